@@ -0,0 +1,268 @@
+// Package client drives language servers from Go: it launches a server
+// process or dials a socket, runs the initialize handshake, sends typed
+// requests and notifications, and answers the requests the server sends.
+//
+//	c, err := client.Start(ctx, exec.Command("my-server"))
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//
+//	if _, err := c.Initialize(ctx, client.InitializeParams{RootURI: uri.File(dir)}); err != nil {
+//		return err
+//	}
+//	hover, err := c.Hover(ctx, u, lsp.Position{Line: 3, Character: 9})
+//
+// Messages are framed by package wire, and the callbacks registered with On
+// are dispatched like the server's: notifications one at a time in the order
+// they were received, requests each in their own goroutine.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/internal/rpc"
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// ExitTimeout is how long Close waits for a server process to exit after the
+// exit notification, before killing it.
+const ExitTimeout = 5 * time.Second
+
+// ErrClosed is returned when sending a message once the connection to the
+// server is closed.
+var ErrClosed = errors.New("[client] connection closed")
+
+// Client is a client connected to a language server over a stream.
+type Client struct {
+	cancel    context.CancelFunc
+	conn      *rpc.Conn
+	callbacks *jsonrpc.MethodRepository
+	cmd       *exec.Cmd // nil unless the server was started by the client
+
+	mu           sync.Mutex
+	observers    []func(ctx context.Context, method string, params *fastjson.RawMessage)
+	capabilities *server.ServerCapabilities
+	versions     map[lsp.DocumentURI]int
+}
+
+// New returns a client talking to a server over rwc, until ctx is cancelled
+// or Close is called. rwc is closed when the connection is.
+//
+// The requests the server commonly sends are answered by default: capability
+// registrations and progress creation with null, workspace/configuration
+// with null settings. Other requests are answered with a MethodNotFound error
+// unless handled with On.
+func New(ctx context.Context, rwc io.ReadWriteCloser) *Client {
+	ctx, cancel := context.WithCancel(ctx)
+	c := &Client{
+		cancel:    cancel,
+		callbacks: jsonrpc.NewMethodRepository(),
+		versions:  make(map[lsp.DocumentURI]int),
+	}
+	c.conn = rpc.New(rwc, rpc.HandlerFunc(c.handle), rpc.Options{ErrClosed: ErrClosed})
+	c.On("client/registerCapability", nullResult)
+	c.On("client/unregisterCapability", nullResult)
+	c.On("window/workDoneProgress/create", nullResult)
+	c.On("workspace/configuration", nullConfiguration)
+
+	go func() {
+		_ = c.conn.Serve(ctx)
+	}()
+	return c
+}
+
+// Dial connects to a server listening on address, e.g. "localhost:4389" over
+// "tcp" or a path over "unix".
+func Dial(ctx context.Context, network, address string) (*Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return New(ctx, conn), nil
+}
+
+// Start starts cmd and connects to it over its standard input and output.
+// The standard error of cmd is left as configured by the caller. Close waits
+// for the process to exit, killing it after ExitTimeout.
+func Start(ctx context.Context, cmd *exec.Cmd) (*Client, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	c := New(ctx, processStream{ReadCloser: stdout, WriteCloser: stdin})
+	c.cmd = cmd
+	return c, nil
+}
+
+// processStream is the stream to a server process.
+type processStream struct {
+	io.ReadCloser
+	io.WriteCloser
+}
+
+func (p processStream) Close() error {
+	err := p.WriteCloser.Close()
+	p.ReadCloser.Close()
+	return err
+}
+
+// On registers a callback for a request or notification sent by the server,
+// replacing the previous one, if any. Errors returned by request callbacks
+// are sent to the server as in server.Server's On.
+func (c *Client) On(method string, do server.CallbackFunc) {
+	if err := c.callbacks.RegisterMethod(method, handler(do), nil, nil); err != nil {
+		panic(fmt.Errorf("[client] register %s: %+v", method, err))
+	}
+}
+
+// OnNotification registers fn to be called with every notification sent by
+// the server, in order, before the callback registered for its method with On,
+// if any.
+func (c *Client) OnNotification(fn func(ctx context.Context, method string, params *fastjson.RawMessage)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.observers = append(c.observers, fn)
+}
+
+// Done returns a channel closed when the connection to the server is closed.
+func (c *Client) Done() <-chan struct{} {
+	return c.conn.Done()
+}
+
+// Call sends a request to the server and waits for its response, which is
+// decoded into result unless it is nil. Errors returned by the server are
+// *jsonrpc.Error values.
+//
+// If ctx is cancelled before the server responds, the request is cancelled
+// with $/cancelRequest and ctx's error is returned.
+func (c *Client) Call(ctx context.Context, method string, params, result interface{}) error {
+	return c.conn.Call(ctx, method, params, result)
+}
+
+// Notify sends a notification to the server.
+func (c *Client) Notify(ctx context.Context, method string, params interface{}) error {
+	return c.conn.Notify(method, params)
+}
+
+// Close shuts the server down with the shutdown request and the exit
+// notification, unless the connection is already closed, then closes the
+// connection. If the client started the server process, Close waits for it
+// to exit and returns its error.
+func (c *Client) Close() error {
+	exiting := false
+	if !c.conn.Closed() {
+		ctx, cancel := context.WithTimeout(context.Background(), ExitTimeout)
+		defer cancel()
+		if err := c.Call(ctx, "shutdown", nil, nil); err == nil {
+			exiting = c.Notify(ctx, "exit", nil) == nil
+		}
+	}
+	if c.cmd == nil {
+		c.cancel()
+		<-c.Done()
+		return nil
+	}
+
+	// The process closes its output when exiting: the connection is closed
+	// only then, so that the messages sent until then are read.
+	if exiting {
+		select {
+		case <-c.Done():
+		case <-time.After(ExitTimeout):
+		}
+	}
+	c.cancel()
+	<-c.Done()
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- c.cmd.Wait()
+	}()
+	select {
+	case err := <-exited:
+		return err
+	case <-time.After(ExitTimeout):
+		_ = c.cmd.Process.Kill()
+		return <-exited
+	}
+}
+
+// Err returns the error the connection failed with, if any, once it is
+// closed.
+func (c *Client) Err() error {
+	return c.conn.Err()
+}
+
+// handle dispatches a message of the server to the callback of its method.
+// Requests without callback are answered with a MethodNotFound error.
+func (c *Client) handle(ctx context.Context, msg *rpc.Message) (interface{}, *jsonrpc.Error) {
+	if msg.ID == nil {
+		c.mu.Lock()
+		observers := c.observers
+		c.mu.Unlock()
+
+		for _, fn := range observers {
+			fn(ctx, msg.Method, msg.Params)
+		}
+	}
+
+	h, rpcErr := c.callbacks.TakeMethod(&jsonrpc.Request{Version: jsonrpc.Version, Method: msg.Method})
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	return h.ServeJSONRPC(ctx, msg.Params)
+}
+
+// handler adapts a server.CallbackFunc to the jsonrpc.Handler interface.
+type handler server.CallbackFunc
+
+func (h handler) ServeJSONRPC(ctx context.Context, params *fastjson.RawMessage) (interface{}, *jsonrpc.Error) {
+	if h == nil {
+		return nil, nil
+	}
+
+	result, err := h(ctx, params)
+	if err != nil {
+		var rpcErr *jsonrpc.Error
+		if errors.As(err, &rpcErr) {
+			return nil, rpcErr
+		}
+		rpcErr = jsonrpc.ErrInternal()
+		rpcErr.Message = err.Error()
+		return nil, rpcErr
+	}
+	return result, nil
+}
+
+func nullResult(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	return nil, nil
+}
+
+func nullConfiguration(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	var p lsp.ConfigurationParams
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	return make([]interface{}, len(p.Items)), nil
+}
@@ -0,0 +1,312 @@
+package client
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/codeaction"
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+)
+
+// helperServerEnv makes the test binary run newTestServer over stdio, for
+// tests starting a server process.
+const helperServerEnv = "CLIENT_TEST_HELPER_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(helperServerEnv) != "" {
+		newTestServer().StartStdio()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func newTestServer() *server.Server {
+	s := server.NewServer(context.Background())
+	s.Capabilities().HoverProvider = true
+
+	s.On("textDocument/didOpen", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p lsp.DidOpenTextDocumentParams
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+
+		var settings []interface{}
+		if err := s.Call(ctx, "workspace/configuration", lsp.ConfigurationParams{Items: []lsp.ConfigurationItem{{Section: "go"}}}, &settings); err != nil {
+			return nil, err
+		}
+		var action lsp.MessageActionItem
+		if err := s.Call(ctx, "window/showMessageRequest", lsp.ShowMessageRequestParams{Message: p.TextDocument.Text}, &action); err != nil {
+			return nil, err
+		}
+		return nil, s.Notify(ctx, "textDocument/publishDiagnostics", lsp.PublishDiagnosticsParams{
+			URI:         p.TextDocument.URI,
+			Diagnostics: []lsp.Diagnostic{{Message: action.Title}},
+		})
+	})
+	s.On("textDocument/hover", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p lsp.TextDocumentPositionParams
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Position.Line > 0 {
+			return nil, nil
+		}
+		return lsp.Hover{Contents: []lsp.MarkedString{{Language: "go", Value: "func main()"}}}, nil
+	})
+	s.On("textDocument/definition", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p lsp.TextDocumentPositionParams
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		switch p.Position.Line {
+		case 0:
+			return lsp.Location{URI: "file:///a.go"}, nil
+		case 1:
+			return []interface{}{map[string]interface{}{
+				"targetUri":            "file:///b.go",
+				"targetRange":          lsp.Range{End: lsp.Position{Line: 3}},
+				"targetSelectionRange": lsp.Range{Start: lsp.Position{Line: 1}},
+			}}, nil
+		}
+		return nil, nil
+	})
+	s.On("textDocument/documentSymbol", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		return []interface{}{map[string]interface{}{
+			"name":           "T",
+			"kind":           lsp.SKStruct,
+			"range":          lsp.Range{},
+			"selectionRange": lsp.Range{Start: lsp.Position{Line: 1}},
+			"children": []interface{}{map[string]interface{}{
+				"name":           "f",
+				"kind":           lsp.SKField,
+				"range":          lsp.Range{},
+				"selectionRange": lsp.Range{Start: lsp.Position{Line: 2}},
+			}},
+		}}, nil
+	})
+	s.On("textDocument/codeAction", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		return []interface{}{
+			lsp.Command{Title: "Organize", Command: "organize"},
+			codeaction.CodeAction{Title: "Fix", Kind: "quickfix"},
+		}, nil
+	})
+	s.On("workspace/executeCommand", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p lsp.ExecuteCommandParams
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Command == "slow" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return p.Arguments, nil
+	})
+
+	return s
+}
+
+// connect connects a client to a new test server over a pipe.
+func connect(ctx context.Context) (*Client, chan error) {
+	clientConn, serverConn := net.Pipe()
+	served := make(chan error, 1)
+	go func() {
+		served <- newTestServer().Serve(serverConn)
+	}()
+	return New(ctx, clientConn), served
+}
+
+// answerMessages answers the window/showMessageRequest requests of the test
+// server with their message.
+func answerMessages(c *Client) {
+	c.On("window/showMessageRequest", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p lsp.ShowMessageRequestParams
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return lsp.MessageActionItem{Title: p.Message}, nil
+	})
+}
+
+func TestClient(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, served := connect(ctx)
+	answerMessages(c)
+	diagnostics := make(chan lsp.PublishDiagnosticsParams, 1)
+	c.On("textDocument/publishDiagnostics", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p lsp.PublishDiagnosticsParams
+		err := jsonrpc.Unmarshal(params, &p)
+		diagnostics <- p
+		return nil, err
+	})
+	assert.Nil(t, c.ServerCapabilities())
+
+	result, err := c.Initialize(ctx, InitializeParams{RootURI: "file:///"})
+	assert.NoError(t, err)
+	assert.True(t, result.Capabilities.HoverProvider)
+	assert.True(t, c.ServerCapabilities().HoverProvider)
+
+	assert.NoError(t, c.DidOpen(ctx, "file:///main.go", "go", "package main"))
+	select {
+	case p := <-diagnostics:
+		assert.Equal(t, lsp.PublishDiagnosticsParams{URI: "file:///main.go", Diagnostics: []lsp.Diagnostic{{Message: "package main"}}}, p)
+	case <-ctx.Done():
+		t.Fatal("no diagnostics published")
+	}
+
+	hover, err := c.Hover(ctx, "file:///main.go", lsp.Position{})
+	assert.NoError(t, err)
+	assert.Equal(t, &lsp.Hover{Contents: []lsp.MarkedString{{Language: "go", Value: "func main()"}}}, hover)
+	hover, err = c.Hover(ctx, "file:///main.go", lsp.Position{Line: 1})
+	assert.NoError(t, err)
+	assert.Nil(t, hover)
+
+	locations, err := c.Definition(ctx, "file:///main.go", lsp.Position{})
+	assert.NoError(t, err)
+	assert.Equal(t, []lsp.Location{{URI: "file:///a.go"}}, locations)
+	locations, err = c.Definition(ctx, "file:///main.go", lsp.Position{Line: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []lsp.Location{{URI: "file:///b.go", Range: lsp.Range{Start: lsp.Position{Line: 1}}}}, locations)
+	locations, err = c.Definition(ctx, "file:///main.go", lsp.Position{Line: 2})
+	assert.NoError(t, err)
+	assert.Nil(t, locations)
+
+	symbols, err := c.DocumentSymbols(ctx, "file:///main.go")
+	assert.NoError(t, err)
+	assert.Equal(t, []lsp.SymbolInformation{
+		{Name: "T", Kind: lsp.SKStruct, Location: lsp.Location{URI: "file:///main.go", Range: lsp.Range{Start: lsp.Position{Line: 1}}}},
+		{Name: "f", Kind: lsp.SKField, Location: lsp.Location{URI: "file:///main.go", Range: lsp.Range{Start: lsp.Position{Line: 2}}}, ContainerName: "T"},
+	}, symbols)
+
+	actions, err := c.CodeActions(ctx, "file:///main.go", lsp.Range{}, codeaction.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, []codeaction.CodeAction{
+		{Title: "Organize", Command: &lsp.Command{Title: "Organize", Command: "organize"}},
+		{Title: "Fix", Kind: "quickfix"},
+	}, actions)
+
+	var args []string
+	assert.NoError(t, c.ExecuteCommand(ctx, "echo", []interface{}{"a", "b"}, &args))
+	assert.Equal(t, []string{"a", "b"}, args)
+
+	_, err = c.Completion(ctx, "file:///main.go", lsp.Position{})
+	assert.Equal(t, jsonrpc.ErrorCodeMethodNotFound, err.(*jsonrpc.Error).Code)
+
+	assert.NoError(t, c.Close())
+	assert.NoError(t, <-served)
+	assert.Equal(t, ErrClosed, c.Call(ctx, "shutdown", nil, nil))
+}
+
+func TestCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, served := connect(ctx)
+	defer func() {
+		assert.NoError(t, c.Close())
+		assert.NoError(t, <-served)
+	}()
+	_, err := c.Initialize(ctx, InitializeParams{})
+	assert.NoError(t, err)
+
+	callCtx, cancelCall := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancelCall()
+	assert.Equal(t, context.DeadlineExceeded, c.ExecuteCommand(callCtx, "slow", nil, nil))
+}
+
+func TestUnhandledRequest(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, served := connect(ctx)
+	defer func() {
+		assert.NoError(t, c.Close())
+		assert.NoError(t, <-served)
+	}()
+	logs := make(chan string, 10)
+	c.On("window/logMessage", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p lsp.LogMessageParams
+		err := jsonrpc.Unmarshal(params, &p)
+		logs <- p.Message
+		return nil, err
+	})
+
+	_, err := c.Initialize(ctx, InitializeParams{})
+	assert.NoError(t, err)
+	assert.NoError(t, c.DidOpen(ctx, "file:///main.go", "go", "package main"))
+
+	select {
+	case msg := <-logs:
+		assert.Contains(t, msg, "Method not found")
+	case <-ctx.Done():
+		t.Fatal("no error logged")
+	}
+}
+
+func TestDial(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer l.Close()
+	served := make(chan error, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			served <- err
+			return
+		}
+		served <- newTestServer().Serve(conn)
+	}()
+
+	c, err := Dial(ctx, "tcp", l.Addr().String())
+	if !assert.NoError(t, err) {
+		return
+	}
+	result, err := c.Initialize(ctx, InitializeParams{})
+	assert.NoError(t, err)
+	assert.True(t, result.Capabilities.HoverProvider)
+
+	assert.NoError(t, c.Close())
+	assert.NoError(t, <-served)
+}
+
+func TestStart(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), helperServerEnv+"=1")
+	c, err := Start(ctx, cmd)
+	if !assert.NoError(t, err) {
+		return
+	}
+	answerMessages(c)
+
+	result, err := c.Initialize(ctx, InitializeParams{})
+	assert.NoError(t, err)
+	assert.True(t, result.Capabilities.HoverProvider)
+	hover, err := c.Hover(ctx, "file:///main.go", lsp.Position{})
+	assert.NoError(t, err)
+	assert.NotNil(t, hover)
+
+	assert.NoError(t, c.Close())
+	assert.True(t, cmd.ProcessState.Exited())
+	select {
+	case <-c.Done():
+	default:
+		t.Error("connection not closed")
+	}
+}
@@ -0,0 +1,364 @@
+package client
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/goodgophers/golsp-sdk/codeaction"
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/intel-go/fastjson"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// WorkspaceFolder is a workspace folder opened in the client.
+type WorkspaceFolder struct {
+	URI  uri.URI `json:"uri"`
+	Name string  `json:"name"`
+}
+
+// InitializeParams are the parameters of the initialize request.
+//
+// Capabilities are the client capabilities, either an lsp.ClientCapabilities
+// or, for capabilities it does not model, any value encoding to the JSON
+// object defined by the LSP specification, e.g.
+//
+//	Capabilities: map[string]interface{}{
+//		"window": map[string]interface{}{"showDocument": map[string]interface{}{"support": true}},
+//	}
+type InitializeParams struct {
+	ProcessID             int               `json:"processId,omitempty"`
+	ClientInfo            *lsp.ClientInfo   `json:"clientInfo,omitempty"`
+	RootURI               uri.URI           `json:"rootUri,omitempty"`
+	WorkspaceFolders      []WorkspaceFolder `json:"workspaceFolders,omitempty"`
+	InitializationOptions interface{}       `json:"initializationOptions,omitempty"`
+	Capabilities          interface{}       `json:"capabilities"`
+	Trace                 lsp.Trace         `json:"trace,omitempty"`
+}
+
+// Initialize runs the initialize handshake: it sends the initialize request,
+// then the initialized notification, and returns the server's response. The
+// process ID defaults to the one of the current process, so that servers
+// watching their parent exit along with it.
+func (c *Client) Initialize(ctx context.Context, params InitializeParams) (*server.InitializeResult, error) {
+	if params.ProcessID == 0 {
+		params.ProcessID = os.Getpid()
+	}
+	if params.Capabilities == nil {
+		params.Capabilities = lsp.ClientCapabilities{}
+	}
+
+	var result server.InitializeResult
+	if err := c.Call(ctx, "initialize", params, &result); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.capabilities = &result.Capabilities
+	c.mu.Unlock()
+
+	if err := c.Notify(ctx, "initialized", struct{}{}); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ServerCapabilities returns the capabilities the server responded to
+// initialize with, or nil before.
+func (c *Client) ServerCapabilities() *server.ServerCapabilities {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.capabilities
+}
+
+// DidOpen sends a textDocument/didOpen notification for the document at u, of
+// the given language and content.
+func (c *Client) DidOpen(ctx context.Context, u uri.URI, languageID, text string) error {
+	c.mu.Lock()
+	c.versions[u.DocumentURI()] = 1
+	c.mu.Unlock()
+
+	return c.Notify(ctx, "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: u.DocumentURI(), LanguageID: languageID, Version: 1, Text: text},
+	})
+}
+
+// DidChange sends a textDocument/didChange notification with the changes of
+// the document at u. Versions are incremented from the one the document was
+// opened with.
+func (c *Client) DidChange(ctx context.Context, u uri.URI, changes ...lsp.TextDocumentContentChangeEvent) error {
+	c.mu.Lock()
+	c.versions[u.DocumentURI()]++
+	version := c.versions[u.DocumentURI()]
+	c.mu.Unlock()
+
+	return c.Notify(ctx, "textDocument/didChange", lsp.DidChangeTextDocumentParams{
+		TextDocument:   lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: documentID(u), Version: version},
+		ContentChanges: changes,
+	})
+}
+
+// DidSave sends a textDocument/didSave notification for the document at u.
+func (c *Client) DidSave(ctx context.Context, u uri.URI) error {
+	return c.Notify(ctx, "textDocument/didSave", lsp.DidSaveTextDocumentParams{TextDocument: documentID(u)})
+}
+
+// DidClose sends a textDocument/didClose notification for the document at u.
+func (c *Client) DidClose(ctx context.Context, u uri.URI) error {
+	c.mu.Lock()
+	delete(c.versions, u.DocumentURI())
+	c.mu.Unlock()
+
+	return c.Notify(ctx, "textDocument/didClose", lsp.DidCloseTextDocumentParams{TextDocument: documentID(u)})
+}
+
+// Hover sends a textDocument/hover request for the position pos of the
+// document at u. It returns nil if the server has nothing to show.
+func (c *Client) Hover(ctx context.Context, u uri.URI, pos lsp.Position) (*lsp.Hover, error) {
+	var hover *lsp.Hover
+	if err := c.Call(ctx, "textDocument/hover", positionParams(u, pos), &hover); err != nil {
+		return nil, err
+	}
+	return hover, nil
+}
+
+// Completion sends a textDocument/completion request for the position pos of
+// the document at u. Servers responding with a bare list of items get it
+// wrapped in a complete lsp.CompletionList; it is nil if the server responds
+// with null.
+func (c *Client) Completion(ctx context.Context, u uri.URI, pos lsp.Position) (*lsp.CompletionList, error) {
+	params := lsp.CompletionParams{TextDocumentPositionParams: positionParams(u, pos)}
+
+	var raw fastjson.RawMessage
+	if err := c.Call(ctx, "textDocument/completion", params, &raw); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case isNull(raw):
+		return nil, nil
+	case isArray(raw):
+		list := &lsp.CompletionList{}
+		if err := fastjson.Unmarshal(raw, &list.Items); err != nil {
+			return nil, err
+		}
+		return list, nil
+	default:
+		var list lsp.CompletionList
+		if err := fastjson.Unmarshal(raw, &list); err != nil {
+			return nil, err
+		}
+		return &list, nil
+	}
+}
+
+// SignatureHelp sends a textDocument/signatureHelp request for the position
+// pos of the document at u. It returns nil if the server has nothing to show.
+func (c *Client) SignatureHelp(ctx context.Context, u uri.URI, pos lsp.Position) (*lsp.SignatureHelp, error) {
+	var help *lsp.SignatureHelp
+	if err := c.Call(ctx, "textDocument/signatureHelp", positionParams(u, pos), &help); err != nil {
+		return nil, err
+	}
+	return help, nil
+}
+
+// Definition sends a textDocument/definition request for the position pos of
+// the document at u. Location links are returned as the locations of their
+// target selection range.
+func (c *Client) Definition(ctx context.Context, u uri.URI, pos lsp.Position) ([]lsp.Location, error) {
+	return c.locations(ctx, "textDocument/definition", positionParams(u, pos))
+}
+
+// TypeDefinition sends a textDocument/typeDefinition request, like Definition.
+func (c *Client) TypeDefinition(ctx context.Context, u uri.URI, pos lsp.Position) ([]lsp.Location, error) {
+	return c.locations(ctx, "textDocument/typeDefinition", positionParams(u, pos))
+}
+
+// Implementation sends a textDocument/implementation request, like
+// Definition.
+func (c *Client) Implementation(ctx context.Context, u uri.URI, pos lsp.Position) ([]lsp.Location, error) {
+	return c.locations(ctx, "textDocument/implementation", positionParams(u, pos))
+}
+
+// References sends a textDocument/references request for the position pos of
+// the document at u.
+func (c *Client) References(ctx context.Context, u uri.URI, pos lsp.Position, includeDeclaration bool) ([]lsp.Location, error) {
+	return c.locations(ctx, "textDocument/references", lsp.ReferenceParams{
+		TextDocumentPositionParams: positionParams(u, pos),
+		Context:                    lsp.ReferenceContext{IncludeDeclaration: includeDeclaration},
+	})
+}
+
+// locationLink is either a lsp.Location or a LocationLink.
+type locationLink struct {
+	lsp.Location
+	TargetURI            lsp.DocumentURI `json:"targetUri"`
+	TargetSelectionRange lsp.Range       `json:"targetSelectionRange"`
+}
+
+func (l locationLink) location() lsp.Location {
+	if l.TargetURI != "" {
+		return lsp.Location{URI: l.TargetURI, Range: l.TargetSelectionRange}
+	}
+	return l.Location
+}
+
+// locations sends a request responded to with a location, an array of
+// locations or an array of location links.
+func (c *Client) locations(ctx context.Context, method string, params interface{}) ([]lsp.Location, error) {
+	var raw fastjson.RawMessage
+	if err := c.Call(ctx, method, params, &raw); err != nil {
+		return nil, err
+	}
+
+	var links []locationLink
+	switch {
+	case isNull(raw):
+		return nil, nil
+	case isArray(raw):
+		if err := fastjson.Unmarshal(raw, &links); err != nil {
+			return nil, err
+		}
+	default:
+		var link locationLink
+		if err := fastjson.Unmarshal(raw, &link); err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+
+	locations := make([]lsp.Location, len(links))
+	for i, link := range links {
+		locations[i] = link.location()
+	}
+	return locations, nil
+}
+
+// documentSymbol is either a lsp.SymbolInformation or a DocumentSymbol.
+type documentSymbol struct {
+	Name           string           `json:"name"`
+	Kind           lsp.SymbolKind   `json:"kind"`
+	Location       *lsp.Location    `json:"location"`
+	ContainerName  string           `json:"containerName"`
+	SelectionRange lsp.Range        `json:"selectionRange"`
+	Children       []documentSymbol `json:"children"`
+}
+
+// DocumentSymbols sends a textDocument/documentSymbol request for the
+// document at u. Hierarchical symbols are flattened, each symbol having its
+// parent as container and its selection range as location.
+func (c *Client) DocumentSymbols(ctx context.Context, u uri.URI) ([]lsp.SymbolInformation, error) {
+	var symbols []documentSymbol
+	if err := c.Call(ctx, "textDocument/documentSymbol", lsp.DocumentSymbolParams{TextDocument: documentID(u)}, &symbols); err != nil {
+		return nil, err
+	}
+
+	var flattened []lsp.SymbolInformation
+	var flatten func(symbols []documentSymbol, container string)
+	flatten = func(symbols []documentSymbol, container string) {
+		for _, s := range symbols {
+			info := lsp.SymbolInformation{Name: s.Name, Kind: s.Kind, ContainerName: s.ContainerName}
+			if s.Location != nil {
+				info.Location = *s.Location
+			} else {
+				info.Location = lsp.Location{URI: u.DocumentURI(), Range: s.SelectionRange}
+				info.ContainerName = container
+			}
+			flattened = append(flattened, info)
+			flatten(s.Children, s.Name)
+		}
+	}
+	flatten(symbols, "")
+	return flattened, nil
+}
+
+// WorkspaceSymbols sends a workspace/symbol request for query.
+func (c *Client) WorkspaceSymbols(ctx context.Context, query string) ([]lsp.SymbolInformation, error) {
+	var symbols []lsp.SymbolInformation
+	if err := c.Call(ctx, "workspace/symbol", lsp.WorkspaceSymbolParams{Query: query}, &symbols); err != nil {
+		return nil, err
+	}
+	return symbols, nil
+}
+
+// CodeActions sends a textDocument/codeAction request for the range rng of
+// the document at u. Commands returned by the server are wrapped into code
+// actions, with the title of the command.
+func (c *Client) CodeActions(ctx context.Context, u uri.URI, rng lsp.Range, actionContext codeaction.Context) ([]codeaction.CodeAction, error) {
+	if actionContext.Diagnostics == nil {
+		actionContext.Diagnostics = []lsp.Diagnostic{}
+	}
+
+	var raws []fastjson.RawMessage
+	params := codeaction.Params{TextDocument: documentID(u), Range: rng, Context: actionContext}
+	if err := c.Call(ctx, "textDocument/codeAction", params, &raws); err != nil {
+		return nil, err
+	}
+
+	actions := make([]codeaction.CodeAction, len(raws))
+	for i, raw := range raws {
+		var probe struct {
+			Command fastjson.RawMessage `json:"command"`
+		}
+		if err := fastjson.Unmarshal(raw, &probe); err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(strings.TrimSpace(string(probe.Command)), `"`) {
+			var command lsp.Command
+			if err := fastjson.Unmarshal(raw, &command); err != nil {
+				return nil, err
+			}
+			actions[i] = codeaction.CodeAction{Title: command.Title, Command: &command}
+			continue
+		}
+		if err := fastjson.Unmarshal(raw, &actions[i]); err != nil {
+			return nil, err
+		}
+	}
+	return actions, nil
+}
+
+// Formatting sends a textDocument/formatting request for the document at u.
+func (c *Client) Formatting(ctx context.Context, u uri.URI, options lsp.FormattingOptions) ([]lsp.TextEdit, error) {
+	var edits []lsp.TextEdit
+	if err := c.Call(ctx, "textDocument/formatting", lsp.DocumentFormattingParams{TextDocument: documentID(u), Options: options}, &edits); err != nil {
+		return nil, err
+	}
+	return edits, nil
+}
+
+// Rename sends a textDocument/rename request renaming the symbol at the
+// position pos of the document at u to newName.
+func (c *Client) Rename(ctx context.Context, u uri.URI, pos lsp.Position, newName string) (*lsp.WorkspaceEdit, error) {
+	var edit *lsp.WorkspaceEdit
+	params := lsp.RenameParams{TextDocument: documentID(u), Position: pos, NewName: newName}
+	if err := c.Call(ctx, "textDocument/rename", params, &edit); err != nil {
+		return nil, err
+	}
+	return edit, nil
+}
+
+// ExecuteCommand sends a workspace/executeCommand request for command, and
+// decodes its result into result unless it is nil.
+func (c *Client) ExecuteCommand(ctx context.Context, command string, arguments []interface{}, result interface{}) error {
+	return c.Call(ctx, "workspace/executeCommand", lsp.ExecuteCommandParams{Command: command, Arguments: arguments}, result)
+}
+
+func documentID(u uri.URI) lsp.TextDocumentIdentifier {
+	return lsp.TextDocumentIdentifier{URI: u.DocumentURI()}
+}
+
+func positionParams(u uri.URI, pos lsp.Position) lsp.TextDocumentPositionParams {
+	return lsp.TextDocumentPositionParams{TextDocument: documentID(u), Position: pos}
+}
+
+func isNull(raw fastjson.RawMessage) bool {
+	trimmed := strings.TrimSpace(string(raw))
+	return trimmed == "" || trimmed == "null"
+}
+
+func isArray(raw fastjson.RawMessage) bool {
+	return strings.HasPrefix(strings.TrimSpace(string(raw)), "[")
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+)
+
+// stringsFlag is a flag that may be repeated.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// callFlags are the flags of the call command.
+type callFlags struct {
+	connectFlags
+	params string
+	open   stringsFlag
+}
+
+var callCommand = &command{
+	name:  "call",
+	usage: "[flags] <method> <server command> [arguments] | -addr host:port <method>",
+	short: "send a request to a server and print its result",
+	flags: func(fs *flag.FlagSet) interface{} {
+		f := &callFlags{}
+		f.register(fs)
+		fs.StringVar(&f.params, "params", "", "the parameters of the request, as `JSON`")
+		fs.Var(&f.open, "open", "open the `file` in the server before the request; may be repeated")
+		return f
+	},
+	run: func(ctx context.Context, e *env, flags interface{}, args []string) error {
+		f := flags.(*callFlags)
+		if len(args) == 0 {
+			return errUsage
+		}
+		method, args := args[0], args[1:]
+
+		var params interface{}
+		if f.params != "" {
+			if !json.Valid([]byte(f.params)) {
+				return fmt.Errorf("invalid parameters %s", f.params)
+			}
+			raw := fastjson.RawMessage(f.params)
+			params = &raw
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, f.timeout)
+		defer cancel()
+
+		c, _, err := f.connect(ctx, e, args)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+
+		for _, path := range f.open {
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			if err := c.DidOpen(ctx, uri.File(abs), languageID(path), string(content)); err != nil {
+				return err
+			}
+		}
+
+		var result fastjson.RawMessage
+		if err := c.Call(ctx, method, params, &result); err != nil {
+			if rpcErr, ok := err.(*jsonrpc.Error); ok {
+				return fmt.Errorf("%s: error %d: %s", method, rpcErr.Code, rpcErr.Message)
+			}
+			return fmt.Errorf("%s: %v", method, err)
+		}
+		return printJSON(e, result)
+	},
+}
+
+// languageIDs are the language identifiers of the LSP specification, by file
+// extension, for the extensions not matching them.
+var languageIDs = map[string]string{
+	".c":    "c",
+	".cc":   "cpp",
+	".cpp":  "cpp",
+	".cs":   "csharp",
+	".h":    "c",
+	".hpp":  "cpp",
+	".js":   "javascript",
+	".jsx":  "javascriptreact",
+	".md":   "markdown",
+	".py":   "python",
+	".rb":   "ruby",
+	".rs":   "rust",
+	".sh":   "shellscript",
+	".ts":   "typescript",
+	".tsx":  "typescriptreact",
+	".txt":  "plaintext",
+	".yml":  "yaml",
+	".yaml": "yaml",
+}
+
+// languageID returns the language identifier of the file at path, e.g. "go"
+// for main.go.
+func languageID(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if id, ok := languageIDs[ext]; ok {
+		return id
+	}
+	if ext == "" {
+		return "plaintext"
+	}
+	return ext[1:]
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/intel-go/fastjson"
+)
+
+// capabilitiesFlags are the flags of the capabilities command.
+type capabilitiesFlags struct {
+	connectFlags
+	full bool
+}
+
+var capabilitiesCommand = &command{
+	name:  "capabilities",
+	usage: "[flags] <server command> [arguments] | -addr host:port",
+	short: "print the capabilities a server advertises",
+	flags: func(fs *flag.FlagSet) interface{} {
+		f := &capabilitiesFlags{}
+		f.register(fs)
+		fs.BoolVar(&f.full, "full", false, "print the whole result of initialize, e.g. with the server info")
+		return f
+	},
+	run: func(ctx context.Context, e *env, flags interface{}, args []string) error {
+		f := flags.(*capabilitiesFlags)
+		ctx, cancel := context.WithTimeout(ctx, f.timeout)
+		defer cancel()
+
+		c, result, err := f.connect(ctx, e, args)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+
+		if f.full {
+			return printJSON(e, result)
+		}
+		var r struct {
+			Capabilities fastjson.RawMessage `json:"capabilities"`
+		}
+		if err := fastjson.Unmarshal(result, &r); err != nil {
+			return err
+		}
+		return printJSON(e, r.Capabilities)
+	},
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/client"
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/intel-go/fastjson"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// connectFlags are the flags of the commands talking to a server.
+//
+// With -addr, the server is talked to over a TCP stream, as with a server
+// started with a listen flag, e.g. gopls -listen. Servers started with
+// server.StartTCP serve JSON-RPC over HTTP instead, over which they cannot send
+// requests and notifications to the client; they must be run as a command.
+type connectFlags struct {
+	addr    string
+	root    string
+	options string
+	timeout time.Duration
+}
+
+func (f *connectFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.addr, "addr", "", "connect to the server listening on `host:port` over a TCP stream, not HTTP, instead of starting a command")
+	fs.StringVar(&f.root, "root", ".", "the root `directory` of the workspace")
+	fs.StringVar(&f.options, "init-options", "", "the initialization options, as `JSON`")
+	fs.DurationVar(&f.timeout, "timeout", 30*time.Second, "how long to wait for the server")
+}
+
+// connect starts the server command args, or connects to f.addr if set, and
+// runs the initialize handshake. It returns the client, and the result of
+// initialize as the server sent it.
+func (f *connectFlags) connect(ctx context.Context, e *env, args []string) (*client.Client, fastjson.RawMessage, error) {
+	if (f.addr == "") == (len(args) == 0) {
+		return nil, nil, errUsage
+	}
+
+	params := client.InitializeParams{
+		ProcessID:    os.Getpid(),
+		ClientInfo:   &lsp.ClientInfo{Name: "golsp-sdk", Version: sdkVersion()},
+		Capabilities: lsp.ClientCapabilities{},
+	}
+	root, err := filepath.Abs(f.root)
+	if err != nil {
+		return nil, nil, err
+	}
+	params.RootURI = uri.File(root)
+	params.WorkspaceFolders = []client.WorkspaceFolder{{URI: params.RootURI, Name: filepath.Base(root)}}
+	if f.options != "" {
+		if !json.Valid([]byte(f.options)) {
+			return nil, nil, fmt.Errorf("invalid initialization options %s", f.options)
+		}
+		options := fastjson.RawMessage(f.options)
+		params.InitializationOptions = &options
+	}
+
+	var c *client.Client
+	if f.addr != "" {
+		c, err = client.Dial(ctx, "tcp", f.addr)
+	} else {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stderr = e.stderr
+		c, err = client.Start(ctx, cmd)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var result fastjson.RawMessage
+	if err := c.Call(ctx, "initialize", params, &result); err != nil {
+		c.Close()
+		return nil, nil, fmt.Errorf("initialize: %v", err)
+	}
+	if err := c.Notify(ctx, "initialized", struct{}{}); err != nil {
+		c.Close()
+		return nil, nil, fmt.Errorf("initialized: %v", err)
+	}
+	return c, result, nil
+}
+
+// printJSON prints data as indented JSON, or null if empty.
+func printJSON(e *env, data []byte) error {
+	if len(data) == 0 {
+		data = []byte("null")
+	}
+	var b bytes.Buffer
+	if err := json.Indent(&b, data, "", "  "); err != nil {
+		b.Reset()
+		b.Write(data)
+	}
+	b.WriteByte('\n')
+	_, err := e.stdout.Write(b.Bytes())
+	return err
+}
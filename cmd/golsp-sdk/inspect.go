@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/goodgophers/golsp-sdk/inspect"
+)
+
+// inspectFlags are the flags of the inspect command.
+type inspectFlags struct {
+	log    string
+	bodies bool
+	json   string
+	html   string
+}
+
+var inspectCommand = &command{
+	name:  "inspect",
+	usage: "[flags] <server command> [arguments]",
+	short: "show the messages between an editor and a server",
+	flags: func(fs *flag.FlagSet) interface{} {
+		f := &inspectFlags{}
+		fs.StringVar(&f.log, "log", "", "print the messages to `file` rather than to the standard error")
+		fs.BoolVar(&f.bodies, "bodies", true, "print the content of the messages")
+		fs.StringVar(&f.json, "json", "", "write a JSON trace of the messages to `file`, which record.Replay can replay")
+		fs.StringVar(&f.html, "html", "", "write an HTML report of the messages to `file` once the server exits")
+		return f
+	},
+	run: func(ctx context.Context, e *env, flags interface{}, args []string) error {
+		f := flags.(*inspectFlags)
+		if len(args) == 0 {
+			return errUsage
+		}
+
+		printed := e.stderr
+		if f.log != "" {
+			file, err := os.Create(f.log)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			printed = file
+		}
+		observers := []inspect.Observer{inspect.NewPrinter(printed, f.bodies)}
+
+		var jsonWriter *inspect.JSONWriter
+		if f.json != "" {
+			file, err := os.Create(f.json)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			jsonWriter = inspect.NewJSONWriter(file)
+			observers = append(observers, jsonWriter)
+		}
+
+		var trace *inspect.Trace
+		var htmlFile *os.File
+		if f.html != "" {
+			var err error
+			if htmlFile, err = os.Create(f.html); err != nil {
+				return err
+			}
+			defer htmlFile.Close()
+			trace = &inspect.Trace{}
+			observers = append(observers, trace)
+		}
+
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stderr = e.stderr
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return err
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+
+		// Editors may stop servers with signals rather than the exit
+		// notification: the server is then killed, and the traces written as
+		// when it exits.
+		var killed int32
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(signals)
+		exited := make(chan struct{})
+		defer close(exited)
+		go func() {
+			select {
+			case <-signals:
+				atomic.StoreInt32(&killed, 1)
+				_ = cmd.Process.Kill()
+			case <-exited:
+			}
+		}()
+
+		err = inspect.New(observers...).Run(stdio{e.stdin, e.stdout}, stdin, stdout)
+		if waitErr := cmd.Wait(); err == nil && atomic.LoadInt32(&killed) == 0 {
+			err = waitErr
+		}
+		if jsonWriter != nil && err == nil {
+			err = jsonWriter.Err()
+		}
+		if trace != nil {
+			if htmlErr := inspect.WriteHTML(htmlFile, trace.Events()); err == nil {
+				err = htmlErr
+			}
+		}
+		return err
+	},
+}
+
+// stdio is the connection to the editor over the standard input and output,
+// which are left open.
+type stdio struct {
+	io.Reader
+	io.Writer
+}
+
+func (stdio) Close() error {
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/client"
+	"github.com/goodgophers/golsp-sdk/load"
+	"github.com/goodgophers/golsp-sdk/record"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// loadFlags are the flags of the load command.
+type loadFlags struct {
+	synthetic   load.Synthetic
+	replay      string
+	rate        float64
+	concurrency int
+	timeout     time.Duration
+	json        bool
+}
+
+var loadCommand = &command{
+	name:  "load",
+	usage: "[flags] <server command> [arguments]",
+	short: "measure the latency of a server under load",
+	flags: func(fs *flag.FlagSet) interface{} {
+		f := &loadFlags{}
+		fs.IntVar(&f.synthetic.Files, "files", 10, "the number of documents opened")
+		fs.IntVar(&f.synthetic.Lines, "lines", 100, "the number of lines of each document")
+		fs.StringVar(&f.synthetic.Language, "language", "go", "the language of the documents")
+		fs.IntVar(&f.synthetic.Edits, "edits", 200, "the number of characters typed")
+		fs.IntVar(&f.synthetic.Completions, "completions", 100, "the number of completion requests")
+		fs.IntVar(&f.synthetic.Hovers, "hovers", 50, "the number of hover requests")
+		fs.Int64Var(&f.synthetic.Seed, "seed", 1, "the `seed` of the generated session")
+		fs.StringVar(&f.replay, "replay", "", "replay the session recorded in `file` rather than generating one")
+		fs.Float64Var(&f.rate, "rate", 0, "the number of messages sent per second; as fast as possible, or as recorded, if 0")
+		fs.IntVar(&f.concurrency, "concurrency", 16, "the maximum number of requests in flight")
+		fs.DurationVar(&f.timeout, "timeout", 30*time.Second, "how long to wait for each response")
+		fs.BoolVar(&f.json, "json", false, "print the report as JSON")
+		return f
+	},
+	run: func(ctx context.Context, e *env, flags interface{}, args []string) error {
+		f := flags.(*loadFlags)
+		if len(args) == 0 {
+			return errUsage
+		}
+
+		var steps []load.Step
+		if f.replay != "" {
+			entries, err := record.ReadFile(f.replay)
+			if err != nil {
+				return err
+			}
+			steps = load.Recorded(entries)
+		} else {
+			steps = f.synthetic.Steps()
+		}
+
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stderr = e.stderr
+		report, err := load.RunCommand(ctx, cmd, steps, load.Options{
+			Initialize: client.InitializeParams{
+				ProcessID:  os.Getpid(),
+				ClientInfo: &lsp.ClientInfo{Name: "golsp-sdk", Version: sdkVersion()},
+			},
+			Rate:        f.rate,
+			Concurrency: f.concurrency,
+			Timeout:     f.timeout,
+		})
+		if err != nil {
+			return err
+		}
+
+		if f.json {
+			data, err := json.Marshal(report)
+			if err != nil {
+				return err
+			}
+			return printJSON(e, data)
+		}
+		_, err = fmt.Fprint(e.stdout, report)
+		return err
+	},
+}
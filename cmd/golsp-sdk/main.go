@@ -0,0 +1,120 @@
+// Command golsp-sdk helps develop language servers with the SDK.
+//
+// Usage:
+//
+//	golsp-sdk <command> [flags] [arguments]
+//
+// The commands are:
+//
+//	new           create a language server project
+//	capabilities  print the capabilities a server advertises
+//	call          send a request to a server and print its result
+//	inspect       show the messages between an editor and a server
+//	mux           front several servers as one
+//	load          measure the latency of a server under load
+//	version       print the version of the SDK
+//
+// Run "golsp-sdk <command> -h" for the flags and arguments of a command.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// command is a subcommand of golsp-sdk.
+type command struct {
+	name  string
+	usage string // the synopsis of the flags and arguments
+	short string // a one-line description, listed by the usage of golsp-sdk
+
+	// flags registers the flags of the command on fs, and returns the value
+	// they are parsed into, created for each run. run runs the command with
+	// that value, nil if the command has no flags, and its remaining
+	// arguments.
+	flags func(fs *flag.FlagSet) interface{}
+	run   func(ctx context.Context, e *env, flags interface{}, args []string) error
+}
+
+var commands = []*command{newCommand, capabilitiesCommand, callCommand, inspectCommand, muxCommand, loadCommand, versionCommand}
+
+// env holds the streams commands read from and write to.
+type env struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+}
+
+// errUsage is returned by commands invoked with invalid arguments, after
+// which their usage is printed.
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], &env{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}))
+}
+
+// run runs the command named by args[0], and returns the exit code.
+func run(ctx context.Context, args []string, e *env) int {
+	if len(args) == 0 {
+		usage(e.stderr)
+		return 2
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage(e.stdout)
+		return 0
+	}
+
+	var cmd *command
+	for _, c := range commands {
+		if c.name == args[0] {
+			cmd = c
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(e.stderr, "golsp-sdk: unknown command %q\n\n", args[0])
+		usage(e.stderr)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("golsp-sdk "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "usage: %s\n", strings.TrimSpace("golsp-sdk "+cmd.name+" "+cmd.usage))
+		fs.PrintDefaults()
+	}
+	var flags interface{}
+	if cmd.flags != nil {
+		flags = cmd.flags(fs)
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	if err := cmd.run(ctx, e, flags, fs.Args()); err != nil {
+		if err == errUsage {
+			fs.Usage()
+			return 2
+		}
+		fmt.Fprintf(e.stderr, "golsp-sdk %s: %v\n", cmd.name, err)
+		return 1
+	}
+	return 0
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "golsp-sdk helps develop language servers with the SDK.\n\n")
+	fmt.Fprintf(w, "Usage:\n\n\tgolsp-sdk <command> [flags] [arguments]\n\n")
+	fmt.Fprintf(w, "The commands are:\n\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "\t%-13s %s\n", c.name, c.short)
+	}
+	fmt.Fprintf(w, "\nRun \"golsp-sdk <command> -h\" for the flags and arguments of a command.\n")
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/goodgophers/golsp-sdk/glob"
+	"github.com/goodgophers/golsp-sdk/inspect"
+	"github.com/goodgophers/golsp-sdk/load"
+	"github.com/goodgophers/golsp-sdk/record"
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/goodgophers/golsp-sdk/wire"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+)
+
+// helperServerEnv makes the test binary run newTestServer over stdio, for
+// the commands starting a server.
+const helperServerEnv = "GOLSP_SDK_TEST_HELPER_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(helperServerEnv) != "" {
+		newTestServer().StartStdio()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func newTestServer() *server.Server {
+	s := server.NewServer(context.Background())
+	s.Capabilities().HoverProvider = true
+
+	s.On("textDocument/hover", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p lsp.TextDocumentPositionParams
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return lsp.Hover{Contents: []lsp.MarkedString{lsp.RawMarkedString(string(p.TextDocument.URI))}}, nil
+	})
+	return s
+}
+
+// runCommand runs golsp-sdk with args, and returns its exit code and output.
+func runCommand(args ...string) (code int, stdout, stderr string) {
+	var out, errOut bytes.Buffer
+	code = run(context.Background(), args, &env{stdin: strings.NewReader(""), stdout: &out, stderr: &errOut})
+	return code, out.String(), errOut.String()
+}
+
+func startHelperServer(t *testing.T) func() {
+	t.Helper()
+
+	assert.NoError(t, os.Setenv(helperServerEnv, "1"))
+	return func() {
+		os.Unsetenv(helperServerEnv)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	code, _, stderr := runCommand()
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "capabilities  print the capabilities a server advertises")
+
+	code, _, stderr = runCommand("build")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, `unknown command "build"`)
+
+	code, _, stderr = runCommand("new")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "usage: golsp-sdk new [flags] <directory>")
+
+	code, _, stderr = runCommand("capabilities")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "usage: golsp-sdk capabilities")
+}
+
+func TestVersion(t *testing.T) {
+	code, stdout, _ := runCommand("version")
+	assert.Equal(t, 0, code)
+	assert.Regexp(t, `^golsp-sdk \S+ go\S+ \w+/\w+\n$`, stdout)
+
+	version = "v1.2.3"
+	defer func() { version = "" }()
+	_, stdout, _ = runCommand("version")
+	assert.Regexp(t, `^golsp-sdk v1.2.3 `, stdout)
+}
+
+func TestNew(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golsp-sdk")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	project := filepath.Join(dir, "hello")
+	code, stdout, stderr := runCommand("new", "-module", "example.com/hello", project)
+	assert.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "Created example.com/hello in "+project)
+
+	goMod, err := ioutil.ReadFile(filepath.Join(project, "go.mod"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(goMod), "module example.com/hello\n")
+	for _, name := range []string{"main.go", "main_test.go"} {
+		content, err := ioutil.ReadFile(filepath.Join(project, name))
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), "package main")
+	}
+
+	code, _, stderr = runCommand("new", project)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "is not empty")
+}
+
+func TestConcurrentRuns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golsp-sdk")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	// Runs don't share their flags.
+	var wg sync.WaitGroup
+	for _, name := range []string{"hello", "world"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			code, stdout, stderr := runCommand("new", "-module", "example.com/"+name, filepath.Join(dir, name))
+			assert.Equal(t, 0, code, stderr)
+			assert.Contains(t, stdout, "Created example.com/"+name+" in ")
+		}(name)
+	}
+	wg.Wait()
+}
+
+func TestNewRequiresReleasedSDK(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golsp-sdk")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	version = "v1.2.3"
+	defer func() { version = "" }()
+	code, stdout, _ := runCommand("new", filepath.Join(dir, "hello"))
+	assert.Equal(t, 0, code)
+	assert.NotContains(t, stdout, "go get")
+
+	goMod, err := ioutil.ReadFile(filepath.Join(dir, "hello", "go.mod"))
+	assert.NoError(t, err)
+	assert.Equal(t, "module hello\n\ngo 1.13\n\nrequire github.com/goodgophers/golsp-sdk v1.2.3\n", string(goMod))
+}
+
+func TestCapabilities(t *testing.T) {
+	defer startHelperServer(t)()
+
+	code, stdout, stderr := runCommand("capabilities", os.Args[0])
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, "{\n  \"hoverProvider\": true\n}\n", stdout)
+
+	code, stdout, stderr = runCommand("capabilities", "-full", os.Args[0])
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, "{\n  \"capabilities\": {\n    \"hoverProvider\": true\n  }\n}\n", stdout)
+}
+
+func TestCall(t *testing.T) {
+	defer startHelperServer(t)()
+
+	code, stdout, stderr := runCommand("call", "-params", `{"textDocument":{"uri":"file:///a.go"},"position":{"line":0,"character":0}}`, "textDocument/hover", os.Args[0])
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, "{\n  \"contents\": [\n    \"file:///a.go\"\n  ]\n}\n", stdout)
+
+	code, _, stderr = runCommand("call", "test/unknown", os.Args[0])
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "golsp-sdk call: test/unknown: error -32601: Method not found")
+
+	code, _, stderr = runCommand("call", "-params", "{", "textDocument/hover", os.Args[0])
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "invalid parameters {")
+}
+
+func TestInspect(t *testing.T) {
+	defer startHelperServer(t)()
+	dir, err := ioutil.TempDir("", "golsp-sdk")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	stdin, stdout, responses := editor(
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}`,
+		`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`,
+	)
+
+	logFile, jsonFile, htmlFile := filepath.Join(dir, "log"), filepath.Join(dir, "trace.json"), filepath.Join(dir, "trace.html")
+	var stderr bytes.Buffer
+	code := run(context.Background(), []string{"inspect", "-log", logFile, "-json", jsonFile, "-html", htmlFile, os.Args[0]}, &env{stdin: stdin, stdout: stdout, stderr: &stderr})
+	stdout.Close()
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, []string{
+		`{"jsonrpc":"2.0","id":1,"result":{"capabilities":{"hoverProvider":true}}}`,
+		`{"jsonrpc":"2.0","method":"window/logMessage","params":{"type":3,"message":"[server] starting stdio..."}}`,
+		`{"jsonrpc":"2.0","id":2,"result":null}`,
+	}, <-responses)
+
+	log, err := ioutil.ReadFile(logFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(log), " --> request initialize #1\n")
+	assert.Contains(t, string(log), " <-- response shutdown #2 in ")
+
+	f, err := os.Open(jsonFile)
+	if assert.NoError(t, err) {
+		defer f.Close()
+		events, err := inspect.ReadTrace(f)
+		assert.NoError(t, err)
+		assert.Len(t, events, 7)
+	}
+
+	html, err := ioutil.ReadFile(htmlFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(html), "<td>shutdown</td>")
+}
+
+func TestMux(t *testing.T) {
+	defer startHelperServer(t)()
+	dir, err := ioutil.TempDir("", "golsp-sdk")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	configuration := filepath.Join(dir, "mux.json")
+	servers := []muxServer{
+		{Name: "go", Command: []string{os.Args[0]}, Selector: glob.DocumentSelector{{Language: "go"}}},
+		{Command: []string{os.Args[0]}},
+	}
+	data, err := json.Marshal(servers)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(configuration, data, 0644))
+
+	stdin, stdout, responses := editor(
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}`,
+		`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"textDocument/hover","params":{"textDocument":{"uri":"file:///a.go"},"position":{"line":0,"character":0}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"shutdown"}`,
+	)
+	var stderr bytes.Buffer
+	code := run(context.Background(), []string{"mux", configuration}, &env{stdin: stdin, stdout: stdout, stderr: &stderr})
+	stdout.Close()
+	assert.Equal(t, 0, code, stderr.String())
+
+	var received []string
+	for _, message := range <-responses {
+		if !strings.Contains(message, `"method"`) {
+			received = append(received, message)
+		}
+	}
+	assert.Equal(t, []string{
+		`{"jsonrpc":"2.0","id":1,"result":{"capabilities":{"hoverProvider":true,"textDocumentSync":{"change":1,"openClose":true,"willSave":false,"willSaveWaitUntil":false}},"serverInfo":{"name":"golsp-sdk mux"}}}`,
+		`{"jsonrpc":"2.0","id":2,"result":{"contents":["file:///a.go"]}}`,
+		`{"jsonrpc":"2.0","id":3,"result":null}`,
+	}, received)
+
+	code, _, errOut := runCommand("mux", filepath.Join(dir, "missing.json"))
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "missing.json")
+}
+
+func TestLoad(t *testing.T) {
+	defer startHelperServer(t)()
+
+	code, stdout, stderr := runCommand("load", "-files", "2", "-lines", "10", "-edits", "20", "-completions", "0", "-hovers", "5", os.Args[0])
+	assert.Equal(t, 0, code, stderr)
+	assert.Regexp(t, `(?m)^textDocument/hover +5 +0 `, stdout)
+	assert.Contains(t, stdout, "5 requests, 24 notifications in ")
+
+	dir, err := ioutil.TempDir("", "golsp-sdk")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	recording := filepath.Join(dir, "session.jsonl")
+	file, err := os.Create(recording)
+	assert.NoError(t, err)
+	recorder := record.NewRecorder(file)
+	assert.NoError(t, recorder.Record(record.In, []byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)))
+	assert.NoError(t, recorder.Record(record.In, []byte(`{"jsonrpc":"2.0","id":2,"method":"textDocument/hover","params":{"textDocument":{"uri":"file:///a.go"},"position":{"line":0,"character":0}}}`)))
+	assert.NoError(t, recorder.Record(record.In, []byte(`{"jsonrpc":"2.0","id":3,"method":"test/unknown"}`)))
+	assert.NoError(t, file.Close())
+
+	code, stdout, stderr = runCommand("load", "-replay", recording, "-json", os.Args[0])
+	assert.Equal(t, 0, code, stderr)
+	var report load.Report
+	assert.NoError(t, json.Unmarshal([]byte(stdout), &report))
+	assert.Equal(t, 2, report.Requests)
+	assert.Equal(t, 1, report.Errors)
+
+	code, _, stderr = runCommand("load")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "usage: golsp-sdk load [flags] <server command> [arguments]")
+}
+
+// editor emulates an editor sending messages to the command it runs, which
+// reads stdin and writes to stdout. The editor waits for the response to each
+// request before sending the next message. It then sends exit, the last
+// request being shutdown, and closes its output. The messages the command
+// sent are received once stdout is closed.
+func editor(messages ...string) (stdin io.Reader, stdout io.WriteCloser, received <-chan []string) {
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	all := make(chan []string, 1)
+	go func() {
+		write := func(message string) {
+			_, _ = fmt.Fprintf(stdinWriter, "Content-Length: %d\r\n\r\n%s", len(message), message)
+		}
+
+		var sent []string
+		reader := bufio.NewReader(stdoutReader)
+		for _, message := range messages {
+			write(message)
+
+			var request struct {
+				ID *json.RawMessage `json:"id"`
+			}
+			if err := json.Unmarshal([]byte(message), &request); err != nil || request.ID == nil {
+				continue
+			}
+			for {
+				data, err := wire.ReadMessage(reader)
+				if err != nil {
+					break
+				}
+				sent = append(sent, string(data))
+
+				var response struct {
+					ID     *json.RawMessage `json:"id"`
+					Method string           `json:"method"`
+				}
+				if json.Unmarshal(data, &response) == nil && response.Method == "" && response.ID != nil && string(*response.ID) == string(*request.ID) {
+					break
+				}
+			}
+		}
+		write(`{"jsonrpc":"2.0","method":"exit"}`)
+		stdinWriter.Close()
+
+		for {
+			data, err := wire.ReadMessage(reader)
+			if err != nil {
+				break
+			}
+			sent = append(sent, string(data))
+		}
+		all <- sent
+	}()
+	return stdinReader, stdoutWriter, all
+}
+
+func TestLanguageID(t *testing.T) {
+	assert.Equal(t, "go", languageID("main.go"))
+	assert.Equal(t, "typescriptreact", languageID("App.TSX"))
+	assert.Equal(t, "plaintext", languageID("Makefile"))
+}
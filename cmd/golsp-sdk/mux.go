@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"sync"
+
+	"github.com/goodgophers/golsp-sdk/client"
+	"github.com/goodgophers/golsp-sdk/glob"
+	"github.com/goodgophers/golsp-sdk/mux"
+)
+
+var muxCommand = &command{
+	name:  "mux",
+	usage: "<configuration file>",
+	short: "front several servers as one",
+	run: func(ctx context.Context, e *env, flags interface{}, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+
+		backends, err := readMuxConfiguration(e, args[0])
+		if err != nil {
+			return err
+		}
+		return mux.New(ctx, backends...).Serve(stdio{e.stdin, e.stdout})
+	},
+}
+
+// muxServer is a server in the configuration of the mux command, a JSON list
+// of servers in order of precedence, e.g.
+//
+//	[
+//		{"name": "go", "command": ["gopls"], "selector": [{"language": "go"}]},
+//		{"name": "spell", "command": ["spell-server", "--stdio"]}
+//	]
+//
+// Servers without selector handle every document.
+type muxServer struct {
+	Name     string                `json:"name"`
+	Command  []string              `json:"command"`
+	Selector glob.DocumentSelector `json:"selector"`
+}
+
+// readMuxConfiguration reads the configuration file at path, and returns the
+// backends it configures. Their standard error is shared with e.
+func readMuxConfiguration(e *env, path string) ([]mux.Backend, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var servers []muxServer
+	if err := json.Unmarshal(data, &servers); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	stderr := &lockedWriter{w: e.stderr}
+	backends := make([]mux.Backend, len(servers))
+	for i, s := range servers {
+		if len(s.Command) == 0 {
+			return nil, fmt.Errorf("%s: server %d has no command", path, i+1)
+		}
+		if s.Name == "" {
+			s.Name = s.Command[0]
+		}
+
+		command := s.Command
+		backends[i] = mux.Backend{
+			Name:     s.Name,
+			Selector: s.Selector,
+			Connect: func(ctx context.Context) (*client.Client, error) {
+				cmd := exec.Command(command[0], command[1:]...)
+				cmd.Stderr = stderr
+				return client.Start(ctx, cmd)
+			},
+		}
+	}
+	return backends, nil
+}
+
+// lockedWriter serializes the writes of several processes to w.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.w.Write(p)
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// newFlags are the flags of the new command.
+type newFlags struct {
+	module string
+}
+
+var newCommand = &command{
+	name:  "new",
+	usage: "[flags] <directory>",
+	short: "create a language server project",
+	flags: func(fs *flag.FlagSet) interface{} {
+		f := &newFlags{}
+		fs.StringVar(&f.module, "module", "", "the module `path` of the project, the name of the directory by default")
+		return f
+	},
+	run: func(ctx context.Context, e *env, flags interface{}, args []string) error {
+		f := flags.(*newFlags)
+		if len(args) != 1 {
+			return errUsage
+		}
+		dir := args[0]
+
+		module := f.module
+		if module == "" {
+			abs, err := filepath.Abs(dir)
+			if err != nil {
+				return err
+			}
+			module = filepath.Base(abs)
+		}
+
+		if err := scaffold(dir, project{Module: module, Name: path.Base(module), SDKVersion: sdkVersion()}); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(e.stdout, "Created %s in %s. To build and test it:\n\n", module, dir)
+		fmt.Fprintf(e.stdout, "\tcd %s\n", dir)
+		if !released(sdkVersion()) {
+			fmt.Fprintf(e.stdout, "\tgo get %s\n", modulePath)
+		}
+		fmt.Fprintf(e.stdout, "\tgo mod tidy\n\tgo test ./...\n")
+		return nil
+	},
+}
+
+// project holds the values the templates of new projects are executed with.
+type project struct {
+	Module     string // the module path
+	Name       string // the name of the server, the last element of Module
+	SDKVersion string
+}
+
+// released reports whether v is the version of a published SDK, which
+// projects can require, rather than e.g. one with local modifications.
+func released(v string) bool {
+	return strings.HasPrefix(v, "v") && !strings.Contains(v, "+")
+}
+
+// scaffold creates the files of p in dir, which must not exist or be empty.
+func scaffold(dir string, p project) error {
+	entries, err := ioutil.ReadDir(dir)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	case len(entries) > 0:
+		return fmt.Errorf("%s is not empty", dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	funcs := template.FuncMap{"released": released}
+	for _, f := range projectFiles {
+		t, err := template.New(f.name).Funcs(funcs).Parse(f.content)
+		if err != nil {
+			return err
+		}
+		var b bytes.Buffer
+		if err := t.Execute(&b, p); err != nil {
+			return err
+		}
+
+		content := b.Bytes()
+		if strings.HasSuffix(f.name, ".go") {
+			if content, err = format.Source(content); err != nil {
+				return fmt.Errorf("%s: %v", f.name, err)
+			}
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, f.name), content, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// projectFiles are the templates of the files of new projects.
+var projectFiles = []struct {
+	name    string
+	content string
+}{
+	{"go.mod", `module {{.Module}}
+
+go 1.13
+{{if released .SDKVersion}}
+require github.com/goodgophers/golsp-sdk {{.SDKVersion}}
+{{end -}}
+`},
+	{"main.go", `// Command {{.Name}} is a language server, talking to clients over its
+// standard input and output.
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/goodgophers/golsp-sdk/textedit"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/sourcegraph/go-lsp"
+)
+
+func main() {
+	newServer(context.Background()).StartStdio()
+}
+
+// newServer returns the language server. It keeps the content of the open
+// documents, and shows the word under the cursor on hover.
+func newServer(ctx context.Context) *server.Server {
+	s := server.NewServer(ctx)
+	full := lsp.TDSKFull
+	s.Capabilities().TextDocumentSync = &lsp.TextDocumentSyncOptionsOrKind{Kind: &full}
+	s.Capabilities().HoverProvider = true
+
+	d := &documents{content: make(map[lsp.DocumentURI]string)}
+	s.On("textDocument/didOpen", d.didOpen)
+	s.On("textDocument/didChange", d.didChange)
+	s.On("textDocument/didClose", d.didClose)
+	s.On("textDocument/hover", d.hover)
+	return s
+}
+
+// documents holds the content of the open documents.
+type documents struct {
+	mu      sync.Mutex
+	content map[lsp.DocumentURI]string
+}
+
+func (d *documents) didOpen(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	var p lsp.DidOpenTextDocumentParams
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.content[p.TextDocument.URI] = p.TextDocument.Text
+	return nil, nil
+}
+
+func (d *documents) didChange(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	var p lsp.DidChangeTextDocumentParams
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Documents are synchronised in full, so the last change holds the
+	// whole content.
+	if len(p.ContentChanges) > 0 {
+		d.content[p.TextDocument.URI] = p.ContentChanges[len(p.ContentChanges)-1].Text
+	}
+	return nil, nil
+}
+
+func (d *documents) didClose(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	var p lsp.DidCloseTextDocumentParams
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.content, p.TextDocument.URI)
+	return nil, nil
+}
+
+func (d *documents) hover(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	var p lsp.TextDocumentPositionParams
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	content, ok := d.content[p.TextDocument.URI]
+	d.mu.Unlock()
+	if !ok {
+		return nil, nil
+	}
+
+	m := textedit.NewMapper(content)
+	offset, err := m.Offset(p.Position)
+	if err != nil {
+		return nil, err
+	}
+	start := strings.LastIndexFunc(content[:offset], notWord) + 1
+	end := strings.IndexFunc(content[offset:], notWord)
+	if end < 0 {
+		end = len(content)
+	} else {
+		end += offset
+	}
+	if start == end {
+		return nil, nil
+	}
+
+	rng, err := m.Range(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return lsp.Hover{Contents: []lsp.MarkedString{lsp.RawMarkedString(content[start:end])}, Range: &rng}, nil
+}
+
+func notWord(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+}
+`},
+	{"main_test.go", `package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goodgophers/golsp-sdk/lsptest"
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/sourcegraph/go-lsp"
+)
+
+func TestHover(t *testing.T) {
+	ctx := context.Background()
+	c := lsptest.New(newServer(ctx))
+	defer c.Close()
+
+	if _, err := c.Initialize(ctx, lsptest.InitializeParams{}); err != nil {
+		t.Fatal(err)
+	}
+	u := uri.File("/hello.txt")
+	if err := c.OpenDocument(ctx, u, "plaintext", "hello, world"); err != nil {
+		t.Fatal(err)
+	}
+
+	hover, err := c.Hover(ctx, u, lsp.Position{Line: 0, Character: 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hover == nil || len(hover.Contents) != 1 || hover.Contents[0].Value != "world" {
+		t.Errorf("expected world on hover, got %+v", hover)
+	}
+}
+`},
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// modulePath is the path of the SDK module.
+const modulePath = "github.com/goodgophers/golsp-sdk"
+
+// version is the version of the SDK, set at link time with
+// -ldflags "-X main.version=v1.2.3". It defaults to the version of the SDK
+// module golsp-sdk was built from, e.g. when installed with go install.
+var version = ""
+
+var versionCommand = &command{
+	name:  "version",
+	short: "print the version of the SDK",
+	run: func(ctx context.Context, e *env, flags interface{}, args []string) error {
+		if len(args) > 0 {
+			return errUsage
+		}
+		fmt.Fprintf(e.stdout, "golsp-sdk %s %s %s/%s\n", sdkVersion(), runtime.Version(), runtime.GOOS, runtime.GOARCH)
+		return nil
+	},
+}
+
+// sdkVersion returns the version of the SDK, or "devel" when built from a
+// source tree.
+func sdkVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Path == modulePath && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			return info.Main.Version
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				return dep.Version
+			}
+		}
+	}
+	return "devel"
+}
@@ -0,0 +1,84 @@
+// Package codeaction implements textDocument/codeAction and codeAction/resolve
+// on top of the server package.
+//
+// Code actions are produced by providers added to a Registry, each declaring
+// the kinds of actions it produces. The Registry only queries providers
+// matching the kinds requested by the client, links quick fixes to the
+// diagnostics they fix, and routes resolve requests back to the provider which
+// produced the action, so that expensive edits can be computed lazily.
+package codeaction
+
+import (
+	"strings"
+
+	"github.com/intel-go/fastjson"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// TriggerKind tells why code actions were requested.
+type TriggerKind int
+
+const (
+	// TKInvoked is used when code actions were explicitly requested by the
+	// user.
+	TKInvoked TriggerKind = 1
+	// TKAutomatic is used when code actions were requested automatically, for
+	// instance after a change to the document.
+	TKAutomatic TriggerKind = 2
+)
+
+// Context carries additional information about the request.
+//
+// It extends lsp.CodeActionContext with fields introduced by later versions of
+// the LSP specification.
+type Context struct {
+	Diagnostics []lsp.Diagnostic     `json:"diagnostics"`
+	Only        []lsp.CodeActionKind `json:"only,omitempty"`
+	TriggerKind TriggerKind          `json:"triggerKind,omitempty"`
+}
+
+// Params are the parameters of a textDocument/codeAction request.
+type Params struct {
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+	Range        lsp.Range                  `json:"range"`
+	Context      Context                    `json:"context"`
+}
+
+// Disabled explains why an action cannot currently be applied.
+type Disabled struct {
+	Reason string `json:"reason"`
+}
+
+// CodeAction is a change that can be performed in code, e.g. to fix a problem
+// or to refactor code.
+type CodeAction struct {
+	Title       string             `json:"title"`
+	Kind        lsp.CodeActionKind `json:"kind,omitempty"`
+	Diagnostics []lsp.Diagnostic   `json:"diagnostics,omitempty"`
+	IsPreferred bool               `json:"isPreferred,omitempty"`
+	Disabled    *Disabled          `json:"disabled,omitempty"`
+	Edit        *lsp.WorkspaceEdit `json:"edit,omitempty"`
+	Command     *lsp.Command       `json:"command,omitempty"`
+	Data        interface{}        `json:"data,omitempty"`
+}
+
+// DecodeData decodes the data attached to the action into v.
+//
+// Actions received in codeAction/resolve requests carry their data as decoded
+// from JSON, so providers use DecodeData to get it back into the type they
+// originally attached.
+func (a *CodeAction) DecodeData(v interface{}) error {
+	data, err := fastjson.Marshal(a.Data)
+	if err != nil {
+		return err
+	}
+
+	return fastjson.Unmarshal(data, v)
+}
+
+// KindMatches reports whether kind is requested when a client asks for
+// requested actions. Kinds are hierarchical: requesting lsp.CAKRefactor
+// matches lsp.CAKRefactorExtract, and the empty kind matches every kind.
+func KindMatches(kind, requested lsp.CodeActionKind) bool {
+	return requested == lsp.CAKEmpty || kind == requested || strings.HasPrefix(string(kind), string(requested)+".")
+}
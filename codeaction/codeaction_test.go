@@ -0,0 +1,183 @@
+package codeaction
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+	jsonRPCClient "github.com/ybbus/jsonrpc"
+)
+
+var unusedVariable = lsp.Diagnostic{Message: "x declared but not used", Code: "unused"}
+
+type testProvider struct{}
+
+func (testProvider) Kinds() []lsp.CodeActionKind {
+	return []lsp.CodeActionKind{lsp.CAKQuickFix, lsp.CAKRefactorExtract}
+}
+
+func (testProvider) CodeActions(ctx context.Context, params *Params) ([]CodeAction, error) {
+	return []CodeAction{
+		{Title: "Extract function", Kind: lsp.CAKRefactorExtract, Data: "extract"},
+		{Title: "Not ready", Kind: lsp.CAKRefactorExtract, Disabled: &Disabled{Reason: "no selection"}},
+	}, nil
+}
+
+func (testProvider) Fix(ctx context.Context, params *Params, diagnostic lsp.Diagnostic) ([]CodeAction, error) {
+	return []CodeAction{{Title: "Remove variable", Data: "remove"}}, nil
+}
+
+func (testProvider) Resolve(ctx context.Context, action CodeAction) (CodeAction, error) {
+	var data string
+	if err := action.DecodeData(&data); err != nil {
+		return action, err
+	}
+	action.Edit = &lsp.WorkspaceEdit{Changes: map[string][]lsp.TextEdit{
+		"file:///main.go": {{NewText: data}},
+	}}
+	return action, nil
+}
+
+type organizeImports struct{}
+
+func (organizeImports) Kinds() []lsp.CodeActionKind {
+	return []lsp.CodeActionKind{lsp.CAKSourceOrganizeImports}
+}
+
+func (organizeImports) CodeActions(ctx context.Context, params *Params) ([]CodeAction, error) {
+	return []CodeAction{{
+		Title:   "Organize imports",
+		Kind:    lsp.CAKSourceOrganizeImports,
+		Command: &lsp.Command{Title: "Organize imports", Command: "organizeImports"},
+	}}, nil
+}
+
+func TestKindMatches(t *testing.T) {
+	assert.True(t, KindMatches(lsp.CAKRefactorExtract, lsp.CAKRefactor))
+	assert.True(t, KindMatches(lsp.CAKRefactor, lsp.CAKRefactor))
+	assert.True(t, KindMatches(lsp.CAKQuickFix, lsp.CAKEmpty))
+	assert.False(t, KindMatches(lsp.CAKRefactor, lsp.CAKRefactorExtract))
+	assert.False(t, KindMatches("sourcefoo", lsp.CAKSource))
+}
+
+func TestRegistry(t *testing.T) {
+	fullSupport := map[string]interface{}{
+		"codeActionLiteralSupport": map[string]interface{}{},
+		"disabledSupport":          true,
+		"resolveSupport":           map[string]interface{}{"properties": []string{"edit"}},
+	}
+	edit := func(text string) map[string]interface{} {
+		return map[string]interface{}{"changes": map[string]interface{}{
+			"file:///main.go": []interface{}{map[string]interface{}{
+				"range":   map[string]interface{}{"start": map[string]interface{}{"line": float64(0), "character": float64(0)}, "end": map[string]interface{}{"line": float64(0), "character": float64(0)}},
+				"newText": text,
+			}},
+		}}
+	}
+
+	tests := []struct {
+		Name             string
+		ClientCodeAction map[string]interface{}
+		Only             []lsp.CodeActionKind
+		ExpectedResponse []interface{}
+	}{
+		{
+			"when the client supports every feature",
+			fullSupport,
+			nil,
+			[]interface{}{
+				map[string]interface{}{"title": "Extract function", "kind": "refactor.extract", "data": map[string]interface{}{"provider": "test", "data": "extract"}},
+				map[string]interface{}{"title": "Not ready", "kind": "refactor.extract", "disabled": map[string]interface{}{"reason": "no selection"}, "data": map[string]interface{}{"provider": "test"}},
+				map[string]interface{}{
+					"title":       "Remove variable",
+					"kind":        "quickfix",
+					"diagnostics": []interface{}{map[string]interface{}{"range": map[string]interface{}{"start": map[string]interface{}{"line": float64(0), "character": float64(0)}, "end": map[string]interface{}{"line": float64(0), "character": float64(0)}}, "code": "unused", "message": "x declared but not used"}},
+					"data":        map[string]interface{}{"provider": "test", "data": "remove"},
+				},
+				map[string]interface{}{"title": "Organize imports", "kind": "source.organizeImports", "command": map[string]interface{}{"title": "Organize imports", "command": "organizeImports", "arguments": nil}},
+			},
+		},
+		{
+			"when the client only requests refactorings",
+			fullSupport,
+			[]lsp.CodeActionKind{lsp.CAKRefactor},
+			[]interface{}{
+				map[string]interface{}{"title": "Extract function", "kind": "refactor.extract", "data": map[string]interface{}{"provider": "test", "data": "extract"}},
+				map[string]interface{}{"title": "Not ready", "kind": "refactor.extract", "disabled": map[string]interface{}{"reason": "no selection"}, "data": map[string]interface{}{"provider": "test"}},
+			},
+		},
+		{
+			"when the client doesn't support lazy resolution nor disabled actions",
+			map[string]interface{}{"codeActionLiteralSupport": map[string]interface{}{}},
+			[]lsp.CodeActionKind{lsp.CAKRefactorExtract},
+			[]interface{}{
+				map[string]interface{}{"title": "Extract function", "kind": "refactor.extract", "data": "extract", "edit": edit("extract")},
+			},
+		},
+		{
+			"when the client doesn't support code action literals",
+			nil,
+			nil,
+			[]interface{}{
+				map[string]interface{}{"title": "Organize imports", "command": "organizeImports", "arguments": nil},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			s := server.NewServer(context.Background())
+			r := NewRegistry()
+			r.Add("test", testProvider{})
+			r.Add("imports", organizeImports{})
+			r.Register(s)
+
+			ts := httptest.NewServer(s)
+			defer ts.Close()
+			rpcClient := jsonRPCClient.NewClient(ts.URL)
+
+			_, err := rpcClient.Call("initialize", map[string]interface{}{
+				"capabilities": map[string]interface{}{
+					"textDocument": map[string]interface{}{"codeAction": tc.ClientCodeAction},
+				},
+			})
+			assert.NoError(t, err)
+
+			var result []interface{}
+			rpcErr := rpcClient.CallFor(&result, "textDocument/codeAction", Params{
+				TextDocument: lsp.TextDocumentIdentifier{URI: "file:///main.go"},
+				Context:      Context{Diagnostics: []lsp.Diagnostic{unusedVariable}, Only: tc.Only},
+			})
+
+			assert.NoError(t, rpcErr)
+			assert.Equal(t, tc.ExpectedResponse, result)
+		})
+	}
+}
+
+func TestRegistryResolve(t *testing.T) {
+	s := server.NewServer(context.Background())
+	r := NewRegistry()
+	r.Add("test", testProvider{})
+	r.Register(s)
+
+	options := s.Capabilities().CodeActionProvider.(*server.CodeActionOptions)
+	assert.Equal(t, []lsp.CodeActionKind{lsp.CAKQuickFix, lsp.CAKRefactorExtract}, options.CodeActionKinds)
+	assert.True(t, options.ResolveProvider)
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	rpcClient := jsonRPCClient.NewClient(ts.URL)
+
+	var result CodeAction
+	rpcErr := rpcClient.CallFor(&result, "codeAction/resolve", map[string]interface{}{
+		"title": "Remove variable",
+		"data":  map[string]interface{}{"provider": "test", "data": "remove"},
+	})
+
+	assert.NoError(t, rpcErr)
+	assert.Equal(t, "remove", result.Edit.Changes["file:///main.go"][0].NewText)
+}
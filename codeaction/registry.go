@@ -0,0 +1,265 @@
+package codeaction
+
+import (
+	"context"
+
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// Provider produces code actions.
+type Provider interface {
+	// Kinds returns the kinds of the actions the provider produces. The
+	// provider is only queried when the client requests one of these kinds,
+	// or one of their parents or children.
+	Kinds() []lsp.CodeActionKind
+
+	// CodeActions returns the actions available for the range of params.
+	CodeActions(ctx context.Context, params *Params) ([]CodeAction, error)
+}
+
+// Fixer is implemented by providers fixing diagnostics. Fix is called for each
+// diagnostic of the request, and the returned actions are linked to it. They
+// default to the lsp.CAKQuickFix kind.
+type Fixer interface {
+	Fix(ctx context.Context, params *Params, diagnostic lsp.Diagnostic) ([]CodeAction, error)
+}
+
+// Resolver is implemented by providers which compute some fields of their
+// actions lazily, in response to codeAction/resolve requests.
+type Resolver interface {
+	// Resolve returns action with its lazy fields, typically Edit, filled in.
+	// The action's data is the one set when it was produced.
+	Resolve(ctx context.Context, action CodeAction) (CodeAction, error)
+}
+
+// resolveData wraps the data of actions produced by a Resolver, to route
+// codeAction/resolve requests back to it.
+type resolveData struct {
+	Provider string      `json:"provider"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+type namedProvider struct {
+	name string
+	Provider
+}
+
+// Registry dispatches code action requests to its providers.
+type Registry struct {
+	providers []namedProvider
+	server    *server.Server
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Add adds a provider to r. Its name identifies the provider in the data of
+// the actions it produces, and must be unique. Providers are queried in the
+// order they are added.
+func (r *Registry) Add(name string, p Provider) {
+	r.providers = append(r.providers, namedProvider{name: name, Provider: p})
+}
+
+// Register registers the textDocument/codeAction and codeAction/resolve
+// callbacks on s, and declares the code action capability along with the
+// kinds of actions produced by the providers. Providers must be added before
+// calling Register.
+func (r *Registry) Register(s *server.Server) {
+	r.server = s
+
+	options := &server.CodeActionOptions{}
+	seen := make(map[lsp.CodeActionKind]bool)
+	for _, p := range r.providers {
+		for _, kind := range p.Kinds() {
+			if !seen[kind] {
+				seen[kind] = true
+				options.CodeActionKinds = append(options.CodeActionKinds, kind)
+			}
+		}
+		if _, ok := p.Provider.(Resolver); ok {
+			options.ResolveProvider = true
+		}
+	}
+
+	s.Capabilities().CodeActionProvider = options
+	s.On("textDocument/codeAction", r.codeAction)
+	if options.ResolveProvider {
+		s.On("codeAction/resolve", r.resolve)
+	}
+}
+
+func (r *Registry) codeAction(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	var p Params
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	actions := []CodeAction{}
+	for _, provider := range r.providers {
+		if !r.requested(p.Context.Only, provider.Kinds()) {
+			continue
+		}
+
+		produced, err := provider.CodeActions(ctx, &p)
+		if err != nil {
+			return nil, err
+		}
+		if fixer, ok := provider.Provider.(Fixer); ok {
+			for _, d := range p.Context.Diagnostics {
+				fixes, err := fixer.Fix(ctx, &p, d)
+				if err != nil {
+					return nil, err
+				}
+				for _, fix := range fixes {
+					if fix.Kind == lsp.CAKEmpty {
+						fix.Kind = lsp.CAKQuickFix
+					}
+					fix.Diagnostics = []lsp.Diagnostic{d}
+					produced = append(produced, fix)
+				}
+			}
+		}
+
+		for _, action := range produced {
+			if !kindRequested(p.Context.Only, action.Kind) {
+				continue
+			}
+			if action.Disabled != nil && !r.clientSupports("textDocument.codeAction.disabledSupport") {
+				continue
+			}
+
+			action, err = r.prepare(ctx, provider, action)
+			if err != nil {
+				return nil, err
+			}
+			actions = append(actions, action)
+		}
+	}
+
+	if !r.clientSupports("textDocument.codeAction.codeActionLiteralSupport") {
+		return commands(actions), nil
+	}
+	return actions, nil
+}
+
+// prepare readies an action produced by provider for the client: resolve data
+// is wrapped when the client supports lazy resolution of edits, and the action
+// is resolved right away otherwise.
+func (r *Registry) prepare(ctx context.Context, provider namedProvider, action CodeAction) (CodeAction, error) {
+	resolver, ok := provider.Provider.(Resolver)
+	if !ok {
+		return action, nil
+	}
+
+	if r.clientResolves("edit") {
+		action.Data = resolveData{Provider: provider.name, Data: action.Data}
+		return action, nil
+	}
+
+	return resolver.Resolve(ctx, action)
+}
+
+func (r *Registry) resolve(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	var action CodeAction
+	if err := jsonrpc.Unmarshal(params, &action); err != nil {
+		return nil, err
+	}
+	var data struct {
+		Data *resolveData `json:"data"`
+	}
+	if err := jsonrpc.Unmarshal(params, &data); err != nil || data.Data == nil {
+		// Not one of ours: nothing to resolve.
+		return action, nil
+	}
+
+	resolver, ok := r.provider(data.Data.Provider).(Resolver)
+	if !ok {
+		return action, nil
+	}
+
+	action.Data = data.Data.Data
+	resolved, err := resolver.Resolve(ctx, action)
+	if err != nil {
+		return nil, err
+	}
+	resolved.Data = resolveData{Provider: data.Data.Provider, Data: resolved.Data}
+
+	return resolved, nil
+}
+
+// requested reports whether any of kinds was requested by the client, only
+// being the kinds it asked for. Parents of requested kinds match as well since
+// providers may produce more specific kinds than the ones they declare.
+func (r *Registry) requested(only, kinds []lsp.CodeActionKind) bool {
+	if len(only) == 0 {
+		return true
+	}
+	for _, kind := range kinds {
+		for _, requested := range only {
+			if KindMatches(kind, requested) || (kind != lsp.CAKEmpty && KindMatches(requested, kind)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// kindRequested reports whether an action of the given kind was requested by
+// the client.
+func kindRequested(only []lsp.CodeActionKind, kind lsp.CodeActionKind) bool {
+	if len(only) == 0 {
+		return true
+	}
+	for _, requested := range only {
+		if KindMatches(kind, requested) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Registry) provider(name string) Provider {
+	for _, p := range r.providers {
+		if p.name == name {
+			return p.Provider
+		}
+	}
+	return nil
+}
+
+func (r *Registry) clientSupports(path string) bool {
+	return r.server != nil && r.server.ClientSupports(path)
+}
+
+// clientResolves reports whether the client supports resolving the named
+// property of code actions lazily.
+func (r *Registry) clientResolves(property string) bool {
+	if r.server == nil {
+		return false
+	}
+	value, _ := r.server.ClientCapability("textDocument.codeAction.resolveSupport.properties")
+	properties, _ := value.([]interface{})
+	for _, p := range properties {
+		if p == property {
+			return true
+		}
+	}
+	return false
+}
+
+// commands converts actions to the commands returned to clients which do not
+// support code action literals. Actions without a command are dropped.
+func commands(actions []CodeAction) []lsp.Command {
+	cmds := []lsp.Command{}
+	for _, a := range actions {
+		if a.Command != nil && a.Edit == nil {
+			cmds = append(cmds, *a.Command)
+		}
+	}
+	return cmds
+}
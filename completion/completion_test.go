@@ -0,0 +1,172 @@
+package completion
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/intel-go/fastjson"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+)
+
+type testProvider struct {
+	list *List
+	err  error
+}
+
+func (p testProvider) Complete(ctx context.Context, params *lsp.CompletionParams) (*List, error) {
+	return p.list, p.err
+}
+
+type testResolver struct {
+	testProvider
+}
+
+func (p testResolver) Resolve(ctx context.Context, item Item) (Item, error) {
+	var data map[string]string
+	if err := item.DecodeData(&data); err != nil {
+		return item, err
+	}
+	item.Documentation = "docs for " + data["symbol"]
+	return item, nil
+}
+
+func TestSnippet(t *testing.T) {
+	var s Snippet
+	s.WriteText("Printf(").WritePlaceholder("format ${x}").WriteText(", ").WriteTabStop().WriteText(")").WriteFinalTabStop()
+	assert.Equal(t, `Printf(${1:format \${x\}}, $2)$0`, s.String())
+
+	var c Snippet
+	c.WriteChoice("a,b", "c|d", `e\f`)
+	assert.Equal(t, `${1|a\,b,c\|d,e\\f|}`, c.String())
+}
+
+func TestRank(t *testing.T) {
+	items := []Item{
+		{CompletionItem: lsp.CompletionItem{Label: "Sprintf"}},
+		{CompletionItem: lsp.CompletionItem{Label: "println"}},
+		{CompletionItem: lsp.CompletionItem{Label: "Println"}},
+		{CompletionItem: lsp.CompletionItem{Label: "Errorf"}},
+		{CompletionItem: lsp.CompletionItem{Label: "Print", FilterText: "zzz"}},
+	}
+
+	ranked := Rank("Pr", items)
+
+	var labels, sortTexts []string
+	for _, item := range ranked {
+		labels = append(labels, item.Label)
+		sortTexts = append(sortTexts, item.SortText)
+	}
+	assert.Equal(t, []string{"Println", "println", "Sprintf"}, labels)
+	assert.Equal(t, []string{"00000", "00001", "00002"}, sortTexts)
+}
+
+func TestWordPrefix(t *testing.T) {
+	prefix, r, err := WordPrefix("fmt.Prin(x)", lsp.Position{Line: 0, Character: 8})
+	assert.NoError(t, err)
+	assert.Equal(t, "Prin", prefix)
+	assert.Equal(t, lsp.Range{Start: lsp.Position{Character: 4}, End: lsp.Position{Character: 8}}, r)
+
+	prefix, _, err = WordPrefix("x := héllo", lsp.Position{Line: 0, Character: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, "héllo", prefix)
+}
+
+func TestRouter(t *testing.T) {
+	editRange := &lsp.Range{Start: lsp.Position{Character: 4}, End: lsp.Position{Character: 8}}
+
+	tests := []struct {
+		Name          string
+		Providers     map[string]Provider
+		ExpectedList  *List
+		ExpectedError error
+	}{
+		{
+			"when no provider returns items",
+			map[string]Provider{"empty": testProvider{}},
+			&List{Items: []Item{}},
+			nil,
+		},
+		{
+			"when the client doesn't support item defaults",
+			map[string]Provider{
+				"defaults": testProvider{list: &List{
+					IsIncomplete: true,
+					ItemDefaults: &ItemDefaults{EditRange: editRange, CommitCharacters: []string{"."}},
+					Items:        []Item{{CompletionItem: lsp.CompletionItem{Label: "Println"}}},
+				}},
+			},
+			&List{
+				IsIncomplete: true,
+				Items: []Item{{
+					CompletionItem:   lsp.CompletionItem{Label: "Println", TextEdit: &lsp.TextEdit{Range: *editRange, NewText: "Println"}},
+					CommitCharacters: []string{"."},
+				}},
+			},
+			nil,
+		},
+		{
+			"when the provider resolves its items",
+			map[string]Provider{
+				"resolver": testResolver{testProvider{list: &List{
+					ItemDefaults: &ItemDefaults{Data: map[string]string{"symbol": "fmt.Println"}},
+					Items:        []Item{{CompletionItem: lsp.CompletionItem{Label: "Println"}}},
+				}}},
+			},
+			&List{
+				Items: []Item{{CompletionItem: lsp.CompletionItem{
+					Label: "Println",
+					Data:  resolveData{Provider: "resolver", Data: map[string]string{"symbol": "fmt.Println"}},
+				}}},
+			},
+			nil,
+		},
+		{
+			"when a provider fails",
+			map[string]Provider{"failing": testProvider{err: errors.New("test error")}},
+			nil,
+			errors.New("test error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := NewRouter(".")
+			for name, p := range tc.Providers {
+				r.Add(name, p)
+			}
+			r.Register(server.NewServer(context.Background()))
+
+			params := fastjson.RawMessage(`{"textDocument":{"uri":"file:///main.go"},"position":{"line":0,"character":8}}`)
+			result, err := r.complete(context.Background(), &params)
+
+			assert.Equal(t, tc.ExpectedError, err)
+			if tc.ExpectedList != nil {
+				assert.Equal(t, tc.ExpectedList, result)
+			}
+		})
+	}
+}
+
+func TestRouterResolve(t *testing.T) {
+	r := NewRouter()
+	r.Add("plain", testProvider{})
+	r.Add("resolver", testResolver{})
+	s := server.NewServer(context.Background())
+	r.Register(s)
+
+	assert.True(t, s.Capabilities().CompletionProvider.ResolveProvider)
+
+	params := fastjson.RawMessage(`{"label":"Println","data":{"provider":"resolver","data":{"symbol":"fmt.Println"}}}`)
+	result, err := r.resolve(context.Background(), &params)
+	assert.NoError(t, err)
+	assert.Equal(t, "docs for fmt.Println", result.(Item).Documentation)
+	assert.Equal(t, "resolver", result.(Item).Data.(resolveData).Provider)
+
+	params = fastjson.RawMessage(`{"label":"Println","data":42}`)
+	result, err = r.resolve(context.Background(), &params)
+	assert.NoError(t, err)
+	assert.Equal(t, "", result.(Item).Documentation)
+}
@@ -0,0 +1,132 @@
+// Package completion implements textDocument/completion and
+// completionItem/resolve on top of the server package.
+//
+// Completion items are produced by providers added to a Router. The Router
+// merges their lists, ranks and filters items, and routes resolve requests
+// back to the provider which produced the item, so that expensive fields such
+// as documentation can be computed lazily.
+package completion
+
+import (
+	"github.com/intel-go/fastjson"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// InsertTextMode defines how whitespace and indentation is handled when a
+// completion item is inserted.
+type InsertTextMode int
+
+const (
+	// ITMAsIs inserts the text as is.
+	ITMAsIs InsertTextMode = 1
+	// ITMAdjustIndentation adjusts the indentation of the inserted lines to the
+	// indentation of the line the cursor is on.
+	ITMAdjustIndentation InsertTextMode = 2
+)
+
+// Item is a completion item.
+//
+// It extends lsp.CompletionItem with fields introduced by later versions of
+// the LSP specification.
+type Item struct {
+	lsp.CompletionItem
+	AdditionalTextEdits []lsp.TextEdit `json:"additionalTextEdits,omitempty"`
+	CommitCharacters    []string       `json:"commitCharacters,omitempty"`
+	Preselect           bool           `json:"preselect,omitempty"`
+	InsertTextMode      InsertTextMode `json:"insertTextMode,omitempty"`
+}
+
+// DecodeData decodes the data attached to the item into v.
+//
+// Items received in completionItem/resolve requests carry their data as
+// decoded from JSON, so providers use DecodeData to get it back into the type
+// they originally attached.
+func (i *Item) DecodeData(v interface{}) error {
+	data, err := fastjson.Marshal(i.Data)
+	if err != nil {
+		return err
+	}
+
+	return fastjson.Unmarshal(data, v)
+}
+
+// ItemDefaults holds values applying to every item of a List which does not
+// set them itself. Defaults were introduced in version 3.17 of the LSP
+// specification.
+type ItemDefaults struct {
+	CommitCharacters []string             `json:"commitCharacters,omitempty"`
+	EditRange        *lsp.Range           `json:"editRange,omitempty"`
+	InsertTextFormat lsp.InsertTextFormat `json:"insertTextFormat,omitempty"`
+	InsertTextMode   InsertTextMode       `json:"insertTextMode,omitempty"`
+	Data             interface{}          `json:"data,omitempty"`
+}
+
+// List is the result of a textDocument/completion request.
+//
+// A list is incomplete when typing further characters should trigger a new
+// request rather than filtering the items client side.
+type List struct {
+	IsIncomplete bool          `json:"isIncomplete"`
+	ItemDefaults *ItemDefaults `json:"itemDefaults,omitempty"`
+	Items        []Item        `json:"items"`
+}
+
+// NewList returns a complete list holding items.
+func NewList(items ...Item) *List {
+	if items == nil {
+		items = []Item{}
+	}
+	return &List{Items: items}
+}
+
+// expandDefaults copies the item defaults of l onto its items, for the
+// defaults which keep reports false. Expanded defaults are removed from the
+// list.
+func (l *List) expandDefaults(keep func(name string) bool) {
+	d := l.ItemDefaults
+	if d == nil {
+		return
+	}
+
+	for i := range l.Items {
+		item := &l.Items[i]
+		if d.CommitCharacters != nil && !keep("commitCharacters") && item.CommitCharacters == nil {
+			item.CommitCharacters = d.CommitCharacters
+		}
+		if d.EditRange != nil && !keep("editRange") && item.TextEdit == nil {
+			text := item.InsertText
+			if text == "" {
+				text = item.Label
+			}
+			item.TextEdit = &lsp.TextEdit{Range: *d.EditRange, NewText: text}
+		}
+		if d.InsertTextFormat != 0 && !keep("insertTextFormat") && item.InsertTextFormat == 0 {
+			item.InsertTextFormat = d.InsertTextFormat
+		}
+		if d.InsertTextMode != 0 && !keep("insertTextMode") && item.InsertTextMode == 0 {
+			item.InsertTextMode = d.InsertTextMode
+		}
+		if d.Data != nil && !keep("data") && item.Data == nil {
+			item.Data = d.Data
+		}
+	}
+
+	if !keep("commitCharacters") {
+		d.CommitCharacters = nil
+	}
+	if !keep("editRange") {
+		d.EditRange = nil
+	}
+	if !keep("insertTextFormat") {
+		d.InsertTextFormat = 0
+	}
+	if !keep("insertTextMode") {
+		d.InsertTextMode = 0
+	}
+	if !keep("data") {
+		d.Data = nil
+	}
+	if d.CommitCharacters == nil && d.EditRange == nil && d.InsertTextFormat == 0 && d.InsertTextMode == 0 && d.Data == nil {
+		l.ItemDefaults = nil
+	}
+}
@@ -0,0 +1,111 @@
+package completion
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/goodgophers/golsp-sdk/textedit"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// WordPrefix returns the identifier characters preceding pos in content, along
+// with their range. Clients filter completion items against this prefix, and
+// items usually replace it when inserted.
+func WordPrefix(content string, pos lsp.Position) (string, lsp.Range, error) {
+	m := textedit.NewMapper(content)
+	end, err := m.Offset(pos)
+	if err != nil {
+		return "", lsp.Range{}, err
+	}
+
+	start := end
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(content[:start])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			break
+		}
+		start -= size
+	}
+
+	r, err := m.Range(start, end)
+	if err != nil {
+		return "", lsp.Range{}, err
+	}
+	return content[start:end], r, nil
+}
+
+// Rank filters and sorts items the way clients expect for the given prefix.
+//
+// Items match when the prefix is a case-insensitive subsequence of their
+// FilterText, or Label when no filter text is set. Matches are ranked exact
+// prefix first, then case-insensitive prefix, then subsequence, ties being
+// broken by SortText and Label. SortText is rewritten so that clients keep
+// this order.
+func Rank(prefix string, items []Item) []Item {
+	type scored struct {
+		item  Item
+		score int
+	}
+
+	var matches []scored
+	for _, item := range items {
+		if score := matchScore(prefix, filterText(item)); score > 0 {
+			matches = append(matches, scored{item: item, score: score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return sortText(matches[i].item) < sortText(matches[j].item)
+	})
+
+	ranked := make([]Item, len(matches))
+	for i, m := range matches {
+		ranked[i] = m.item
+		ranked[i].SortText = fmt.Sprintf("%05d", i)
+	}
+	return ranked
+}
+
+// matchScore returns how well prefix matches text, or 0 if it doesn't.
+func matchScore(prefix, text string) int {
+	switch {
+	case strings.HasPrefix(text, prefix):
+		return 3
+	case strings.HasPrefix(strings.ToLower(text), strings.ToLower(prefix)):
+		return 2
+	case isSubsequence(strings.ToLower(prefix), strings.ToLower(text)):
+		return 1
+	}
+	return 0
+}
+
+func isSubsequence(sub, s string) bool {
+	for _, r := range sub {
+		i := strings.IndexRune(s, r)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(string(r)):]
+	}
+	return true
+}
+
+func filterText(item Item) string {
+	if item.FilterText != "" {
+		return item.FilterText
+	}
+	return item.Label
+}
+
+func sortText(item Item) string {
+	if item.SortText != "" {
+		return item.SortText
+	}
+	return item.Label
+}
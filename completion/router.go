@@ -0,0 +1,178 @@
+package completion
+
+import (
+	"context"
+
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// Provider produces completion items.
+type Provider interface {
+	// Complete returns the completion items at the position of params. A nil
+	// list contributes no items.
+	Complete(ctx context.Context, params *lsp.CompletionParams) (*List, error)
+}
+
+// Resolver is implemented by providers which compute some fields of their
+// items lazily, in response to completionItem/resolve requests.
+type Resolver interface {
+	// Resolve returns item with its lazy fields, typically Documentation or
+	// Detail, filled in. The item's data is the one set by Complete.
+	Resolve(ctx context.Context, item Item) (Item, error)
+}
+
+// resolveData wraps the data of items produced by a Resolver, to route
+// completionItem/resolve requests back to it.
+type resolveData struct {
+	Provider string      `json:"provider"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+type namedProvider struct {
+	name string
+	Provider
+}
+
+// Router dispatches completion requests to its providers and merges their
+// results.
+type Router struct {
+	providers         []namedProvider
+	triggerCharacters []string
+	server            *server.Server
+}
+
+// NewRouter returns a Router advertising triggerCharacters to clients, in
+// addition to identifier characters which always trigger completion.
+func NewRouter(triggerCharacters ...string) *Router {
+	return &Router{triggerCharacters: triggerCharacters}
+}
+
+// Add adds a provider to r. Its name identifies the provider in the data of
+// the items it produces, and must be unique. Providers are queried in the
+// order they are added.
+func (r *Router) Add(name string, p Provider) {
+	r.providers = append(r.providers, namedProvider{name: name, Provider: p})
+}
+
+// Register registers the textDocument/completion and completionItem/resolve
+// callbacks on s, and declares the completion capability. Providers must be
+// added before calling Register.
+func (r *Router) Register(s *server.Server) {
+	r.server = s
+
+	resolveProvider := false
+	for _, p := range r.providers {
+		if _, ok := p.Provider.(Resolver); ok {
+			resolveProvider = true
+		}
+	}
+
+	s.Capabilities().CompletionProvider = &lsp.CompletionOptions{
+		ResolveProvider:   resolveProvider,
+		TriggerCharacters: r.triggerCharacters,
+	}
+	s.On("textDocument/completion", r.complete)
+	if resolveProvider {
+		s.On("completionItem/resolve", r.resolve)
+	}
+}
+
+func (r *Router) complete(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	var completionParams lsp.CompletionParams
+	if err := jsonrpc.Unmarshal(params, &completionParams); err != nil {
+		return nil, err
+	}
+
+	var lists []*List
+	var names []string
+	for _, p := range r.providers {
+		l, err := p.Complete(ctx, &completionParams)
+		if err != nil {
+			return nil, err
+		}
+		if l != nil && (len(l.Items) > 0 || l.IsIncomplete) {
+			lists = append(lists, l)
+			names = append(names, p.name)
+		}
+	}
+
+	// Defaults can only be kept when a single provider contributed items, and
+	// when the client supports them. Data is always expanded since it is
+	// wrapped per item for resolve routing.
+	keep := func(name string) bool {
+		return len(lists) == 1 && name != "data" && r.clientSupportsDefault(name)
+	}
+
+	merged := NewList()
+	for i, l := range lists {
+		l.expandDefaults(keep)
+		merged.IsIncomplete = merged.IsIncomplete || l.IsIncomplete
+		merged.ItemDefaults = l.ItemDefaults
+
+		_, resolvable := r.provider(names[i]).(Resolver)
+		for _, item := range l.Items {
+			if resolvable {
+				item.Data = resolveData{Provider: names[i], Data: item.Data}
+			}
+			merged.Items = append(merged.Items, item)
+		}
+	}
+
+	return merged, nil
+}
+
+func (r *Router) resolve(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	var item Item
+	if err := jsonrpc.Unmarshal(params, &item); err != nil {
+		return nil, err
+	}
+	var data struct {
+		Data *resolveData `json:"data"`
+	}
+	if err := jsonrpc.Unmarshal(params, &data); err != nil || data.Data == nil {
+		// Not one of ours: nothing to resolve.
+		return item, nil
+	}
+
+	resolver, ok := r.provider(data.Data.Provider).(Resolver)
+	if !ok {
+		return item, nil
+	}
+
+	item.Data = data.Data.Data
+	resolved, err := resolver.Resolve(ctx, item)
+	if err != nil {
+		return nil, err
+	}
+	resolved.Data = resolveData{Provider: data.Data.Provider, Data: resolved.Data}
+
+	return resolved, nil
+}
+
+func (r *Router) provider(name string) Provider {
+	for _, p := range r.providers {
+		if p.name == name {
+			return p.Provider
+		}
+	}
+	return nil
+}
+
+// clientSupportsDefault reports whether the client supports the named item
+// default.
+func (r *Router) clientSupportsDefault(name string) bool {
+	if r.server == nil {
+		return false
+	}
+	supported, _ := r.server.ClientCapability("textDocument.completion.completionList.itemDefaults")
+	names, _ := supported.([]interface{})
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package completion
+
+import (
+	"strconv"
+	"strings"
+)
+
+var (
+	snippetEscaper = strings.NewReplacer(`\`, `\\`, `$`, `\$`, `}`, `\}`)
+	choiceEscaper  = strings.NewReplacer(`\`, `\\`, `,`, `\,`, `|`, `\|`)
+)
+
+// EscapeSnippet escapes the characters of s which have a special meaning in
+// the snippet syntax, so that s is inserted literally by the client.
+func EscapeSnippet(s string) string {
+	return snippetEscaper.Replace(s)
+}
+
+// Snippet builds the insert text of items whose InsertTextFormat is
+// lsp.ITFSnippet. Tab stops are numbered in the order they are written.
+//
+// The zero value is an empty snippet ready to use.
+type Snippet struct {
+	b       strings.Builder
+	tabStop int
+}
+
+// WriteText appends text, which is escaped to be inserted literally.
+func (s *Snippet) WriteText(text string) *Snippet {
+	s.b.WriteString(EscapeSnippet(text))
+	return s
+}
+
+// WriteTabStop appends an empty tab stop.
+func (s *Snippet) WriteTabStop() *Snippet {
+	s.b.WriteString("$" + s.nextTabStop())
+	return s
+}
+
+// WritePlaceholder appends a tab stop pre-filled with text.
+func (s *Snippet) WritePlaceholder(text string) *Snippet {
+	s.b.WriteString("${" + s.nextTabStop() + ":" + EscapeSnippet(text) + "}")
+	return s
+}
+
+// WriteChoice appends a tab stop letting the user pick one of choices.
+func (s *Snippet) WriteChoice(choices ...string) *Snippet {
+	escaped := make([]string, len(choices))
+	for i, c := range choices {
+		escaped[i] = choiceEscaper.Replace(c)
+	}
+	s.b.WriteString("${" + s.nextTabStop() + "|" + strings.Join(escaped, ",") + "|}")
+	return s
+}
+
+// WriteFinalTabStop appends the final tab stop, where the cursor lands once
+// every other tab stop has been visited.
+func (s *Snippet) WriteFinalTabStop() *Snippet {
+	s.b.WriteString("$0")
+	return s
+}
+
+// String returns the snippet in the LSP snippet syntax.
+func (s *Snippet) String() string {
+	return s.b.String()
+}
+
+func (s *Snippet) nextTabStop() string {
+	s.tabStop++
+	return strconv.Itoa(s.tabStop)
+}
@@ -0,0 +1,285 @@
+// Package config gives servers typed access to the settings of the client.
+//
+// Settings are pulled with workspace/configuration requests when the client
+// supports them, and read from the initialization options otherwise. They are
+// decoded into user-supplied structs, cached per scope, and refreshed when the
+// client sends workspace/didChangeConfiguration, in which case subscribers are
+// called with the old and new values.
+package config
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// Client fetches and tracks the settings of the client.
+type Client struct {
+	server *server.Server
+
+	mu       sync.Mutex
+	sections []*Section
+	settings interface{} // latest settings pushed by the client
+}
+
+// NewClient returns a Client for the settings of the clients of s.
+//
+// It registers the workspace/didChangeConfiguration callback on s, and
+// registers the notification with the client once initialized when the client
+// supports it, which is required by some clients to send it. Settings are
+// forgotten once the client is gone.
+func NewClient(s *server.Server) *Client {
+	c := &Client{server: s}
+
+	s.On("workspace/didChangeConfiguration", c.didChangeConfiguration)
+	s.OnInitialized(func(ctx context.Context) {
+		if !s.SupportsDynamicRegistration("workspace/didChangeConfiguration") {
+			return
+		}
+		if _, err := s.RegisterCapability(ctx, "workspace/didChangeConfiguration", nil); err != nil {
+			s.Logger().Errorf("[config] register didChangeConfiguration: %+v", err)
+		}
+	})
+	s.OnExit(c.reset)
+
+	return c
+}
+
+// reset forgets the settings of the client once it is gone, so that the next
+// client's are fetched afresh.
+func (c *Client) reset() {
+	c.mu.Lock()
+	c.settings = nil
+	sections := c.sections
+	c.mu.Unlock()
+
+	for _, s := range sections {
+		s.mu.Lock()
+		s.cache = make(map[uri.URI]interface{})
+		s.mu.Unlock()
+	}
+}
+
+// Section returns the settings of the named section, e.g. "go" or
+// "go.lint", decoded into values of the type of defaults.
+//
+// defaults must be a pointer to a struct holding the default settings, which
+// are overridden by the settings of the client.
+func (c *Client) Section(name string, defaults interface{}) *Section {
+	t := reflect.TypeOf(defaults)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		panic(fmt.Errorf("[config] section %s: defaults must be a pointer to a struct, got %T", name, defaults))
+	}
+	defaultsJSON, err := fastjson.Marshal(defaults)
+	if err != nil {
+		panic(fmt.Errorf("[config] section %s: %+v", name, err))
+	}
+
+	s := &Section{
+		client:   c,
+		name:     name,
+		typ:      t,
+		defaults: defaultsJSON,
+		cache:    make(map[uri.URI]interface{}),
+	}
+
+	c.mu.Lock()
+	c.sections = append(c.sections, s)
+	c.mu.Unlock()
+
+	return s
+}
+
+// pull reports whether the client supports workspace/configuration requests.
+func (c *Client) pull() bool {
+	return c.server.ClientSupports("workspace.configuration")
+}
+
+// pushed returns the settings of the named section last pushed by the client,
+// either through workspace/didChangeConfiguration or as initialization
+// options. Either way, settings hold those of every section: the empty
+// section stands for all of them, and missing sections have none.
+func (c *Client) pushed(section string) interface{} {
+	c.mu.Lock()
+	settings := c.settings
+	c.mu.Unlock()
+
+	if settings == nil {
+		p := c.server.InitializeParams()
+		if p == nil {
+			return nil
+		}
+		settings = p.InitializationOptions
+	}
+
+	if section == "" {
+		return settings
+	}
+	value, _ := lookup(settings, section)
+	return value
+}
+
+func (c *Client) didChangeConfiguration(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	var p lsp.DidChangeConfigurationParams
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if p.Settings != nil {
+		c.settings = p.Settings
+	}
+	sections := c.sections
+	c.mu.Unlock()
+
+	for _, s := range sections {
+		s.refresh(ctx)
+	}
+	return nil, nil
+}
+
+// lookup returns the value at the dotted path section of settings.
+func lookup(settings interface{}, section string) (interface{}, bool) {
+	value := settings
+	for _, key := range strings.Split(section, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// Section gives access to the settings of a section, for each scope.
+type Section struct {
+	client   *Client
+	name     string
+	typ      reflect.Type
+	defaults []byte
+
+	mu          sync.Mutex
+	cache       map[uri.URI]interface{}
+	subscribers []func(scope uri.URI, old, new interface{})
+}
+
+// Get decodes the settings of the section for scope into v, which must be a
+// pointer of the same type as the section's defaults. The empty scope stands
+// for the settings of the whole workspace, others are usually the URIs of
+// workspace folders or documents.
+//
+// Settings are fetched from the client the first time they are requested for
+// a scope, and cached until they change.
+func (s *Section) Get(ctx context.Context, scope uri.URI, v interface{}) error {
+	if reflect.TypeOf(v) != s.typ {
+		return fmt.Errorf("[config] section %s: cannot decode into %T, expected %s", s.name, v, s.typ)
+	}
+
+	s.mu.Lock()
+	value, ok := s.cache[scope]
+	s.mu.Unlock()
+
+	if !ok {
+		var err error
+		if value, err = s.fetch(ctx, scope); err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		s.cache[scope] = value
+		s.mu.Unlock()
+	}
+
+	reflect.ValueOf(v).Elem().Set(reflect.ValueOf(value).Elem())
+	return nil
+}
+
+// Subscribe registers fn to be called when the settings of a cached scope
+// change. old and new are pointers of the same type as the section's
+// defaults.
+func (s *Section) Subscribe(fn func(scope uri.URI, old, new interface{})) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// fetch returns the settings of the section for scope, as sent by the client.
+func (s *Section) fetch(ctx context.Context, scope uri.URI) (interface{}, error) {
+	if !s.client.pull() {
+		return s.decode(s.client.pushed(s.name))
+	}
+
+	params := lsp.ConfigurationParams{Items: []lsp.ConfigurationItem{{ScopeURI: string(scope), Section: s.name}}}
+	var result []interface{}
+	if err := s.client.server.Call(ctx, "workspace/configuration", params, &result); err != nil {
+		return nil, err
+	}
+	if len(result) != 1 {
+		return nil, fmt.Errorf("[config] section %s: expected 1 configuration, got %d", s.name, len(result))
+	}
+
+	return s.decode(result[0])
+}
+
+// decode decodes settings over the section's defaults.
+func (s *Section) decode(settings interface{}) (interface{}, error) {
+	v := reflect.New(s.typ.Elem()).Interface()
+	if err := fastjson.Unmarshal(s.defaults, v); err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		return v, nil
+	}
+
+	data, err := fastjson.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	if err := fastjson.Unmarshal(data, v); err != nil {
+		return nil, fmt.Errorf("[config] section %s: %+v", s.name, err)
+	}
+	return v, nil
+}
+
+// refresh fetches the settings of every cached scope again, and notifies
+// subscribers of those which changed.
+func (s *Section) refresh(ctx context.Context) {
+	s.mu.Lock()
+	scopes := make([]uri.URI, 0, len(s.cache))
+	for scope := range s.cache {
+		scopes = append(scopes, scope)
+	}
+	s.mu.Unlock()
+
+	for _, scope := range scopes {
+		value, err := s.fetch(ctx, scope)
+		if err != nil {
+			s.client.server.Logger().Errorf("[config] section %s: refresh %q: %+v", s.name, scope, err)
+			continue
+		}
+
+		s.mu.Lock()
+		old := s.cache[scope]
+		s.cache[scope] = value
+		subscribers := s.subscribers
+		s.mu.Unlock()
+
+		if reflect.DeepEqual(old, value) {
+			continue
+		}
+		for _, fn := range subscribers {
+			fn(scope, old, value)
+		}
+	}
+}
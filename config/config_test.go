@@ -0,0 +1,198 @@
+package config
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/intel-go/fastjson"
+	"github.com/stretchr/testify/assert"
+)
+
+type lintSettings struct {
+	Enabled bool     `json:"enabled"`
+	Linters []string `json:"linters"`
+	Timeout int      `json:"timeout"`
+}
+
+// testClient is a client connected to a server over an in-memory pipe.
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func newTestClient(t *testing.T, s *server.Server) *testClient {
+	clientConn, serverConn := net.Pipe()
+	go s.Serve(serverConn)
+
+	return &testClient{t: t, conn: clientConn, reader: bufio.NewReader(clientConn)}
+}
+
+func (c *testClient) send(msg map[string]interface{}) {
+	msg["jsonrpc"] = "2.0"
+	data, err := fastjson.Marshal(msg)
+	assert.NoError(c.t, err)
+	_, err = fmt.Fprintf(c.conn, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	assert.NoError(c.t, err)
+}
+
+func (c *testClient) receive() map[string]interface{} {
+	assert.NoError(c.t, c.conn.SetReadDeadline(time.Now().Add(time.Second)))
+
+	length := 0
+	for {
+		line, err := c.reader.ReadString('\n')
+		if !assert.NoError(c.t, err) {
+			c.t.FailNow()
+		}
+		if line == "\r\n" {
+			break
+		}
+		if strings.HasPrefix(line, "Content-Length: ") {
+			length, _ = strconv.Atoi(strings.TrimSpace(line[len("Content-Length: "):]))
+		}
+	}
+	data := make([]byte, length)
+	_, err := io.ReadFull(c.reader, data)
+	assert.NoError(c.t, err)
+
+	var msg map[string]interface{}
+	assert.NoError(c.t, fastjson.Unmarshal(data, &msg))
+	return msg
+}
+
+func (c *testClient) initialize(params map[string]interface{}) {
+	c.send(map[string]interface{}{"id": 0, "method": "initialize", "params": params})
+	assert.Contains(c.t, c.receive(), "result")
+	c.send(map[string]interface{}{"method": "initialized", "params": map[string]interface{}{}})
+}
+
+// answerConfiguration expects a workspace/configuration request and answers
+// it with settings.
+func (c *testClient) answerConfiguration(expectedScope string, settings interface{}) {
+	request := c.receive()
+	assert.Equal(c.t, "workspace/configuration", request["method"])
+	assert.Equal(c.t, map[string]interface{}{
+		"items": []interface{}{map[string]interface{}{"scopeUri": expectedScope, "section": "go.lint"}},
+	}, request["params"])
+	c.send(map[string]interface{}{"id": request["id"], "result": []interface{}{settings}})
+}
+
+func TestSectionPull(t *testing.T) {
+	s := server.NewServer(context.Background())
+	section := NewClient(s).Section("go.lint", &lintSettings{Enabled: true, Timeout: 30})
+
+	type change struct {
+		Scope    uri.URI
+		Old, New *lintSettings
+	}
+	changes := make(chan change, 1)
+	section.Subscribe(func(scope uri.URI, old, new interface{}) {
+		changes <- change{scope, old.(*lintSettings), new.(*lintSettings)}
+	})
+
+	c := newTestClient(t, s)
+	defer c.conn.Close()
+	c.initialize(map[string]interface{}{
+		"capabilities": map[string]interface{}{"workspace": map[string]interface{}{"configuration": true}},
+	})
+
+	got := make(chan lintSettings)
+	get := func() {
+		var settings lintSettings
+		assert.NoError(t, section.Get(context.Background(), "file:///project", &settings))
+		got <- settings
+	}
+
+	go get()
+	c.answerConfiguration("file:///project", map[string]interface{}{"linters": []string{"vet"}})
+	assert.Equal(t, lintSettings{Enabled: true, Linters: []string{"vet"}, Timeout: 30}, <-got)
+
+	// Cached settings are not fetched again.
+	go get()
+	assert.Equal(t, lintSettings{Enabled: true, Linters: []string{"vet"}, Timeout: 30}, <-got)
+
+	c.send(map[string]interface{}{"method": "workspace/didChangeConfiguration", "params": map[string]interface{}{"settings": nil}})
+	c.answerConfiguration("file:///project", map[string]interface{}{"enabled": false, "linters": []string{"vet"}})
+
+	assert.Equal(t, change{
+		Scope: "file:///project",
+		Old:   &lintSettings{Enabled: true, Linters: []string{"vet"}, Timeout: 30},
+		New:   &lintSettings{Enabled: false, Linters: []string{"vet"}, Timeout: 30},
+	}, <-changes)
+
+	var wrongType struct{}
+	assert.Error(t, section.Get(context.Background(), "", &wrongType))
+}
+
+func TestSectionPush(t *testing.T) {
+	s := server.NewServer(context.Background())
+	section := NewClient(s).Section("go.lint", &lintSettings{Timeout: 30})
+
+	changes := make(chan *lintSettings, 1)
+	section.Subscribe(func(scope uri.URI, old, new interface{}) {
+		changes <- new.(*lintSettings)
+	})
+
+	c := newTestClient(t, s)
+	defer c.conn.Close()
+	c.initialize(map[string]interface{}{
+		"capabilities":          map[string]interface{}{},
+		"initializationOptions": map[string]interface{}{"go": map[string]interface{}{"lint": map[string]interface{}{"enabled": true}}},
+	})
+
+	var settings lintSettings
+	assert.NoError(t, section.Get(context.Background(), "", &settings))
+	assert.Equal(t, lintSettings{Enabled: true, Timeout: 30}, settings)
+
+	c.send(map[string]interface{}{"method": "workspace/didChangeConfiguration", "params": map[string]interface{}{
+		"settings": map[string]interface{}{"go": map[string]interface{}{"lint": map[string]interface{}{"enabled": true, "timeout": 10}}},
+	}})
+
+	assert.Equal(t, &lintSettings{Enabled: true, Timeout: 10}, <-changes)
+	assert.NoError(t, section.Get(context.Background(), "", &settings))
+	assert.Equal(t, lintSettings{Enabled: true, Timeout: 10}, settings)
+}
+
+func TestSectionPushedLookup(t *testing.T) {
+	s := server.NewServer(context.Background())
+	section := NewClient(s).Section("go.lint", &lintSettings{Timeout: 30})
+
+	changes := make(chan *lintSettings, 1)
+	section.Subscribe(func(scope uri.URI, old, new interface{}) {
+		changes <- new.(*lintSettings)
+	})
+
+	c := newTestClient(t, s)
+	defer c.conn.Close()
+	c.initialize(map[string]interface{}{
+		"capabilities":          map[string]interface{}{},
+		"initializationOptions": map[string]interface{}{"go": map[string]interface{}{"lint": map[string]interface{}{"linters": []string{"vet"}}}},
+	})
+
+	var settings lintSettings
+	assert.NoError(t, section.Get(context.Background(), "", &settings))
+	assert.Equal(t, lintSettings{Linters: []string{"vet"}, Timeout: 30}, settings)
+
+	// Settings missing the section leave it to its defaults, rather than
+	// decoding unrelated settings into it.
+	c.send(map[string]interface{}{"method": "workspace/didChangeConfiguration", "params": map[string]interface{}{
+		"settings": map[string]interface{}{"enabled": true, "go": map[string]interface{}{"build": map[string]interface{}{"enabled": true}}},
+	}})
+	assert.Equal(t, &lintSettings{Timeout: 30}, <-changes)
+}
+
+func TestSectionDefaults(t *testing.T) {
+	c := NewClient(server.NewServer(context.Background()))
+
+	assert.Panics(t, func() { c.Section("go", lintSettings{}) })
+}
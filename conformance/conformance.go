@@ -0,0 +1,245 @@
+// Package conformance checks that language servers follow the protocol-level
+// rules of the LSP specification: the framing of messages, the lifecycle of
+// sessions, responses to requests and notifications, and cancellation.
+//
+// Run checks a server started in-process or launched as a command, and
+// reports which rules pass:
+//
+//	report := conformance.Run(ctx, conformance.Server(newServer()))
+//	if !report.Passed() {
+//		t.Error(report)
+//	}
+//
+// Framing rules each run on a connection of their own, while the others run
+// in order on a single session, from before initialize until exit.
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/server"
+)
+
+// ResponseTimeout is how long rules wait for the server to respond.
+const ResponseTimeout = 5 * time.Second
+
+// Target is a server to check.
+type Target interface {
+	// Connect opens a new connection to the server. Closing the connection
+	// waits for the server to be done with it.
+	Connect(ctx context.Context) (io.ReadWriteCloser, error)
+}
+
+// Server returns a target serving each connection with s, one at a time.
+func Server(s *server.Server) Target {
+	return serverTarget{s}
+}
+
+type serverTarget struct {
+	s *server.Server
+}
+
+func (t serverTarget) Connect(ctx context.Context) (io.ReadWriteCloser, error) {
+	clientConn, serverConn := net.Pipe()
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		_ = t.s.Serve(serverConn)
+	}()
+	return &pipeConn{Conn: clientConn, served: served}, nil
+}
+
+// pipeConn is a connection to a server served in-process.
+type pipeConn struct {
+	net.Conn
+	served chan struct{}
+}
+
+func (c *pipeConn) Close() error {
+	err := c.Conn.Close()
+	<-c.served
+	return err
+}
+
+// Command returns a target launching the command name with args for each
+// connection, and talking to it over its standard input and output.
+func Command(name string, args ...string) Target {
+	return commandTarget{name: name, args: args}
+}
+
+type commandTarget struct {
+	name string
+	args []string
+}
+
+func (t commandTarget) Connect(ctx context.Context) (io.ReadWriteCloser, error) {
+	cmd := exec.Command(t.name, t.args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &processConn{ReadCloser: stdout, WriteCloser: stdin, cmd: cmd}, nil
+}
+
+// processConn is a connection to a server process.
+type processConn struct {
+	io.ReadCloser
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+// Close closes the standard input of the process, then waits for it to exit,
+// killing it after ResponseTimeout.
+func (c *processConn) Close() error {
+	c.WriteCloser.Close()
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- c.cmd.Wait()
+	}()
+	select {
+	case err := <-exited:
+		return err
+	case <-time.After(ResponseTimeout):
+		_ = c.cmd.Process.Kill()
+		return <-exited
+	}
+}
+
+// Status is the outcome of a rule.
+type Status string
+
+const (
+	// Pass is the status of the rules the server follows.
+	Pass Status = "PASS"
+	// Fail is the status of the rules the server breaks.
+	Fail Status = "FAIL"
+	// Skip is the status of the rules that could not be checked, e.g. because
+	// the session ended early.
+	Skip Status = "SKIP"
+)
+
+// Result is the outcome of a rule.
+type Result struct {
+	Rule        string
+	Description string
+	Status      Status
+	Message     string // why the rule failed or was skipped
+}
+
+// Report lists the outcome of every rule, in the order they were checked.
+type Report struct {
+	Results []Result
+}
+
+// Passed reports whether no rule failed.
+func (r *Report) Passed() bool {
+	for _, result := range r.Results {
+		if result.Status == Fail {
+			return false
+		}
+	}
+	return true
+}
+
+// Result returns the outcome of the rule with the given name, if it was
+// checked.
+func (r *Report) Result(rule string) (Result, bool) {
+	for _, result := range r.Results {
+		if result.Rule == rule {
+			return result, true
+		}
+	}
+	return Result{}, false
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	counts := make(map[Status]int)
+	for _, result := range r.Results {
+		counts[result.Status]++
+		fmt.Fprintf(&b, "%s %s: %s\n", result.Status, result.Rule, result.Description)
+		if result.Message != "" {
+			fmt.Fprintf(&b, "     %s\n", result.Message)
+		}
+	}
+	fmt.Fprintf(&b, "%d passed, %d failed, %d skipped\n", counts[Pass], counts[Fail], counts[Skip])
+	return b.String()
+}
+
+// Run checks the rules against the server t connects to.
+func Run(ctx context.Context, t Target) *Report {
+	report := &Report{}
+	add := func(rule Rule, err error, status Status) {
+		result := Result{Rule: rule.Name, Description: rule.Description, Status: status}
+		if err != nil {
+			result.Message = err.Error()
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	for _, rule := range framingRules {
+		err := runAlone(ctx, t, rule)
+		add(rule, err, statusOf(err))
+	}
+
+	rwc, err := t.Connect(ctx)
+	if err != nil {
+		for _, rule := range sessionRules {
+			add(rule, fmt.Errorf("connect: %v", err), Fail)
+		}
+		return report
+	}
+	s := newSession(rwc)
+	defer s.close()
+
+	var skipped error
+	for _, rule := range sessionRules {
+		if skipped != nil {
+			add(rule, skipped, Skip)
+			continue
+		}
+
+		err := rule.check(ctx, s)
+		add(rule, err, statusOf(err))
+		switch {
+		case err != nil && rule.required:
+			skipped = fmt.Errorf("%s failed", rule.Name)
+		case s.closed() && rule.Name != "lifecycle/exit":
+			skipped = fmt.Errorf("connection closed after %s", rule.Name)
+		}
+	}
+	return report
+}
+
+// runAlone checks rule on a connection of its own.
+func runAlone(ctx context.Context, t Target, rule Rule) error {
+	rwc, err := t.Connect(ctx)
+	if err != nil {
+		return fmt.Errorf("connect: %v", err)
+	}
+	s := newSession(rwc)
+	defer s.close()
+
+	return rule.check(ctx, s)
+}
+
+func statusOf(err error) Status {
+	if err != nil {
+		return Fail
+	}
+	return Pass
+}
@@ -0,0 +1,128 @@
+package conformance
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/goodgophers/golsp-sdk/wire"
+	"github.com/intel-go/fastjson"
+	"github.com/stretchr/testify/assert"
+)
+
+// helperServerEnv makes the test binary run a server over stdio, for tests
+// launching a server command.
+const helperServerEnv = "CONFORMANCE_TEST_HELPER_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(helperServerEnv) != "" {
+		server.NewServer(context.Background()).StartStdio()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	report := Run(ctx, Server(server.NewServer(context.Background())))
+	assert.True(t, report.Passed(), report.String())
+	assert.Len(t, report.Results, len(Rules()))
+	assert.Contains(t, report.String(), "19 passed, 0 failed, 0 skipped")
+}
+
+func TestCommand(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	os.Setenv(helperServerEnv, "1")
+	defer os.Unsetenv(helperServerEnv)
+
+	report := Run(ctx, Command(os.Args[0]))
+	assert.True(t, report.Passed(), report.String())
+}
+
+func TestCommandNotFound(t *testing.T) {
+	report := Run(context.Background(), Command("conformance-test-no-such-command"))
+	assert.False(t, report.Passed())
+	for _, result := range report.Results {
+		assert.Equal(t, Fail, result.Status, result.Rule)
+		assert.Contains(t, result.Message, "connect:", result.Rule)
+	}
+}
+
+// sloppyServer answers every message, notifications included, with a null
+// result, omitted.
+type sloppyServer struct{}
+
+func (sloppyServer) Connect(ctx context.Context) (io.ReadWriteCloser, error) {
+	clientConn, serverConn := net.Pipe()
+	go func() {
+		defer serverConn.Close()
+
+		reader := bufio.NewReader(serverConn)
+		for {
+			data, err := wire.ReadMessage(reader)
+			if err != nil {
+				return
+			}
+			var msg struct {
+				ID     *fastjson.RawMessage `json:"id"`
+				Method string               `json:"method"`
+			}
+			_ = fastjson.Unmarshal(data, &msg)
+			if msg.Method == "initialize" {
+				_ = wire.WriteMessage(serverConn, map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": map[string]interface{}{"capabilities": map[string]interface{}{}}})
+				continue
+			}
+			_ = wire.WriteMessage(serverConn, map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID})
+		}
+	}()
+	return clientConn, nil
+}
+
+func TestSloppyServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	report := Run(ctx, sloppyServer{})
+	assert.False(t, report.Passed())
+
+	statuses := make(map[string]Status)
+	for _, result := range report.Results {
+		statuses[result.Rule] = result.Status
+	}
+	assert.Equal(t, map[string]Status{
+		"framing/content-type":                     Pass,
+		"framing/partial-writes":                   Pass,
+		"framing/consecutive-messages":             Pass,
+		"framing/invalid-json":                     Fail,
+		"framing/invalid-request":                  Fail,
+		"framing/missing-content-length":           Pass,
+		"lifecycle/request-before-initialize":      Fail,
+		"lifecycle/notification-before-initialize": Fail,
+		"lifecycle/initialize":                     Pass,
+		"lifecycle/initialize-once":                Fail,
+		"notifications/no-response":                Fail,
+		"requests/method-not-found":                Fail,
+		"requests/string-id":                       Pass,
+		"cancellation/unknown-request":             Fail,
+		"cancellation/respond-once":                Fail,
+		"lifecycle/shutdown":                       Fail,
+		"lifecycle/request-after-shutdown":         Skip,
+		"responses/well-formed":                    Skip,
+		"lifecycle/exit":                           Skip,
+	}, statuses)
+
+	result, ok := report.Result("lifecycle/shutdown")
+	assert.True(t, ok)
+	assert.Equal(t, "result omitted, expected null", result.Message)
+	result, _ = report.Result("lifecycle/exit")
+	assert.Equal(t, "lifecycle/shutdown failed", result.Message)
+}
@@ -0,0 +1,337 @@
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/osamingo/jsonrpc"
+)
+
+// Rule is a protocol-level rule servers must follow.
+type Rule struct {
+	Name        string
+	Description string
+
+	required bool // whether the session rules following it need it to pass
+	check    func(ctx context.Context, s *session) error
+}
+
+// Rules returns the rules checked by Run, in the order they are checked.
+func Rules() []Rule {
+	return append(append([]Rule(nil), framingRules...), sessionRules...)
+}
+
+// hoverParams are the parameters of the requests the rules send.
+var hoverParams = map[string]interface{}{
+	"textDocument": map[string]interface{}{"uri": "file:///conformance.go"},
+	"position":     map[string]interface{}{"line": 0, "character": 0},
+}
+
+// frame frames body with the given headers.
+func frame(body string, headers ...string) string {
+	return strings.Join(headers, "\r\n") + "\r\n\r\n" + body
+}
+
+func contentLength(body string) string {
+	return fmt.Sprintf("Content-Length: %d", len(body))
+}
+
+// framingRules each run on a connection of their own, before initialize.
+var framingRules = []Rule{
+	{
+		Name:        "framing/content-type",
+		Description: "Messages with a Content-Type header besides Content-Length are read.",
+		check: func(ctx context.Context, s *session) error {
+			body := `{"jsonrpc":"2.0","id":1,"method":"textDocument/hover"}`
+			if err := s.writeRaw(frame(body, contentLength(body), "Content-Type: application/vscode-jsonrpc; charset=utf-8")); err != nil {
+				return err
+			}
+			_, err := s.await(ctx, "1", "textDocument/hover")
+			return err
+		},
+	},
+	{
+		Name:        "framing/partial-writes",
+		Description: "Messages written in several parts are read once complete.",
+		check: func(ctx context.Context, s *session) error {
+			body := `{"jsonrpc":"2.0","id":1,"method":"textDocument/hover"}`
+			message := frame(body, contentLength(body))
+			for _, part := range []string{message[:7], message[7:20], message[20:40], message[40:]} {
+				if err := s.writeRaw(part); err != nil {
+					return err
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			_, err := s.await(ctx, "1", "textDocument/hover")
+			return err
+		},
+	},
+	{
+		Name:        "framing/consecutive-messages",
+		Description: "Messages written at once are all read.",
+		check: func(ctx context.Context, s *session) error {
+			first := `{"jsonrpc":"2.0","id":1,"method":"textDocument/hover"}`
+			second := `{"jsonrpc":"2.0","id":2,"method":"textDocument/hover"}`
+			if err := s.writeRaw(frame(first, contentLength(first)) + frame(second, contentLength(second))); err != nil {
+				return err
+			}
+
+			seen := make(map[string]bool)
+			for i := 0; i < 2; i++ {
+				r, err := s.next(ctx)
+				if err != nil {
+					return err
+				}
+				seen[r.ID] = true
+			}
+			if !seen["1"] || !seen["2"] {
+				return fmt.Errorf("expected responses to requests 1 and 2, got %v", seen)
+			}
+			return nil
+		},
+	},
+	{
+		Name:        "framing/invalid-json",
+		Description: "Bodies that are not JSON are answered with ParseError (-32700) and a null ID, and the connection stays usable.",
+		check: func(ctx context.Context, s *session) error {
+			body := `{"jsonrpc":`
+			if err := s.writeRaw(frame(body, contentLength(body))); err != nil {
+				return err
+			}
+			r, err := s.await(ctx, "null", "invalid JSON")
+			if err != nil {
+				return err
+			}
+			if err := expectError(r, jsonrpc.ErrorCodeParse); err != nil {
+				return err
+			}
+
+			_, err = s.call(ctx, "textDocument/hover", hoverParams)
+			return err
+		},
+	},
+	{
+		Name:        "framing/invalid-request",
+		Description: "Messages that are not JSON-RPC 2.0 requests are answered with InvalidRequest (-32600).",
+		check: func(ctx context.Context, s *session) error {
+			body := `{"jsonrpc":"1.0","id":1,"method":"textDocument/hover"}`
+			if err := s.writeRaw(frame(body, contentLength(body))); err != nil {
+				return err
+			}
+			r, err := s.next(ctx)
+			if err != nil {
+				return err
+			}
+			if r.ID != "1" && r.ID != "null" {
+				return fmt.Errorf("expected a response to request 1 or with a null ID, got %s", r.ID)
+			}
+			return expectError(r, jsonrpc.ErrorCodeInvalidRequest)
+		},
+	},
+	{
+		Name:        "framing/missing-content-length",
+		Description: "Headers without Content-Length are rejected, with ParseError (-32700) if answered.",
+		check: func(ctx context.Context, s *session) error {
+			body := `{"jsonrpc":"2.0","id":1,"method":"textDocument/hover"}`
+			if err := s.writeRaw(frame(body, "Content-Type: application/vscode-jsonrpc; charset=utf-8")); err != nil {
+				return err
+			}
+			r, err := s.next(ctx)
+			if err == errClosed {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			return expectError(r, jsonrpc.ErrorCodeParse)
+		},
+	},
+}
+
+// sessionRules run in order on a single session.
+var sessionRules = []Rule{
+	{
+		Name:        "lifecycle/request-before-initialize",
+		Description: "Requests before initialize are answered with ServerNotInitialized (-32002).",
+		check: func(ctx context.Context, s *session) error {
+			r, err := s.call(ctx, "textDocument/hover", hoverParams)
+			if err != nil {
+				return err
+			}
+			return expectError(r, server.ErrorCodeServerNotInitialized)
+		},
+	},
+	{
+		Name:        "lifecycle/notification-before-initialize",
+		Description: "Notifications before initialize are dropped, without response.",
+		check: func(ctx context.Context, s *session) error {
+			if err := s.notify("textDocument/didClose", map[string]interface{}{"textDocument": hoverParams["textDocument"]}); err != nil {
+				return err
+			}
+			return s.expectNoResponse(ctx, "textDocument/didClose")
+		},
+	},
+	{
+		Name:        "lifecycle/initialize",
+		Description: "initialize is answered with a result holding the capabilities of the server.",
+		required:    true,
+		check: func(ctx context.Context, s *session) error {
+			r, err := s.call(ctx, "initialize", map[string]interface{}{
+				"processId":    nil,
+				"rootUri":      nil,
+				"capabilities": map[string]interface{}{},
+			})
+			if err != nil {
+				return err
+			}
+			if r.Error != nil {
+				return fmt.Errorf("initialize failed: %d (%s)", r.Error.Code, r.Error.Message)
+			}
+
+			if !strings.Contains(raw(r.Result), `"capabilities"`) {
+				return fmt.Errorf("result without capabilities: %s", raw(r.Result))
+			}
+			return s.notify("initialized", map[string]interface{}{})
+		},
+	},
+	{
+		Name:        "lifecycle/initialize-once",
+		Description: "A second initialize request is answered with an error.",
+		check: func(ctx context.Context, s *session) error {
+			r, err := s.call(ctx, "initialize", map[string]interface{}{"processId": nil, "rootUri": nil, "capabilities": map[string]interface{}{}})
+			if err != nil {
+				return err
+			}
+			if r.Error == nil {
+				return errors.New("second initialize succeeded")
+			}
+			return nil
+		},
+	},
+	{
+		Name:        "notifications/no-response",
+		Description: "Notifications are never responded to, even for unknown methods or invalid parameters.",
+		check: func(ctx context.Context, s *session) error {
+			if err := s.notify("$/conformance/notification", nil); err != nil {
+				return err
+			}
+			if err := s.notify("textDocument/didOpen", "invalid"); err != nil {
+				return err
+			}
+			return s.expectNoResponse(ctx, "notifications")
+		},
+	},
+	{
+		Name:        "requests/method-not-found",
+		Description: "Requests for unknown methods are answered with MethodNotFound (-32601).",
+		check: func(ctx context.Context, s *session) error {
+			r, err := s.call(ctx, "conformance/unknown", nil)
+			if err != nil {
+				return err
+			}
+			return expectError(r, jsonrpc.ErrorCodeMethodNotFound)
+		},
+	},
+	{
+		Name:        "requests/string-id",
+		Description: "Responses carry the ID of their request, including string IDs.",
+		check: func(ctx context.Context, s *session) error {
+			if err := s.send("conformance", "conformance/unknown", nil); err != nil {
+				return err
+			}
+			_, err := s.await(ctx, `"conformance"`, "conformance/unknown")
+			return err
+		},
+	},
+	{
+		Name:        "cancellation/unknown-request",
+		Description: "$/cancelRequest for an unknown request is ignored, without response.",
+		check: func(ctx context.Context, s *session) error {
+			if err := s.notify("$/cancelRequest", map[string]interface{}{"id": 424242}); err != nil {
+				return err
+			}
+			return s.expectNoResponse(ctx, "$/cancelRequest")
+		},
+	},
+	{
+		Name:        "cancellation/respond-once",
+		Description: "Cancelled requests are still answered, exactly once, with a result or RequestCancelled (-32800).",
+		check: func(ctx context.Context, s *session) error {
+			id := s.nextID()
+			if err := s.send(id, "textDocument/hover", hoverParams); err != nil {
+				return err
+			}
+			if err := s.notify("$/cancelRequest", map[string]interface{}{"id": id}); err != nil {
+				return err
+			}
+			if _, err := s.await(ctx, fmt.Sprint(id), "textDocument/hover"); err != nil {
+				return err
+			}
+			return s.expectNoResponse(ctx, "the cancelled request")
+		},
+	},
+	{
+		Name:        "lifecycle/shutdown",
+		Description: "shutdown is answered with a null result, which is not omitted.",
+		required:    true,
+		check: func(ctx context.Context, s *session) error {
+			r, err := s.call(ctx, "shutdown", nil)
+			if err != nil {
+				return err
+			}
+			if r.Error != nil {
+				return fmt.Errorf("shutdown failed: %d (%s)", r.Error.Code, r.Error.Message)
+			}
+			if !r.hasResult {
+				return errors.New("result omitted, expected null")
+			}
+			if raw(r.Result) != "null" {
+				return fmt.Errorf("expected a null result, got %s", raw(r.Result))
+			}
+			return nil
+		},
+	},
+	{
+		Name:        "lifecycle/request-after-shutdown",
+		Description: "Requests after shutdown are answered with InvalidRequest (-32600).",
+		check: func(ctx context.Context, s *session) error {
+			r, err := s.call(ctx, "textDocument/hover", hoverParams)
+			if err != nil {
+				return err
+			}
+			return expectError(r, jsonrpc.ErrorCodeInvalidRequest)
+		},
+	},
+	{
+		Name:        "responses/well-formed",
+		Description: "Responses are JSON-RPC 2.0 messages holding either a result or an error.",
+		check: func(ctx context.Context, s *session) error {
+			if invalid := s.invalidResponses(); len(invalid) > 0 {
+				return errors.New(strings.Join(invalid, "; "))
+			}
+			return nil
+		},
+	},
+	{
+		Name:        "lifecycle/exit",
+		Description: "The server closes the connection after the exit notification.",
+		check: func(ctx context.Context, s *session) error {
+			if err := s.notify("exit", nil); err != nil {
+				return err
+			}
+
+			select {
+			case <-s.done:
+				return nil
+			case <-time.After(ResponseTimeout):
+				return fmt.Errorf("connection still open after %v", ResponseTimeout)
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	},
+}
@@ -0,0 +1,282 @@
+package conformance
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/goodgophers/golsp-sdk/wire"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+)
+
+// errClosed is returned when the server closes the connection while a rule
+// waits for a response.
+var errClosed = errors.New("connection closed by the server")
+
+// response is a response sent by the server.
+type response struct {
+	ID     string // as JSON
+	Result *fastjson.RawMessage
+	Error  *jsonrpc.Error
+
+	hasResult bool
+	hasError  bool
+}
+
+// session is a connection to the server, on which rules send messages and
+// wait for responses. Requests sent by the server are answered with null
+// results, or null settings for workspace/configuration.
+type session struct {
+	rwc       io.ReadWriteCloser
+	responses chan response
+	done      chan struct{} // closed once the server closed the connection
+
+	writeMu sync.Mutex
+	seq     int
+
+	mu      sync.Mutex
+	invalid []string // the invalid responses received
+}
+
+func newSession(rwc io.ReadWriteCloser) *session {
+	s := &session{rwc: rwc, responses: make(chan response, 100), done: make(chan struct{})}
+	go s.read()
+	return s
+}
+
+func (s *session) read() {
+	defer close(s.done)
+
+	reader := bufio.NewReader(s.rwc)
+	for {
+		data, err := wire.ReadMessage(reader)
+		if err != nil {
+			return
+		}
+
+		var fields map[string]fastjson.RawMessage
+		if err := fastjson.Unmarshal(data, &fields); err != nil {
+			s.invalidResponse("invalid JSON: %s", data)
+			continue
+		}
+		id, hasID := fields["id"]
+		method, hasMethod := fields["method"]
+
+		switch {
+		case hasMethod && hasID:
+			go s.answer(id, method, fields["params"])
+		case hasMethod:
+			// Notifications of the server are not checked.
+		default:
+			s.receive(data, fields)
+		}
+	}
+}
+
+// receive checks a response, and hands it over to the rule waiting for it.
+func (s *session) receive(data []byte, fields map[string]fastjson.RawMessage) {
+	r := response{ID: strings.TrimSpace(string(fields["id"]))}
+	if _, ok := fields["id"]; !ok {
+		s.invalidResponse("response without ID: %s", data)
+		r.ID = "null"
+	}
+	if result, ok := fields["result"]; ok {
+		r.Result, r.hasResult = &result, true
+	}
+	if e, ok := fields["error"]; ok {
+		r.hasError = true
+		r.Error = &jsonrpc.Error{}
+		if err := fastjson.Unmarshal(e, r.Error); err != nil {
+			s.invalidResponse("invalid error: %s", data)
+		}
+	}
+	if r.hasResult == r.hasError {
+		s.invalidResponse("response with both or neither of result and error: %s", data)
+	}
+	if string(fields["jsonrpc"]) != `"2.0"` {
+		s.invalidResponse("response without \"jsonrpc\": \"2.0\": %s", data)
+	}
+
+	s.responses <- r
+}
+
+func (s *session) invalidResponse(format string, v ...interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.invalid = append(s.invalid, fmt.Sprintf(format, v...))
+}
+
+// answer answers a request sent by the server.
+func (s *session) answer(id, method, params fastjson.RawMessage) {
+	var result interface{}
+	if string(method) == `"workspace/configuration"` {
+		var p struct {
+			Items []interface{} `json:"items"`
+		}
+		_ = fastjson.Unmarshal(params, &p)
+		result = make([]interface{}, len(p.Items))
+	}
+	_ = s.write(map[string]interface{}{"jsonrpc": jsonrpc.Version, "id": &id, "result": result})
+}
+
+// nextID returns a new request ID.
+func (s *session) nextID() int {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.seq++
+	return s.seq
+}
+
+func (s *session) write(v interface{}) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	return wire.WriteMessage(s.rwc, v)
+}
+
+// writeRaw writes data as is, bypassing framing.
+func (s *session) writeRaw(data string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	_, err := io.WriteString(s.rwc, data)
+	return err
+}
+
+// send sends a request with the given ID, which may be of any JSON type.
+func (s *session) send(id interface{}, method string, params interface{}) error {
+	msg := map[string]interface{}{"jsonrpc": jsonrpc.Version, "id": id, "method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+	return s.write(msg)
+}
+
+// notify sends a notification.
+func (s *session) notify(method string, params interface{}) error {
+	msg := map[string]interface{}{"jsonrpc": jsonrpc.Version, "method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+	return s.write(msg)
+}
+
+// call sends a request and waits for its response. Responses to other
+// requests arriving first are errors.
+func (s *session) call(ctx context.Context, method string, params interface{}) (response, error) {
+	id := s.nextID()
+	if err := s.send(id, method, params); err != nil {
+		return response{}, err
+	}
+	return s.await(ctx, strconv.Itoa(id), method)
+}
+
+// await waits for the response to the request with the given ID, as JSON.
+// Responses to other requests arriving first are skipped, and reported as an
+// error along with the awaited response.
+func (s *session) await(ctx context.Context, id, method string) (response, error) {
+	var unexpected []string
+	for {
+		r, err := s.next(ctx)
+		if err != nil {
+			return r, fmt.Errorf("%s: %v", method, err)
+		}
+		if r.ID == id {
+			if len(unexpected) > 0 {
+				return r, fmt.Errorf("unexpected responses before the response to %s: %s", method, strings.Join(unexpected, ", "))
+			}
+			return r, nil
+		}
+		unexpected = append(unexpected, r.ID)
+	}
+}
+
+// next waits for the next response.
+func (s *session) next(ctx context.Context) (response, error) {
+	ctx, cancel := context.WithTimeout(ctx, ResponseTimeout)
+	defer cancel()
+
+	select {
+	case r := <-s.responses:
+		return r, nil
+	default:
+	}
+	select {
+	case r := <-s.responses:
+		return r, nil
+	case <-s.done:
+		select {
+		case r := <-s.responses:
+			return r, nil
+		default:
+			return response{}, errClosed
+		}
+	case <-ctx.Done():
+		return response{}, fmt.Errorf("no response after %v", ResponseTimeout)
+	}
+}
+
+// expectNoResponse checks that the server sent no response to the messages
+// sent so far, other than those already awaited. It sends a request and
+// expects its response to be the next one, as servers process messages in
+// order.
+func (s *session) expectNoResponse(ctx context.Context, what string) error {
+	id := s.nextID()
+	if err := s.send(id, "$/conformance/ping", nil); err != nil {
+		return err
+	}
+
+	if _, err := s.await(ctx, strconv.Itoa(id), "$/conformance/ping"); err != nil {
+		return fmt.Errorf("%s: %v", what, err)
+	}
+	return nil
+}
+
+// invalidResponses returns the invalid responses received so far.
+func (s *session) invalidResponses() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.invalid
+}
+
+// closed reports whether the server closed the connection.
+func (s *session) closed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *session) close() {
+	s.rwc.Close()
+	<-s.done
+}
+
+// expectError checks that r is an error response with the given code.
+func expectError(r response, code jsonrpc.ErrorCode) error {
+	if r.Error == nil {
+		return fmt.Errorf("expected error %d, got result %s", code, raw(r.Result))
+	}
+	if r.Error.Code != code {
+		return fmt.Errorf("expected error %d, got error %d (%s)", code, r.Error.Code, r.Error.Message)
+	}
+	return nil
+}
+
+func raw(m *fastjson.RawMessage) string {
+	if m == nil {
+		return "null"
+	}
+	return string(*m)
+}
@@ -0,0 +1,381 @@
+// Package fileops lets servers take part in the file operations performed by
+// users in the client, e.g. updating imports when a file is renamed.
+//
+// Handlers declare filters of the files they are interested in. Filters are
+// advertised in the server capabilities, so that clients only send the
+// operations on those files, and checked again before calling handlers.
+package fileops
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/goodgophers/golsp-sdk/glob"
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// FileCreate describes a created file.
+type FileCreate struct {
+	URI uri.URI `json:"uri"`
+}
+
+// FileRename describes a renamed file.
+type FileRename struct {
+	OldURI uri.URI `json:"oldUri"`
+	NewURI uri.URI `json:"newUri"`
+}
+
+// FileDelete describes a deleted file.
+type FileDelete struct {
+	URI uri.URI `json:"uri"`
+}
+
+// CreateFilesParams are the parameters of workspace/willCreateFiles and
+// workspace/didCreateFiles.
+type CreateFilesParams struct {
+	Files []FileCreate `json:"files"`
+}
+
+// RenameFilesParams are the parameters of workspace/willRenameFiles and
+// workspace/didRenameFiles.
+type RenameFilesParams struct {
+	Files []FileRename `json:"files"`
+}
+
+// DeleteFilesParams are the parameters of workspace/willDeleteFiles and
+// workspace/didDeleteFiles.
+type DeleteFilesParams struct {
+	Files []FileDelete `json:"files"`
+}
+
+// WorkspaceEdit is an edit handlers apply before files are created, renamed
+// or deleted. It extends lsp.WorkspaceEdit with versioned document changes,
+// which clients supporting them prefer over changes.
+type WorkspaceEdit struct {
+	Changes         map[string][]lsp.TextEdit `json:"changes,omitempty"`
+	DocumentChanges []TextDocumentEdit        `json:"documentChanges,omitempty"`
+}
+
+// TextDocumentEdit are the edits of a version of a document.
+type TextDocumentEdit struct {
+	TextDocument lsp.VersionedTextDocumentIdentifier `json:"textDocument"`
+	Edits        []lsp.TextEdit                      `json:"edits"`
+}
+
+// Operations dispatches file operations to handlers.
+type Operations struct {
+	server *server.Server
+
+	mu       sync.Mutex
+	handlers map[string][]*handler
+}
+
+type handler struct {
+	filters []filter
+	fn      interface{}
+}
+
+// filter is a compiled server.FileOperationFilter.
+type filter struct {
+	scheme     string
+	glob       *glob.Glob
+	ignoreCase bool
+	kind       server.FileOperationPatternKind
+}
+
+// New returns the Operations of the clients of s.
+//
+// It registers the callbacks of the six file operation methods on s. The
+// capabilities of the server are declared as handlers are added.
+func New(s *server.Server) *Operations {
+	o := &Operations{server: s, handlers: make(map[string][]*handler)}
+
+	s.On("workspace/willCreateFiles", o.createFiles("workspace/willCreateFiles"))
+	s.On("workspace/didCreateFiles", o.createFiles("workspace/didCreateFiles"))
+	s.On("workspace/willRenameFiles", o.renameFiles("workspace/willRenameFiles"))
+	s.On("workspace/didRenameFiles", o.renameFiles("workspace/didRenameFiles"))
+	s.On("workspace/willDeleteFiles", o.deleteFiles("workspace/willDeleteFiles"))
+	s.On("workspace/didDeleteFiles", o.deleteFiles("workspace/didDeleteFiles"))
+
+	return o
+}
+
+// OnWillCreate registers fn to be called with the files matching filters
+// before they are created. The edits fn returns are applied by the client
+// before creating the files.
+func (o *Operations) OnWillCreate(filters []server.FileOperationFilter, fn func(ctx context.Context, files []FileCreate) (*WorkspaceEdit, error)) {
+	o.add("workspace/willCreateFiles", filters, fn)
+}
+
+// OnDidCreate registers fn to be called with the files matching filters once
+// they are created.
+func (o *Operations) OnDidCreate(filters []server.FileOperationFilter, fn func(ctx context.Context, files []FileCreate)) {
+	o.add("workspace/didCreateFiles", filters, fn)
+}
+
+// OnWillRename registers fn to be called with the files matching filters
+// before they are renamed. The edits fn returns are applied by the client
+// before renaming the files.
+//
+// Filters are matched against the old URIs of the files.
+func (o *Operations) OnWillRename(filters []server.FileOperationFilter, fn func(ctx context.Context, files []FileRename) (*WorkspaceEdit, error)) {
+	o.add("workspace/willRenameFiles", filters, fn)
+}
+
+// OnDidRename registers fn to be called with the files matching filters once
+// they are renamed.
+//
+// Filters are matched against the old URIs of the files.
+func (o *Operations) OnDidRename(filters []server.FileOperationFilter, fn func(ctx context.Context, files []FileRename)) {
+	o.add("workspace/didRenameFiles", filters, fn)
+}
+
+// OnWillDelete registers fn to be called with the files matching filters
+// before they are deleted. The edits fn returns are applied by the client
+// before deleting the files.
+func (o *Operations) OnWillDelete(filters []server.FileOperationFilter, fn func(ctx context.Context, files []FileDelete) (*WorkspaceEdit, error)) {
+	o.add("workspace/willDeleteFiles", filters, fn)
+}
+
+// OnDidDelete registers fn to be called with the files matching filters once
+// they are deleted.
+func (o *Operations) OnDidDelete(filters []server.FileOperationFilter, fn func(ctx context.Context, files []FileDelete)) {
+	o.add("workspace/didDeleteFiles", filters, fn)
+}
+
+// add registers a handler of method, and advertises its filters. It panics if
+// a filter pattern is invalid.
+func (o *Operations) add(method string, filters []server.FileOperationFilter, fn interface{}) {
+	h := &handler{fn: fn}
+	for _, f := range filters {
+		pattern := f.Pattern.Glob
+		ignoreCase := f.Pattern.Options != nil && f.Pattern.Options.IgnoreCase
+		if ignoreCase {
+			pattern = strings.ToLower(pattern)
+		}
+		g, err := glob.Compile(pattern)
+		if err != nil {
+			panic(fmt.Errorf("[fileops] %s: %+v", method, err))
+		}
+		h.filters = append(h.filters, filter{scheme: f.Scheme, glob: g, ignoreCase: ignoreCase, kind: f.Pattern.Matches})
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.handlers[method] = append(o.handlers[method], h)
+
+	options := o.options(method)
+	options.Filters = append(options.Filters, filters...)
+}
+
+// options returns the registration options of method advertised in the
+// server capabilities, declaring them if needed.
+func (o *Operations) options(method string) *server.FileOperationRegistrationOptions {
+	caps := o.server.Capabilities()
+	if caps.Workspace == nil {
+		caps.Workspace = &server.WorkspaceServerCapabilities{}
+	}
+	if caps.Workspace.FileOperations == nil {
+		caps.Workspace.FileOperations = &server.FileOperationsServerCapabilities{}
+	}
+
+	var options **server.FileOperationRegistrationOptions
+	switch ops := caps.Workspace.FileOperations; method {
+	case "workspace/willCreateFiles":
+		options = &ops.WillCreate
+	case "workspace/didCreateFiles":
+		options = &ops.DidCreate
+	case "workspace/willRenameFiles":
+		options = &ops.WillRename
+	case "workspace/didRenameFiles":
+		options = &ops.DidRename
+	case "workspace/willDeleteFiles":
+		options = &ops.WillDelete
+	default:
+		options = &ops.DidDelete
+	}
+	if *options == nil {
+		*options = &server.FileOperationRegistrationOptions{Filters: []server.FileOperationFilter{}}
+	}
+	return *options
+}
+
+// matches reports whether the file at u matches any filter of h. Whether the
+// file is a folder is checked on disk at stat, its location at the time of the
+// operation. Filters restricted to files or folders match when this cannot be
+// determined, e.g. for files not created yet.
+func (h *handler) matches(u, stat uri.URI) bool {
+	for _, f := range h.filters {
+		if f.scheme != "" && f.scheme != u.Scheme() {
+			continue
+		}
+		p := u.Path()
+		if f.ignoreCase {
+			p = strings.ToLower(p)
+		}
+		if !f.glob.Match(p) {
+			continue
+		}
+		if f.kind != "" {
+			if isDir, ok := isDir(stat); ok && isDir != (f.kind == server.FOPKFolder) {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// isDir reports whether u denotes a directory, and whether it could be
+// determined.
+func isDir(u uri.URI) (bool, bool) {
+	filename, err := u.Filename()
+	if err != nil {
+		return false, false
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return false, false
+	}
+	return info.IsDir(), true
+}
+
+func (o *Operations) createFiles(method string) server.CallbackFunc {
+	return func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p CreateFilesParams
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+
+		return o.dispatch(method, func(h *handler) (*WorkspaceEdit, error) {
+			var files []FileCreate
+			for _, f := range p.Files {
+				if h.matches(f.URI, f.URI) {
+					files = append(files, f)
+				}
+			}
+			if len(files) == 0 {
+				return nil, nil
+			}
+
+			switch fn := h.fn.(type) {
+			case func(context.Context, []FileCreate) (*WorkspaceEdit, error):
+				return fn(ctx, files)
+			case func(context.Context, []FileCreate):
+				fn(ctx, files)
+			}
+			return nil, nil
+		})
+	}
+}
+
+func (o *Operations) renameFiles(method string) server.CallbackFunc {
+	return func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p RenameFilesParams
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+
+		return o.dispatch(method, func(h *handler) (*WorkspaceEdit, error) {
+			var files []FileRename
+			for _, f := range p.Files {
+				stat := f.OldURI
+				if strings.HasPrefix(method, "workspace/did") {
+					stat = f.NewURI
+				}
+				if h.matches(f.OldURI, stat) {
+					files = append(files, f)
+				}
+			}
+			if len(files) == 0 {
+				return nil, nil
+			}
+
+			switch fn := h.fn.(type) {
+			case func(context.Context, []FileRename) (*WorkspaceEdit, error):
+				return fn(ctx, files)
+			case func(context.Context, []FileRename):
+				fn(ctx, files)
+			}
+			return nil, nil
+		})
+	}
+}
+
+func (o *Operations) deleteFiles(method string) server.CallbackFunc {
+	return func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p DeleteFilesParams
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+
+		return o.dispatch(method, func(h *handler) (*WorkspaceEdit, error) {
+			var files []FileDelete
+			for _, f := range p.Files {
+				if h.matches(f.URI, f.URI) {
+					files = append(files, f)
+				}
+			}
+			if len(files) == 0 {
+				return nil, nil
+			}
+
+			switch fn := h.fn.(type) {
+			case func(context.Context, []FileDelete) (*WorkspaceEdit, error):
+				return fn(ctx, files)
+			case func(context.Context, []FileDelete):
+				fn(ctx, files)
+			}
+			return nil, nil
+		})
+	}
+}
+
+// dispatch calls call for each handler of method, and merges the edits they
+// return. Clients apply either the changes or the document changes of an edit,
+// so handlers returning changes only cannot be merged with handlers returning
+// document changes.
+func (o *Operations) dispatch(method string, call func(h *handler) (*WorkspaceEdit, error)) (interface{}, error) {
+	o.mu.Lock()
+	handlers := o.handlers[method]
+	o.mu.Unlock()
+
+	var edit *WorkspaceEdit
+	var changesOnly bool
+	for _, h := range handlers {
+		e, err := call(h)
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			continue
+		}
+		if edit == nil {
+			edit = &WorkspaceEdit{}
+		}
+		if len(e.Changes) > 0 && edit.Changes == nil {
+			edit.Changes = make(map[string][]lsp.TextEdit)
+		}
+		for u, edits := range e.Changes {
+			edit.Changes[u] = append(edit.Changes[u], edits...)
+		}
+		edit.DocumentChanges = append(edit.DocumentChanges, e.DocumentChanges...)
+		changesOnly = changesOnly || (len(e.Changes) > 0 && len(e.DocumentChanges) == 0)
+	}
+
+	if edit == nil {
+		return nil, nil
+	}
+	if changesOnly && len(edit.DocumentChanges) > 0 {
+		return nil, fmt.Errorf("[fileops] %s: handlers returned both changes and document changes", method)
+	}
+	return edit, nil
+}
@@ -0,0 +1,146 @@
+package fileops
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+	jsonRPCClient "github.com/ybbus/jsonrpc"
+)
+
+func TestFileOperations(t *testing.T) {
+	root, err := ioutil.TempDir("", "fileops")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+	assert.NoError(t, os.Mkdir(filepath.Join(root, "pkg"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "main.go"), nil, 0644))
+
+	s := server.NewServer(context.Background())
+	o := New(s)
+
+	goFiles := []server.FileOperationFilter{{Scheme: "file", Pattern: server.FileOperationPattern{Glob: "**/*.go"}}}
+	var renamed [][]FileRename
+	o.OnWillRename(goFiles, func(ctx context.Context, files []FileRename) (*WorkspaceEdit, error) {
+		renamed = append(renamed, files)
+		return &WorkspaceEdit{Changes: map[string][]lsp.TextEdit{
+			"file:///api/main.go": {{NewText: "\"api/" + files[0].NewURI.Path() + "\""}},
+		}}, nil
+	})
+	o.OnWillRename([]server.FileOperationFilter{{Pattern: server.FileOperationPattern{
+		Glob:    "**/*.GO",
+		Options: &server.FileOperationPatternOptions{IgnoreCase: true},
+	}}}, func(ctx context.Context, files []FileRename) (*WorkspaceEdit, error) {
+		return &WorkspaceEdit{Changes: map[string][]lsp.TextEdit{
+			"file:///api/main.go": {{NewText: "// renamed"}},
+		}}, nil
+	})
+
+	var deleted [][]FileDelete
+	o.OnDidDelete([]server.FileOperationFilter{{Pattern: server.FileOperationPattern{Glob: "**", Matches: server.FOPKFolder}}}, func(ctx context.Context, files []FileDelete) {
+		deleted = append(deleted, files)
+	})
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	rpcClient := jsonRPCClient.NewClient(ts.URL)
+
+	var result map[string]interface{}
+	assert.NoError(t, rpcClient.CallFor(&result, "initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}))
+	assert.Equal(t, map[string]interface{}{
+		"fileOperations": map[string]interface{}{
+			"willRename": map[string]interface{}{"filters": []interface{}{
+				map[string]interface{}{"scheme": "file", "pattern": map[string]interface{}{"glob": "**/*.go"}},
+				map[string]interface{}{"pattern": map[string]interface{}{"glob": "**/*.GO", "options": map[string]interface{}{"ignoreCase": true}}},
+			}},
+			"didDelete": map[string]interface{}{"filters": []interface{}{
+				map[string]interface{}{"pattern": map[string]interface{}{"glob": "**", "matches": "folder"}},
+			}},
+		},
+	}, result["capabilities"].(map[string]interface{})["workspace"])
+
+	var edit WorkspaceEdit
+	assert.NoError(t, rpcClient.CallFor(&edit, "workspace/willRenameFiles", map[string]interface{}{
+		"files": []interface{}{
+			map[string]interface{}{"oldUri": "file:///api/a.go", "newUri": "file:///api/b.go"},
+			map[string]interface{}{"oldUri": "file:///api/README.md", "newUri": "file:///api/README"},
+			map[string]interface{}{"oldUri": "untitled:/c.go", "newUri": "untitled:/d.go"},
+		},
+	}))
+	assert.Equal(t, [][]FileRename{{{OldURI: "file:///api/a.go", NewURI: "file:///api/b.go"}}}, renamed)
+	assert.Equal(t, WorkspaceEdit{Changes: map[string][]lsp.TextEdit{
+		"file:///api/main.go": {{NewText: "\"api//api/b.go\""}, {NewText: "// renamed"}},
+	}}, edit)
+
+	response, err := rpcClient.Call("workspace/willRenameFiles", map[string]interface{}{
+		"files": []interface{}{map[string]interface{}{"oldUri": "file:///api/README.md", "newUri": "file:///api/README"}},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, response.Result)
+
+	_, err = rpcClient.Call("workspace/didDeleteFiles", map[string]interface{}{
+		"files": []interface{}{
+			map[string]interface{}{"uri": string(uri.File(filepath.Join(root, "pkg")))},
+			map[string]interface{}{"uri": string(uri.File(filepath.Join(root, "main.go")))},
+			map[string]interface{}{"uri": string(uri.File(filepath.Join(root, "gone")))},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]FileDelete{{
+		{URI: uri.File(filepath.Join(root, "pkg"))},
+		{URI: uri.File(filepath.Join(root, "gone"))},
+	}}, deleted)
+}
+
+func TestDocumentChanges(t *testing.T) {
+	s := server.NewServer(context.Background())
+	o := New(s)
+
+	all := []server.FileOperationFilter{{Pattern: server.FileOperationPattern{Glob: "**"}}}
+	documentEdit := func(u lsp.DocumentURI, text string) TextDocumentEdit {
+		return TextDocumentEdit{
+			TextDocument: lsp.VersionedTextDocumentIdentifier{TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: u}, Version: 2},
+			Edits:        []lsp.TextEdit{{NewText: text}},
+		}
+	}
+	for _, text := range []string{"a", "b"} {
+		text := text
+		o.OnWillCreate(all, func(ctx context.Context, files []FileCreate) (*WorkspaceEdit, error) {
+			return &WorkspaceEdit{DocumentChanges: []TextDocumentEdit{documentEdit("file:///main.go", text)}}, nil
+		})
+	}
+	o.OnWillDelete(all, func(ctx context.Context, files []FileDelete) (*WorkspaceEdit, error) {
+		return &WorkspaceEdit{DocumentChanges: []TextDocumentEdit{documentEdit("file:///main.go", "")}}, nil
+	})
+	o.OnWillDelete(all, func(ctx context.Context, files []FileDelete) (*WorkspaceEdit, error) {
+		return &WorkspaceEdit{Changes: map[string][]lsp.TextEdit{"file:///main.go": {{NewText: ""}}}}, nil
+	})
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	rpcClient := jsonRPCClient.NewClient(ts.URL)
+
+	var edit WorkspaceEdit
+	assert.NoError(t, rpcClient.CallFor(&edit, "workspace/willCreateFiles", map[string]interface{}{
+		"files": []interface{}{map[string]interface{}{"uri": "file:///new.go"}},
+	}))
+	assert.Equal(t, WorkspaceEdit{DocumentChanges: []TextDocumentEdit{
+		documentEdit("file:///main.go", "a"),
+		documentEdit("file:///main.go", "b"),
+	}}, edit)
+
+	response, err := rpcClient.Call("workspace/willDeleteFiles", map[string]interface{}{
+		"files": []interface{}{map[string]interface{}{"uri": "file:///main.go"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &jsonRPCClient.RPCError{
+		Code:    -32603,
+		Message: "[fileops] workspace/willDeleteFiles: handlers returned both changes and document changes",
+	}, response.Error)
+}
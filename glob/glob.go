@@ -0,0 +1,168 @@
+// Package glob implements the glob syntax of the LSP specification, used by
+// document selectors, file watchers and file operation filters.
+//
+// The syntax differs from path.Match:
+//
+//	?       matches one character in a path segment
+//	*       matches zero or more characters in a path segment
+//	**      matches any number of path segments, including none
+//	{a,b}   matches any of the comma-separated sub patterns
+//	[0-9]   matches a character of a range, in a path segment
+//	[!0-9]  matches a character outside of a range, in a path segment
+//
+// Patterns match whole slash-separated paths.
+package glob
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Glob is a compiled glob pattern.
+type Glob struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// Compile parses a glob pattern.
+func Compile(pattern string) (*Glob, error) {
+	expr, err := translate(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("glob: invalid pattern %q: %v", pattern, err)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("glob: invalid pattern %q: %v", pattern, err)
+	}
+
+	return &Glob{pattern: pattern, re: re}, nil
+}
+
+// MustCompile is like Compile but panics if the pattern is invalid.
+func MustCompile(pattern string) *Glob {
+	g, err := Compile(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// Match reports whether path matches the glob pattern.
+func Match(pattern, path string) (bool, error) {
+	g, err := Compile(pattern)
+	if err != nil {
+		return false, err
+	}
+	return g.Match(path), nil
+}
+
+// Match reports whether path matches g.
+func (g *Glob) Match(path string) bool {
+	return g.re.MatchString(path)
+}
+
+// String returns the pattern g was compiled from.
+func (g *Glob) String() string {
+	return g.pattern
+}
+
+// translate translates a glob pattern into an equivalent regular expression.
+func translate(pattern string) (string, error) {
+	var b strings.Builder
+	b.WriteString("^")
+
+	depth := 0
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '*' && strings.HasPrefix(pattern[i:], "**") && atSegmentStart(pattern, i) && atSegmentEnd(pattern, i+2):
+			// "**" only spans segments when it forms a whole segment.
+			if i+2 < len(pattern) && pattern[i+2] == '/' {
+				b.WriteString("(?:[^/]*/)*")
+				i += 2
+			} else {
+				b.WriteString(".*")
+				i++
+			}
+		case c == '*':
+			for i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+			}
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '{':
+			depth++
+			b.WriteString("(?:")
+		case c == '}' && depth > 0:
+			depth--
+			b.WriteString(")")
+		case c == ',' && depth > 0:
+			b.WriteString("|")
+		case c == '[':
+			class, n := translateClass(pattern[i:])
+			if n == 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			b.WriteString(class)
+			i += n - 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if depth > 0 {
+		return "", fmt.Errorf("unterminated {")
+	}
+
+	b.WriteString("$")
+	return b.String(), nil
+}
+
+// translateClass translates the character class at the start of s, returning
+// the equivalent regular expression and the length of the class in s. It
+// returns a zero length if s doesn't start with a valid class.
+func translateClass(s string) (string, int) {
+	i := 1
+	negated := i < len(s) && s[i] == '!'
+	if negated {
+		i++
+	}
+	start := i
+	// A ']' right after the opening bracket is part of the class.
+	if i < len(s) && s[i] == ']' {
+		i++
+	}
+	for i < len(s) && s[i] != ']' {
+		i++
+	}
+	if i >= len(s) || i == start {
+		return "", 0
+	}
+
+	var b strings.Builder
+	b.WriteString("[")
+	if negated {
+		// Classes never match separators.
+		b.WriteString("^/")
+	}
+	for _, r := range s[start:i] {
+		switch r {
+		case '\\', '[', ']', '^':
+			b.WriteString(`\`)
+		}
+		b.WriteRune(r)
+	}
+	b.WriteString("]")
+
+	return b.String(), i + 1
+}
+
+func atSegmentStart(pattern string, i int) bool {
+	return i == 0 || pattern[i-1] == '/' || pattern[i-1] == '{' || pattern[i-1] == ','
+}
+
+func atSegmentEnd(pattern string, i int) bool {
+	return i == len(pattern) || pattern[i] == '/' || pattern[i] == '}' || pattern[i] == ','
+}
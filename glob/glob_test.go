@@ -0,0 +1,96 @@
+package glob
+
+import (
+	"testing"
+
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		Pattern string
+		Path    string
+		Match   bool
+	}{
+		// Examples from the specification.
+		{"**/*.{ts,js}", "src/index.ts", true},
+		{"**/*.{ts,js}", "index.js", true},
+		{"**/*.{ts,js}", "src/index.go", false},
+		{"example.[0-9]", "example.0", true},
+		{"example.[0-9]", "example.a", false},
+		{"example.[!0-9]", "example.a", true},
+		{"example.[!0-9]", "example.0", false},
+
+		{"*", "main.go", true},
+		{"*", "cmd/main.go", false},
+		{"*.go", ".go", true},
+		{"?.go", "a.go", true},
+		{"?.go", "ab.go", false},
+		{"?", "/", false},
+		{"**", "", true},
+		{"**", "a/b/c", true},
+		{"**/go.mod", "go.mod", true},
+		{"**/go.mod", "/home/gopher/api/go.mod", true},
+		{"**/go.mod", "/home/gopher/api/go.mod.bak", false},
+		{"src/**/*.go", "src/main.go", true},
+		{"src/**/*.go", "src/a/b/main.go", true},
+		{"src/**/*.go", "lib/src/main.go", false},
+		{"src/**", "src/a/b", true},
+		{"**/node_modules/**", "/web/node_modules/react/index.js", true},
+		{"a**b", "a/b", false},
+		{"a**b", "axxb", true},
+		{"{cmd,internal}/**/*.go", "internal/server/conn.go", true},
+		{"{cmd,internal}/**/*.go", "pkg/server/conn.go", false},
+		{"*.{go,{mod,sum}}", "go.sum", true},
+		{"a,b", "a,b", true},
+		{"a,b", "a", false},
+		{"}", "}", true},
+		{"[!a]", "/", false},
+		{"[]]", "]", true},
+		{"[^]", "^", true},
+		{"[a-c]x", "bx", true},
+		{"[", "[", true},
+		{"file.(go)", "file.(go)", true},
+	}
+
+	for _, tc := range tests {
+		match, err := Match(tc.Pattern, tc.Path)
+		assert.NoError(t, err, tc.Pattern)
+		assert.Equal(t, tc.Match, match, "%s ~ %s", tc.Pattern, tc.Path)
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	_, err := Compile("*.{go,mod")
+	assert.EqualError(t, err, `glob: invalid pattern "*.{go,mod": unterminated {`)
+
+	assert.Panics(t, func() { MustCompile("{") })
+}
+
+func TestDocumentSelector(t *testing.T) {
+	selector := DocumentSelector{
+		{Language: "go", Scheme: "file"},
+		{Pattern: "**/go.{mod,sum}"},
+	}
+
+	tests := []struct {
+		URI      uri.URI
+		Language string
+		Match    bool
+	}{
+		{"file:///api/main.go", "go", true},
+		{"untitled:Untitled-1", "go", false},
+		{"file:///api/go.mod", "go.mod", true},
+		{"file:///api/go.work", "go.work", false},
+		{uri.New("file:///c%3A/api/go.sum"), "go.sum", true},
+		{"file:///api/README.md", "markdown", false},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.Match, selector.Matches(tc.URI, tc.Language), tc.URI)
+	}
+
+	assert.True(t, DocumentFilter{}.Matches("file:///anything", "text"))
+	assert.False(t, DocumentFilter{Pattern: "{"}.Matches("file:///{", "text"))
+}
@@ -0,0 +1,70 @@
+package glob
+
+import (
+	"sync"
+
+	"github.com/goodgophers/golsp-sdk/uri"
+)
+
+// DocumentFilter denotes documents by their language, the scheme of their URI
+// or a glob pattern matching their path. Empty fields match any document.
+type DocumentFilter struct {
+	Language string `json:"language,omitempty"`
+	Scheme   string `json:"scheme,omitempty"`
+	Pattern  string `json:"pattern,omitempty"`
+}
+
+// Matches reports whether the document at u, of the given language, matches
+// f. Patterns are matched against the path of u. Invalid patterns never
+// match.
+func (f DocumentFilter) Matches(u uri.URI, language string) bool {
+	if f.Language != "" && f.Language != language {
+		return false
+	}
+	if f.Scheme == "" && f.Pattern == "" {
+		return true
+	}
+
+	if f.Scheme != "" && f.Scheme != u.Scheme() {
+		return false
+	}
+	if f.Pattern != "" {
+		g, err := cached(f.Pattern)
+		if err != nil || !g.Match(u.Path()) {
+			return false
+		}
+	}
+	return true
+}
+
+// DocumentSelector is a combination of document filters, matching the
+// documents matched by any of them.
+type DocumentSelector []DocumentFilter
+
+// Matches reports whether the document at u, of the given language, matches
+// any filter of s.
+func (s DocumentSelector) Matches(u uri.URI, language string) bool {
+	for _, f := range s {
+		if f.Matches(u, language) {
+			return true
+		}
+	}
+	return false
+}
+
+// globs caches the patterns compiled by document filters, which are typically
+// few and matched against every document.
+var globs sync.Map
+
+func cached(pattern string) (*Glob, error) {
+	if g, ok := globs.Load(pattern); ok {
+		return g.(*Glob), nil
+	}
+
+	g, err := Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	globs.Store(pattern, g)
+	return g, nil
+}
@@ -6,6 +6,7 @@ require (
 	github.com/intel-go/fastjson v0.0.0-20170329170629-f846ae58a1ab
 	github.com/onsi/gomega v1.8.1 // indirect
 	github.com/osamingo/jsonrpc v0.0.0-20191226055922-29994f892db1
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/sourcegraph/go-lsp v0.0.0-20200117082640-b19bb38222e2
 	github.com/stretchr/testify v1.4.0
 	github.com/ybbus/jsonrpc v2.1.2+incompatible
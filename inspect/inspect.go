@@ -0,0 +1,222 @@
+// Package inspect sits between a client and a language server, forwards the
+// messages they exchange, and reports each of them to observers: a Printer
+// pretty-printing them as they go, a JSONWriter streaming them to a trace
+// file, or a Trace collecting them for an HTML report.
+//
+//	in := inspect.New(inspect.NewPrinter(os.Stderr, true), trace)
+//	err := in.Run(stdio, serverStdin, serverStdout)
+//
+// Responses are correlated with their requests: their events carry the
+// method of the request and the latency of the response.
+package inspect
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/record"
+	"github.com/goodgophers/golsp-sdk/wire"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+)
+
+// Kind is the kind of a message.
+type Kind string
+
+const (
+	// Request is the kind of requests.
+	Request Kind = "request"
+	// Notification is the kind of notifications.
+	Notification Kind = "notification"
+	// Response is the kind of responses.
+	Response Kind = "response"
+	// Invalid is the kind of messages that are not JSON-RPC messages.
+	Invalid Kind = "invalid"
+)
+
+// Event is a message forwarded between the client and the server. Its
+// direction is record.In for the messages sent by the client, and record.Out
+// for the ones sent by the server, so that JSON traces are recordings which
+// record.Replay can replay.
+type Event struct {
+	record.Entry
+
+	Kind   Kind   `json:"kind"`
+	ID     string `json:"id,omitempty"`     // as JSON, for requests and responses
+	Method string `json:"method,omitempty"` // for responses, the method of their request
+
+	// Latency is the time elapsed between a response and its request, and
+	// Cancelled whether the request was cancelled before.
+	Latency   time.Duration `json:"latency,omitempty"`
+	Cancelled bool          `json:"cancelled,omitempty"`
+
+	// Error is the error of error responses.
+	Error *jsonrpc.Error `json:"error,omitempty"`
+}
+
+// Observer is notified of the events of an inspector.
+type Observer interface {
+	// Observe is called for each event, one at a time, in the order the
+	// messages were forwarded.
+	Observe(e Event)
+}
+
+// Inspector forwards messages between a client and a server, and reports
+// them to its observers.
+type Inspector struct {
+	observers []Observer
+
+	mu      sync.Mutex // held while observing events, one at a time
+	pending map[key]*pendingRequest
+}
+
+// key identifies a request by its direction and ID.
+type key struct {
+	direction record.Direction
+	id        string
+}
+
+type pendingRequest struct {
+	method    string
+	time      time.Time
+	cancelled bool
+}
+
+// New returns an inspector reporting messages to observers.
+func New(observers ...Observer) *Inspector {
+	return &Inspector{observers: observers, pending: make(map[key]*pendingRequest)}
+}
+
+// Run forwards the messages the client writes to client to serverIn, the
+// input of the server, and the messages the server writes to serverOut to
+// client, until serverOut ends. serverIn is closed once the client stops
+// writing, so that the server sees the end of its input.
+//
+// Run returns the first error reading or writing messages, after which the
+// server's output is still forwarded as raw bytes. Run does not wait for the
+// client to stop writing once serverOut ended.
+func (in *Inspector) Run(client io.ReadWriter, serverIn io.WriteCloser, serverOut io.Reader) error {
+	errs := make(chan error, 1)
+	go func() {
+		err := in.forward(record.In, client, serverIn)
+		serverIn.Close()
+		errs <- err
+	}()
+
+	err := in.forward(record.Out, serverOut, client)
+	select {
+	case inErr := <-errs:
+		if err == nil {
+			err = inErr
+		}
+	default:
+	}
+	return err
+}
+
+// forward forwards the messages read from r to w, until r ends.
+func (in *Inspector) forward(direction record.Direction, r io.Reader, w io.Writer) error {
+	reader := bufio.NewReader(r)
+	for {
+		data, err := wire.ReadMessage(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// The stream cannot be resynchronised: forward the rest as is.
+			_, _ = io.Copy(w, reader)
+			return fmt.Errorf("[inspect] %s: %v", describe(direction), err)
+		}
+
+		in.mu.Lock()
+		in.observe(in.event(direction, data))
+		in.mu.Unlock()
+		if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(data), data); err != nil {
+			_, _ = io.Copy(ioutil.Discard, reader)
+			return fmt.Errorf("[inspect] %s: %v", describe(direction), err)
+		}
+	}
+}
+
+func describe(direction record.Direction) string {
+	if direction == record.In {
+		return "client to server"
+	}
+	return "server to client"
+}
+
+// event returns the event of a message, and tracks the requests pending a
+// response. in.mu must be held.
+func (in *Inspector) event(direction record.Direction, data []byte) Event {
+	e := Event{Entry: record.Entry{Time: time.Now().UTC(), Direction: direction}, Kind: Invalid}
+	if !json.Valid(data) {
+		e.Raw = string(data)
+		return e
+	}
+	raw := fastjson.RawMessage(data)
+	e.Message = &raw
+
+	// Fields are decoded one by one, as IDs may be null in responses.
+	var fields map[string]fastjson.RawMessage
+	if err := fastjson.Unmarshal(data, &fields); err != nil {
+		return e
+	}
+	id, hasID := fields["id"]
+	e.ID = strings.TrimSpace(string(id))
+	var method string
+	if m, ok := fields["method"]; ok {
+		if err := fastjson.Unmarshal(m, &method); err != nil {
+			return e
+		}
+	}
+
+	switch {
+	case method != "" && hasID:
+		e.Kind, e.Method = Request, method
+		in.pending[key{direction, e.ID}] = &pendingRequest{method: method, time: e.Time}
+
+	case method != "":
+		e.Kind, e.Method = Notification, method
+		if params, ok := fields["params"]; ok && method == "$/cancelRequest" {
+			var p struct {
+				ID *fastjson.RawMessage `json:"id"`
+			}
+			if fastjson.Unmarshal(params, &p) == nil && p.ID != nil {
+				if r, ok := in.pending[key{direction, strings.TrimSpace(string(*p.ID))}]; ok {
+					r.cancelled = true
+				}
+			}
+		}
+
+	case hasID:
+		e.Kind = Response
+		if rpcErr, ok := fields["error"]; ok && strings.TrimSpace(string(rpcErr)) != "null" {
+			e.Error = &jsonrpc.Error{}
+			if err := fastjson.Unmarshal(rpcErr, e.Error); err != nil {
+				e.Error = nil
+			}
+		}
+		// Responses travel in the opposite direction of their request.
+		k := key{record.In, e.ID}
+		if direction == record.In {
+			k.direction = record.Out
+		}
+		if r, ok := in.pending[k]; ok {
+			e.Method, e.Latency, e.Cancelled = r.method, e.Time.Sub(r.time), r.cancelled
+			delete(in.pending, k)
+		}
+	}
+	return e
+}
+
+func (in *Inspector) observe(e Event) {
+	for _, o := range in.observers {
+		o.Observe(e)
+	}
+}
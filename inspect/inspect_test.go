@@ -0,0 +1,191 @@
+package inspect
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/client"
+	"github.com/goodgophers/golsp-sdk/record"
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer() *server.Server {
+	s := server.NewServer(context.Background())
+	s.Capabilities().HoverProvider = true
+
+	s.On("textDocument/hover", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		time.Sleep(5 * time.Millisecond)
+		return lsp.Hover{Contents: []lsp.MarkedString{lsp.RawMarkedString("func main()")}}, nil
+	})
+	return s
+}
+
+// inspect runs a session of the client package against newTestServer through
+// an inspector reporting to observers.
+func inspect(t *testing.T, observers ...Observer) {
+	t.Helper()
+
+	serverConn, inspectorServerConn := net.Pipe()
+	go func() {
+		_ = newTestServer().Serve(serverConn)
+	}()
+	clientConn, inspectorClientConn := net.Pipe()
+	ran := make(chan error, 1)
+	go func() {
+		// The server exits on exit, and its input isn't closed meanwhile.
+		ran <- New(observers...).Run(inspectorClientConn, nopCloser{inspectorServerConn}, inspectorServerConn)
+	}()
+
+	ctx := context.Background()
+	c := client.New(ctx, clientConn)
+	_, err := c.Initialize(ctx, client.InitializeParams{})
+	assert.NoError(t, err)
+	_, err = c.Hover(ctx, uri.File("/main.go"), lsp.Position{})
+	assert.NoError(t, err)
+	assert.Error(t, c.Call(ctx, "test/unknown", nil, nil))
+	assert.NoError(t, c.Close())
+
+	select {
+	case err := <-ran:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("inspector still running after the server exited")
+	}
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func TestInspector(t *testing.T) {
+	var trace Trace
+	var printed bytes.Buffer
+	inspect(t, &trace, NewPrinter(&printed, false))
+
+	type summary struct {
+		Direction record.Direction
+		Kind      Kind
+		Method    string
+		ID        string
+	}
+	var summaries []summary
+	for _, e := range trace.Events() {
+		if e.Method == "window/logMessage" {
+			continue
+		}
+		summaries = append(summaries, summary{e.Direction, e.Kind, e.Method, e.ID})
+		if e.Kind == Response {
+			assert.True(t, e.Latency > 0, "latency of %s", e.Method)
+		}
+	}
+	assert.Equal(t, []summary{
+		{record.In, Request, "initialize", "1"},
+		{record.Out, Response, "initialize", "1"},
+		{record.In, Notification, "initialized", ""},
+		{record.In, Request, "textDocument/hover", "2"},
+		{record.Out, Response, "textDocument/hover", "2"},
+		{record.In, Request, "test/unknown", "3"},
+		{record.Out, Response, "test/unknown", "3"},
+		{record.In, Request, "shutdown", "4"},
+		{record.Out, Response, "shutdown", "4"},
+		{record.In, Notification, "exit", ""},
+	}, summaries)
+
+	assert.Contains(t, printed.String(), " --> request initialize #1\n")
+	assert.Regexp(t, ` <-- response textDocument/hover #2 in [0-9.]+ms\n`, printed.String())
+	assert.Regexp(t, ` <-- response test/unknown #3 in [0-9.]+[µm]?s: error -32601: Method not found\n`, printed.String())
+}
+
+func TestPrinterBodies(t *testing.T) {
+	var printed bytes.Buffer
+	message := fastjson.RawMessage(`{"jsonrpc":"2.0","method":"exit"}`)
+	NewPrinter(&printed, true).Observe(Event{
+		Entry: record.Entry{Time: time.Now(), Direction: record.In, Message: &message},
+		Kind:  Notification, Method: "exit",
+	})
+	assert.Regexp(t, `^\d\d:\d\d:\d\d\.\d{3} --> notification exit\n\t\{\n\t  "jsonrpc": "2.0",\n\t  "method": "exit"\n\t\}\n$`, printed.String())
+}
+
+func TestEvents(t *testing.T) {
+	in := New()
+	for _, tc := range []struct {
+		Direction record.Direction
+		Message   string
+		Expected  Event
+	}{
+		{record.In, `{"jsonrpc":"2.0","id":"a","method":"textDocument/hover"}`, Event{Kind: Request, ID: `"a"`, Method: "textDocument/hover"}},
+		{record.In, `{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":"a"}}`, Event{Kind: Notification, Method: "$/cancelRequest"}},
+		{record.Out, `{"jsonrpc":"2.0","id":"a","error":{"code":-32800,"message":"Request cancelled"}}`, Event{Kind: Response, ID: `"a"`, Method: "textDocument/hover", Cancelled: true, Error: &jsonrpc.Error{Code: -32800, Message: "Request cancelled"}}},
+		{record.Out, `{"jsonrpc":"2.0","id":"a","result":null}`, Event{Kind: Response, ID: `"a"`}},
+		{record.Out, `{"jsonrpc":"2.0","id":1,"method":"workspace/configuration"}`, Event{Kind: Request, ID: "1", Method: "workspace/configuration"}},
+		{record.In, `{"jsonrpc":"2.0","id":1,"result":[null]}`, Event{Kind: Response, ID: "1", Method: "workspace/configuration"}},
+		{record.Out, `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"Parse error"}}`, Event{Kind: Response, ID: "null", Error: &jsonrpc.Error{Code: -32700, Message: "Parse error"}}},
+		{record.In, `{"jsonrpc":"2.0","method":1}`, Event{Kind: Invalid}},
+	} {
+		e := in.event(tc.Direction, []byte(tc.Message))
+		assert.Equal(t, tc.Direction, e.Direction, tc.Message)
+		assert.Equal(t, tc.Message, string(e.Data()))
+		e.Entry, e.Latency = record.Entry{}, 0
+		assert.Equal(t, tc.Expected, e, tc.Message)
+	}
+
+	e := in.event(record.In, []byte("{invalid}"))
+	assert.Equal(t, Invalid, e.Kind)
+	assert.Nil(t, e.Message)
+	assert.Equal(t, "{invalid}", e.Raw)
+}
+
+func TestJSONTrace(t *testing.T) {
+	var b bytes.Buffer
+	var trace Trace
+	w := NewJSONWriter(&b)
+	inspect(t, w, &trace)
+	assert.NoError(t, w.Err())
+
+	events, err := ReadTrace(bytes.NewReader(b.Bytes()))
+	assert.NoError(t, err)
+	if assert.Len(t, events, len(trace.Events())) {
+		for i, e := range trace.Events() {
+			assert.True(t, e.Time.Equal(events[i].Time))
+			events[i].Time = e.Time
+		}
+		assert.Equal(t, trace.Events(), events)
+	}
+
+	// JSON traces are recordings.
+	entries, err := record.Read(bytes.NewReader(b.Bytes()))
+	assert.NoError(t, err)
+	report, err := record.Replay(context.Background(), newTestServer(), entries)
+	assert.NoError(t, err)
+	assert.True(t, report.OK(), report.String())
+	assert.Equal(t, 4, report.Responses)
+}
+
+func TestWriteHTML(t *testing.T) {
+	var trace Trace
+	inspect(t, &trace)
+
+	var b bytes.Buffer
+	assert.NoError(t, WriteHTML(&b, trace.Events()))
+	html := b.String()
+	assert.Contains(t, html, "<td>initialize</td>")
+	assert.Regexp(t, `<tr id="m0" class="in">`, html)
+	assert.Contains(t, html, `<a href="#m1">1</a>`)
+	assert.Contains(t, html, `<a href="#m0">1</a>`)
+	assert.Regexp(t, `<tr><td>test/unknown</td><td class="number">1</td><td class="number">1</td>`, html)
+	assert.Contains(t, html, "error -32601: Method not found")
+	assert.Contains(t, html, "&#34;jsonrpc&#34;: &#34;2.0&#34;")
+}
@@ -0,0 +1,86 @@
+package inspect
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/record"
+)
+
+// Printer pretty-prints events as they are observed, e.g.
+//
+//	12:03:15.042 --> request initialize #1
+//	12:03:15.160 <-- response initialize #1 in 118ms
+//	12:03:15.161 <-- notification window/logMessage
+//
+// where --> marks the messages sent by the client, and <-- the ones sent by
+// the server. The messages are printed below as indented JSON if bodies are
+// enabled.
+type Printer struct {
+	mu     sync.Mutex
+	w      io.Writer
+	bodies bool
+}
+
+// NewPrinter returns a printer writing to w, printing the messages themselves
+// if bodies is set.
+func NewPrinter(w io.Writer, bodies bool) *Printer {
+	return &Printer{w: w, bodies: bodies}
+}
+
+// Observe prints e.
+func (p *Printer) Observe(e Event) {
+	var b bytes.Buffer
+	b.WriteString(Summary(e))
+	b.WriteByte('\n')
+	if p.bodies {
+		var body bytes.Buffer
+		if err := json.Indent(&body, e.Data(), "\t", "  "); err != nil {
+			body.Reset()
+			body.Write(e.Data())
+		}
+		b.WriteByte('\t')
+		b.Write(body.Bytes())
+		b.WriteByte('\n')
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, _ = p.w.Write(b.Bytes())
+}
+
+// Summary returns a one-line summary of e: when it was observed, its
+// direction, kind and method, the ID of requests and responses, and the
+// latency and error of responses.
+func Summary(e Event) string {
+	var b strings.Builder
+	b.WriteString(e.Time.Local().Format("15:04:05.000"))
+	if e.Direction == record.In {
+		b.WriteString(" --> ")
+	} else {
+		b.WriteString(" <-- ")
+	}
+	b.WriteString(string(e.Kind))
+	if e.Method != "" {
+		b.WriteString(" " + e.Method)
+	}
+	if e.ID != "" {
+		b.WriteString(" #" + strings.Trim(e.ID, `"`))
+	}
+	if e.Kind == Response && e.Method != "" {
+		fmt.Fprintf(&b, " in %v", e.Latency.Round(time.Microsecond))
+	}
+	if e.Cancelled {
+		b.WriteString(" (cancelled)")
+	}
+	if e.Error != nil {
+		fmt.Fprintf(&b, ": error %d: %s", e.Error.Code, e.Error.Message)
+	}
+	return b.String()
+}
@@ -0,0 +1,239 @@
+package inspect
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/record"
+	"github.com/intel-go/fastjson"
+)
+
+// JSONWriter writes events as JSON lines as they are observed. The traces it
+// writes are read by ReadTrace, and are recordings which record.Read reads.
+type JSONWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+// NewJSONWriter returns a JSONWriter writing to w.
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{w: w}
+}
+
+// Observe writes e, unless writing a previous event failed.
+func (j *JSONWriter) Observe(e Event) {
+	line, err := fastjson.Marshal(e)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.err != nil {
+		return
+	}
+	if j.err = err; err == nil {
+		_, j.err = j.w.Write(append(line, '\n'))
+	}
+}
+
+// Err returns the first error writing an event.
+func (j *JSONWriter) Err() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.err
+}
+
+// ReadTrace reads the events of a trace written by a JSONWriter.
+func ReadTrace(r io.Reader) ([]Event, error) {
+	var events []Event
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var e Event
+		if err := fastjson.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("[inspect] line %d: %v", line, err)
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
+
+// Trace collects events, e.g. to write them as an HTML report once the
+// session is over.
+type Trace struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// Observe adds e to the trace.
+func (t *Trace) Observe(e Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.events = append(t.events, e)
+}
+
+// Events returns the events observed so far.
+func (t *Trace) Events() []Event {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]Event(nil), t.events...)
+}
+
+// htmlEvent is an event as shown in HTML reports.
+type htmlEvent struct {
+	Event
+	Index    int    // the index of the event, which rows are anchored by
+	Request  int    // for responses, the index of their request, or -1
+	Response int    // for requests, the index of their response, or -1
+	Body     string // the message, as indented JSON
+}
+
+// methodStats are the statistics of the responses to the requests of a
+// method.
+type methodStats struct {
+	Method             string
+	Count, Errors      int
+	Mean, P95, Maximum time.Duration
+}
+
+// WriteHTML writes a self-contained HTML report of events to w: statistics of
+// the latency of responses per method, then the messages, each with a link to
+// the request or response it is paired with.
+func WriteHTML(w io.Writer, events []Event) error {
+	rows := make([]htmlEvent, len(events))
+	requests := make(map[key]int)
+	latencies := make(map[string][]time.Duration)
+	stats := make(map[string]*methodStats)
+	for i, e := range events {
+		rows[i] = htmlEvent{Event: e, Index: i, Request: -1, Response: -1, Body: indent(e.Data())}
+
+		switch e.Kind {
+		case Request:
+			requests[key{e.Direction, e.ID}] = i
+		case Response:
+			k := key{record.In, e.ID}
+			if e.Direction == record.In {
+				k.direction = record.Out
+			}
+			if j, ok := requests[k]; ok {
+				rows[i].Request, rows[j].Response = j, i
+				delete(requests, k)
+			}
+			if e.Method == "" {
+				continue
+			}
+			s, ok := stats[e.Method]
+			if !ok {
+				s = &methodStats{Method: e.Method}
+				stats[e.Method] = s
+			}
+			s.Count++
+			if e.Error != nil {
+				s.Errors++
+			}
+			latencies[e.Method] = append(latencies[e.Method], e.Latency)
+		}
+	}
+
+	var methods []*methodStats
+	for method, s := range stats {
+		l := latencies[method]
+		sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+		var total time.Duration
+		for _, d := range l {
+			total += d
+		}
+		s.Mean, s.P95, s.Maximum = total/time.Duration(len(l)), l[(len(l)*95+99)/100-1], l[len(l)-1]
+		methods = append(methods, s)
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Method < methods[j].Method })
+
+	return htmlTemplate.Execute(w, struct {
+		Events  []htmlEvent
+		Methods []*methodStats
+	}{rows, methods})
+}
+
+func indent(data []byte) string {
+	var b bytes.Buffer
+	if err := json.Indent(&b, data, "", "  "); err != nil {
+		return string(data)
+	}
+	return b.String()
+}
+
+var htmlTemplate = template.Must(template.New("trace").Funcs(template.FuncMap{
+	"time": func(t time.Time) string {
+		return t.Local().Format("15:04:05.000")
+	},
+	"latency": func(d time.Duration) string {
+		return d.Round(time.Microsecond).String()
+	},
+	"arrow": func(d record.Direction) string {
+		if d == record.In {
+			return "client → server"
+		}
+		return "server → client"
+	},
+	"trim": func(id string) string {
+		return strings.Trim(id, `"`)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>LSP trace</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { text-align: left; padding: 0.2em 0.8em; border-bottom: 1px solid #ddd; vertical-align: top; }
+td.number { text-align: right; }
+tr.in { background: #f4f8ff; }
+tr.out { background: #f6fff4; }
+tr.error td { color: #b00020; }
+tr:target { outline: 2px solid #f0a000; }
+pre { margin: 0.4em 0; font-size: 12px; }
+summary { cursor: pointer; }
+</style>
+</head>
+<body>
+<h1>LSP trace</h1>
+{{with .Methods}}
+<h2>Responses</h2>
+<table>
+<tr><th>Method</th><th>Responses</th><th>Errors</th><th>Mean</th><th>p95</th><th>Maximum</th></tr>
+{{range .}}<tr><td>{{.Method}}</td><td class="number">{{.Count}}</td><td class="number">{{.Errors}}</td><td class="number">{{latency .Mean}}</td><td class="number">{{latency .P95}}</td><td class="number">{{latency .Maximum}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>Messages</h2>
+<table>
+<tr><th>Time</th><th>Direction</th><th>Kind</th><th>Method</th><th>ID</th><th>Latency</th><th>Message</th></tr>
+{{range .Events}}<tr id="m{{.Index}}" class="{{.Direction}}{{if .Error}} error{{end}}">
+<td>{{time .Time}}</td>
+<td>{{arrow .Direction}}</td>
+<td>{{.Kind}}{{if .Cancelled}} (cancelled){{end}}</td>
+<td>{{.Method}}</td>
+<td>{{if ge .Request 0}}<a href="#m{{.Request}}">{{trim .ID}}</a>{{else if ge .Response 0}}<a href="#m{{.Response}}">{{trim .ID}}</a>{{else}}{{trim .ID}}{{end}}</td>
+<td>{{if ge .Request 0}}{{latency .Latency}}{{end}}</td>
+<td><details><summary>{{with .Error}}error {{.Code}}: {{.Message}}{{else}}{{len .Data}} bytes{{end}}</summary><pre>{{.Body}}</pre></details></td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,515 @@
+// Package rpc implements the JSON-RPC connections of the servers and clients
+// of the SDK: messages are exchanged over a stream framed by package wire,
+// the requests and notifications of the peer are dispatched to a Handler, and
+// the requests sent to the peer are matched with their responses.
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/goodgophers/golsp-sdk/record"
+	"github.com/goodgophers/golsp-sdk/wire"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+)
+
+// ErrorCodeRequestCancelled is returned for requests cancelled by the peer
+// through $/cancelRequest.
+const ErrorCodeRequestCancelled jsonrpc.ErrorCode = -32800
+
+// ErrRequestCancelled returns a request cancelled error.
+func ErrRequestCancelled() *jsonrpc.Error {
+	return &jsonrpc.Error{
+		Code:    ErrorCodeRequestCancelled,
+		Message: "Request cancelled",
+	}
+}
+
+// Message is any JSON-RPC message: a request, a notification or a response.
+type Message struct {
+	Version string               `json:"jsonrpc"`
+	ID      *fastjson.RawMessage `json:"id,omitempty"`
+	Method  string               `json:"method,omitempty"`
+	Params  *fastjson.RawMessage `json:"params,omitempty"`
+	Result  *fastjson.RawMessage `json:"result,omitempty"`
+	Error   *jsonrpc.Error       `json:"error,omitempty"`
+}
+
+// Request is a request or, without ID, a notification sent to the peer.
+type Request struct {
+	Version string               `json:"jsonrpc"`
+	ID      *fastjson.RawMessage `json:"id,omitempty"`
+	Method  string               `json:"method"`
+	Params  interface{}          `json:"params,omitempty"`
+}
+
+// Response is a successful response. Unlike jsonrpc.Response, the result is
+// never omitted, as a null result is meaningful in LSP.
+type Response struct {
+	Version string               `json:"jsonrpc"`
+	ID      *fastjson.RawMessage `json:"id"`
+	Result  interface{}          `json:"result"`
+}
+
+// ErrorResponse is an error response. Its ID is null when the request ID
+// could not be determined.
+type ErrorResponse struct {
+	Version string               `json:"jsonrpc"`
+	ID      *fastjson.RawMessage `json:"id"`
+	Error   *jsonrpc.Error       `json:"error"`
+}
+
+// Handler handles the requests and notifications of the peer.
+type Handler interface {
+	// Handle handles msg, a request or a notification. The result or error
+	// of a request is sent to the peer, those of a notification are dropped.
+	Handle(ctx context.Context, msg *Message) (interface{}, *jsonrpc.Error)
+}
+
+// HandlerFunc adapts a function to the Handler interface.
+type HandlerFunc func(ctx context.Context, msg *Message) (interface{}, *jsonrpc.Error)
+
+// Handle calls f.
+func (f HandlerFunc) Handle(ctx context.Context, msg *Message) (interface{}, *jsonrpc.Error) {
+	return f(ctx, msg)
+}
+
+// Options configure a Conn. Hooks are optional.
+type Options struct {
+	// Strict makes the connection answer invalid messages with errors, as
+	// servers do, rather than drop them.
+	Strict bool
+	// Sync reports whether the requests of method are handled in order with
+	// notifications, rather than each in its own goroutine.
+	Sync func(method string) bool
+	// ErrClosed is returned when sending a message once the connection is
+	// closed.
+	ErrClosed error
+
+	// Record is called with each message read, before it is decoded, and
+	// with each message written, in the order they are written.
+	Record func(direction record.Direction, data []byte)
+	// Received is called with each valid message read, before it is routed.
+	Received func(msg *Message)
+	// Sending is called with each message before it is written: a Request, a
+	// Response or an ErrorResponse. The function it returns, if any, is called
+	// once the message is written.
+	Sending func(v interface{}) (sent func())
+	// ReplyFailed is called when a response cannot be written while the
+	// connection is open.
+	ReplyFailed func(err error)
+}
+
+// Conn is a JSON-RPC connection to a peer over a stream.
+//
+// Incoming messages are read by a single goroutine. Responses to the requests
+// sent to the peer are delivered immediately, while requests and notifications
+// are queued for dispatch: notifications are handled one at a time in the
+// order they were received, and requests are each handled in their own
+// goroutine. Handlers can thus send requests to the peer and wait for the
+// responses.
+type Conn struct {
+	rwc     io.ReadWriteCloser
+	reader  *bufio.Reader
+	handler Handler
+	opts    Options
+
+	writeMu sync.Mutex
+	seq     int64
+	wg      sync.WaitGroup
+
+	mu       sync.Mutex
+	closed   bool
+	err      error
+	pending  map[string]chan *Message
+	inflight map[string]context.CancelFunc
+	queue    []*Message
+	wake     chan struct{}
+	done     chan struct{}
+}
+
+// New returns a connection to a peer over rwc, served by Serve, whose
+// messages are handled by h.
+func New(rwc io.ReadWriteCloser, h Handler, opts Options) *Conn {
+	if opts.ErrClosed == nil {
+		opts.ErrClosed = errors.New("[rpc] connection closed")
+	}
+	return &Conn{
+		rwc:      rwc,
+		reader:   bufio.NewReader(rwc),
+		handler:  h,
+		opts:     opts,
+		pending:  make(map[string]chan *Message),
+		inflight: make(map[string]context.CancelFunc),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+}
+
+// Serve reads and dispatches messages until the stream is closed or ctx is
+// cancelled, which handlers may do to stop dispatching the messages following
+// theirs. rwc is closed, and the handlers have returned, when Serve returns.
+func (c *Conn) Serve(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+		c.rwc.Close()
+	}()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.dispatch(ctx)
+	}()
+
+	err := c.read()
+	if ctx.Err() != nil {
+		// The stream was closed on purpose.
+		err = nil
+	}
+
+	cancel()
+	c.close(err)
+	c.wg.Wait()
+	close(c.done)
+
+	return err
+}
+
+// Done returns a channel closed once Serve returns.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the error Serve returned, once it did.
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.err
+}
+
+func (c *Conn) read() error {
+	for {
+		data, err := wire.ReadMessage(c.reader)
+		if err != nil {
+			if _, ok := err.(*wire.HeaderError); ok {
+				rpcErr := jsonrpc.ErrParse()
+				rpcErr.Data = err.Error()
+				c.invalid(nil, rpcErr)
+				return err
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		c.receive(data)
+	}
+}
+
+// receive routes an incoming message.
+func (c *Conn) receive(data []byte) {
+	if c.opts.Record != nil {
+		c.opts.Record(record.In, data)
+	}
+
+	// fastjson accepts some invalid JSON, which is validated first.
+	if !json.Valid(data) {
+		c.invalid(nil, jsonrpc.ErrParse())
+		return
+	}
+	var msg Message
+	if err := fastjson.Unmarshal(data, &msg); err != nil {
+		// Valid JSON that is not a message, such as a batch, which LSP does
+		// not support, is an invalid request.
+		c.invalid(nil, jsonrpc.ErrInvalidRequest())
+		return
+	}
+	if msg.Version != jsonrpc.Version || !validID(msg.ID) {
+		c.invalid(nil, jsonrpc.ErrInvalidRequest())
+		return
+	}
+	if c.opts.Received != nil {
+		c.opts.Received(&msg)
+	}
+
+	switch {
+	case msg.Method == "" && msg.ID != nil:
+		c.deliver(&msg)
+	case msg.Method == "$/cancelRequest":
+		c.cancel(msg.Params)
+	case msg.Method != "":
+		c.Enqueue(&msg)
+	default:
+		c.invalid(msg.ID, jsonrpc.ErrInvalidRequest())
+	}
+}
+
+// invalid answers an invalid message with rpcErr if c is strict.
+func (c *Conn) invalid(id *fastjson.RawMessage, rpcErr *jsonrpc.Error) {
+	if c.opts.Strict {
+		c.replyError(id, rpcErr)
+	}
+}
+
+// validID reports whether id is absent, a number or a string.
+func validID(id *fastjson.RawMessage) bool {
+	if id == nil {
+		return true
+	}
+	raw := strings.TrimSpace(string(*id))
+	return raw != "" && (raw[0] == '"' || raw[0] == '-' || (raw[0] >= '0' && raw[0] <= '9'))
+}
+
+// key returns the key of the request identified by id, in both the requests
+// sent and the requests received.
+func key(id *fastjson.RawMessage) string {
+	return strings.TrimSpace(string(*id))
+}
+
+// Enqueue queues msg for dispatch, as if received from the peer.
+func (c *Conn) Enqueue(msg *Message) {
+	c.mu.Lock()
+	c.queue = append(c.queue, msg)
+	c.mu.Unlock()
+
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next blocks until a message is queued, and returns it. It returns false
+// once ctx is done.
+func (c *Conn) next(ctx context.Context) (*Message, bool) {
+	for {
+		c.mu.Lock()
+		if len(c.queue) > 0 {
+			msg := c.queue[0]
+			c.queue = c.queue[1:]
+			c.mu.Unlock()
+			return msg, true
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-c.wake:
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+}
+
+func (c *Conn) dispatch(ctx context.Context) {
+	for ctx.Err() == nil {
+		msg, ok := c.next(ctx)
+		if !ok {
+			return
+		}
+
+		if msg.ID == nil {
+			// Notifications are never answered, even when they fail.
+			_, _ = c.handler.Handle(ctx, msg)
+			continue
+		}
+		c.handleRequest(ctx, msg)
+	}
+}
+
+func (c *Conn) handleRequest(ctx context.Context, msg *Message) {
+	key := key(msg.ID)
+	reqCtx, cancel := context.WithCancel(jsonrpc.WithRequestID(ctx, msg.ID))
+	c.mu.Lock()
+	c.inflight[key] = cancel
+	c.mu.Unlock()
+
+	handle := func() {
+		result, rpcErr := c.handler.Handle(reqCtx, msg)
+
+		c.mu.Lock()
+		delete(c.inflight, key)
+		c.mu.Unlock()
+		cancelled := reqCtx.Err() != nil && ctx.Err() == nil
+		cancel()
+
+		switch {
+		case cancelled:
+			c.replyError(msg.ID, ErrRequestCancelled())
+		case rpcErr != nil:
+			c.replyError(msg.ID, rpcErr)
+		default:
+			c.reply(msg.ID, result)
+		}
+	}
+
+	if c.opts.Sync != nil && c.opts.Sync(msg.Method) {
+		handle()
+		return
+	}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		handle()
+	}()
+}
+
+// cancel cancels the context of the request identified in params.
+func (c *Conn) cancel(params *fastjson.RawMessage) {
+	var p struct {
+		ID *fastjson.RawMessage `json:"id"`
+	}
+	if params == nil || fastjson.Unmarshal(*params, &p) != nil || p.ID == nil {
+		return
+	}
+
+	c.mu.Lock()
+	cancel, ok := c.inflight[key(p.ID)]
+	c.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// deliver hands a response over to the call waiting for it.
+func (c *Conn) deliver(msg *Message) {
+	key := key(msg.ID)
+
+	c.mu.Lock()
+	ch, ok := c.pending[key]
+	delete(c.pending, key)
+	c.mu.Unlock()
+
+	if ok {
+		ch <- msg
+	}
+}
+
+func (c *Conn) forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.pending, key)
+}
+
+// close fails the calls waiting for a response.
+func (c *Conn) close(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	c.err = err
+	for key, ch := range c.pending {
+		close(ch)
+		delete(c.pending, key)
+	}
+}
+
+// Closed reports whether the connection is closed.
+func (c *Conn) Closed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.closed
+}
+
+// Call sends a request to the peer and waits for its response, which is
+// decoded into result unless it is nil. Errors returned by the peer are
+// *jsonrpc.Error values.
+//
+// If ctx is cancelled before the peer responds, the request is cancelled with
+// $/cancelRequest and ctx's error is returned.
+func (c *Conn) Call(ctx context.Context, method string, params, result interface{}) error {
+	id := fastjson.RawMessage(strconv.FormatInt(atomic.AddInt64(&c.seq, 1), 10))
+	key := key(&id)
+	ch := make(chan *Message, 1)
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return c.opts.ErrClosed
+	}
+	c.pending[key] = ch
+	c.mu.Unlock()
+
+	if err := c.write(Request{Version: jsonrpc.Version, ID: &id, Method: method, Params: params}); err != nil {
+		c.forget(key)
+		return err
+	}
+
+	select {
+	case msg, ok := <-ch:
+		if !ok {
+			return c.opts.ErrClosed
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil && msg.Result != nil {
+			return fastjson.Unmarshal(*msg.Result, result)
+		}
+		return nil
+
+	case <-ctx.Done():
+		c.forget(key)
+		_ = c.Notify("$/cancelRequest", map[string]interface{}{"id": &id})
+		return ctx.Err()
+	}
+}
+
+// Notify sends a notification to the peer.
+func (c *Conn) Notify(method string, params interface{}) error {
+	if c.Closed() {
+		return c.opts.ErrClosed
+	}
+	return c.write(Request{Version: jsonrpc.Version, Method: method, Params: params})
+}
+
+func (c *Conn) reply(id *fastjson.RawMessage, result interface{}) {
+	c.replyFailed(c.write(Response{Version: jsonrpc.Version, ID: id, Result: result}))
+}
+
+func (c *Conn) replyError(id *fastjson.RawMessage, rpcErr *jsonrpc.Error) {
+	c.replyFailed(c.write(ErrorResponse{Version: jsonrpc.Version, ID: id, Error: rpcErr}))
+}
+
+// replyFailed reports errors writing responses, unless the connection is
+// closed in which case they are expected.
+func (c *Conn) replyFailed(err error) {
+	if err != nil && c.opts.ReplyFailed != nil && !c.Closed() {
+		c.opts.ReplyFailed(err)
+	}
+}
+
+// write sends v to the peer.
+func (c *Conn) write(v interface{}) error {
+	var sent func()
+	if c.opts.Sending != nil {
+		sent = c.opts.Sending(v)
+	}
+
+	c.writeMu.Lock()
+	if c.opts.Record != nil {
+		if data, err := fastjson.Marshal(v); err == nil {
+			c.opts.Record(record.Out, data)
+		}
+	}
+	err := wire.WriteMessage(c.rwc, v)
+	c.writeMu.Unlock()
+
+	if err == nil && sent != nil {
+		sent()
+	}
+	return err
+}
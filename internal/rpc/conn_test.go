@@ -0,0 +1,125 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/wire"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// peer is the other end of a Conn, exchanging raw messages.
+type peer struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (p *peer) send(t *testing.T, message string) {
+	t.Helper()
+	_, err := fmt.Fprintf(p.conn, "Content-Length: %d\r\n\r\n%s", len(message), message)
+	assert.NoError(t, err)
+}
+
+func (p *peer) receive(t *testing.T) map[string]interface{} {
+	t.Helper()
+	data, err := wire.ReadMessage(p.reader)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	var msg map[string]interface{}
+	assert.NoError(t, fastjson.Unmarshal(data, &msg))
+	return msg
+}
+
+// serve serves a Conn handling requests with h until the returned function is
+// called.
+func serve(h HandlerFunc, opts Options) (*Conn, *peer, func()) {
+	local, remote := net.Pipe()
+	c := New(local, h, opts)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_ = c.Serve(ctx)
+	}()
+	return c, &peer{conn: remote, reader: bufio.NewReader(remote)}, func() {
+		cancel()
+		<-c.Done()
+	}
+}
+
+func TestCancel(t *testing.T) {
+	started := make(chan struct{})
+	_, p, stop := serve(func(ctx context.Context, msg *Message) (interface{}, *jsonrpc.Error) {
+		close(started)
+		<-ctx.Done()
+		return nil, nil
+	}, Options{})
+	defer stop()
+
+	// IDs are matched regardless of the spaces around them.
+	p.send(t, `{"jsonrpc":"2.0","id": 7 ,"method":"slow"}`)
+	<-started
+	p.send(t, `{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":7}}`)
+	assert.Equal(t, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      float64(7),
+		"error":   map[string]interface{}{"code": float64(-32800), "message": "Request cancelled"},
+	}, p.receive(t))
+}
+
+func TestCall(t *testing.T) {
+	errClosed := errors.New("closed")
+	c, p, stop := serve(func(ctx context.Context, msg *Message) (interface{}, *jsonrpc.Error) {
+		return nil, nil
+	}, Options{ErrClosed: errClosed})
+
+	called := make(chan error, 1)
+	var result string
+	go func() {
+		called <- c.Call(context.Background(), "ping", nil, &result)
+	}()
+	assert.Equal(t, map[string]interface{}{"jsonrpc": "2.0", "id": float64(1), "method": "ping"}, p.receive(t))
+	p.send(t, `{"jsonrpc":"2.0","id": 1 ,"result":"pong"}`)
+	select {
+	case err := <-called:
+		assert.NoError(t, err)
+		assert.Equal(t, "pong", result)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no response delivered")
+	}
+
+	stop()
+	assert.Equal(t, errClosed, c.Call(context.Background(), "ping", nil, nil))
+}
+
+func TestStrict(t *testing.T) {
+	handled := make(chan string, 1)
+	_, p, stop := serve(func(ctx context.Context, msg *Message) (interface{}, *jsonrpc.Error) {
+		handled <- msg.Method
+		return nil, nil
+	}, Options{Strict: true})
+	defer stop()
+
+	p.send(t, `{"jsonrpc":"1.0","id":1,"method":"ping"}`)
+	assert.Equal(t, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      nil,
+		"error":   map[string]interface{}{"code": float64(-32600), "message": "Invalid Request"},
+	}, p.receive(t))
+
+	// Lenient connections drop invalid messages instead.
+	_, lenient, stopLenient := serve(func(ctx context.Context, msg *Message) (interface{}, *jsonrpc.Error) {
+		handled <- msg.Method
+		return nil, nil
+	}, Options{})
+	defer stopLenient()
+	lenient.send(t, `{"jsonrpc":"1.0","method":"dropped"}`)
+	lenient.send(t, `{"jsonrpc":"2.0","method":"handled"}`)
+	assert.Equal(t, "handled", <-handled)
+}
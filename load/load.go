@@ -0,0 +1,275 @@
+// Package load generates load against language servers, to measure how they
+// behave with many open documents and fast typing.
+//
+// A session is a list of steps, the requests and notifications an editor
+// sends. Synthetic generates sessions opening many documents, typing into
+// them and storming the server with completion and hover requests; Recorded
+// turns a recording of package record into one. Run sends the steps of a
+// session at a configurable rate, with requests in flight concurrently, and
+// reports the latency of the responses per method:
+//
+//	steps := load.Synthetic{Files: 500, Edits: 2000, Completions: 2000, Hovers: 500}.Steps()
+//	report, err := load.RunCommand(ctx, exec.Command("my-server"), steps, load.Options{Rate: 200})
+//	if err != nil {
+//		return err
+//	}
+//	fmt.Println(report)
+//
+// Benchmark runs sessions from Go benchmarks, so that the latency of servers
+// is tracked over time like any other benchmark result.
+package load
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/client"
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/goodgophers/golsp-sdk/uri"
+)
+
+// Step is a message of a session sent to the server.
+type Step struct {
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Request bool            `json:"request,omitempty"` // whether the server responds
+
+	// Wait is the pause before the step, as recorded. It is ignored when
+	// steps are sent at a fixed rate.
+	Wait time.Duration `json:"wait,omitempty"`
+}
+
+// Options configure how the steps of a session are sent.
+type Options struct {
+	// Initialize are the parameters of the initialize request. The root
+	// defaults to file:///load, the directory of synthetic documents.
+	Initialize client.InitializeParams
+
+	// Rate is the number of steps sent per second. When zero, steps are sent
+	// as fast as possible, after the wait recorded for each.
+	//
+	// At a fixed rate, latencies are measured from when requests are due, so
+	// that requests held back by Concurrency count as slow responses rather
+	// than lowering the load.
+	Rate float64
+
+	// Concurrency is the maximum number of requests in flight, 16 by
+	// default. Notifications are always sent in order.
+	Concurrency int
+
+	// Timeout bounds the time waited for each response, 30 seconds by
+	// default. Requests timing out are cancelled and counted as errors.
+	Timeout time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.Initialize.RootURI == "" {
+		o.Initialize.RootURI = uri.File(root)
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 16
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 30 * time.Second
+	}
+	return o
+}
+
+// Run initializes the server c is connected to, then sends it steps as
+// configured by opts, and reports the latency of its responses. It returns
+// once every request was responded to, leaving the server running.
+func Run(ctx context.Context, c *client.Client, steps []Step, opts Options) (*Report, error) {
+	s := newSession()
+	if err := s.run(ctx, c, steps, opts.withDefaults()); err != nil {
+		return nil, err
+	}
+	return s.report(), nil
+}
+
+// RunServer runs a session against s, served in-process, then closes the
+// client connected to it, which sends shutdown and exit without waiting for s
+// to stop serving. The memory of the report is the heap of the whole process,
+// which includes the client's.
+func RunServer(ctx context.Context, s *server.Server, steps []Step, opts Options) (*Report, error) {
+	r := newSession()
+	if err := r.runServer(ctx, s, steps, opts.withDefaults()); err != nil {
+		return nil, err
+	}
+	return r.report(), nil
+}
+
+// RunCommand starts cmd and runs a session against it, then shuts it down.
+// The memory of the report is the peak resident set size of the process,
+// where the platform reports it.
+func RunCommand(ctx context.Context, cmd *exec.Cmd, steps []Step, opts Options) (*Report, error) {
+	c, err := client.Start(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newSession()
+	err = s.run(ctx, c, steps, opts.withDefaults())
+	if closeErr := c.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	report := s.report()
+	if cmd.ProcessState != nil {
+		report.Memory.MaxRSS = maxRSS(cmd.ProcessState)
+	}
+	return report, nil
+}
+
+// session accumulates the results of one or more sessions.
+type session struct {
+	mu            sync.Mutex
+	latencies     map[string][]time.Duration // of successful requests, by method
+	errors        map[string]int             // by method
+	notifications int
+	elapsed       time.Duration
+	memory        Memory
+}
+
+func newSession() *session {
+	return &session{latencies: make(map[string][]time.Duration), errors: make(map[string]int)}
+}
+
+// runServer runs a session against s, served in-process over a pipe.
+func (s *session) runServer(ctx context.Context, srv *server.Server, steps []Step, opts Options) error {
+	var before runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	clientConn, serverConn := net.Pipe()
+	go func() {
+		_ = srv.Serve(serverConn)
+	}()
+	c := client.New(ctx, clientConn)
+	defer c.Close()
+
+	if err := s.run(ctx, c, steps, opts); err != nil {
+		return err
+	}
+
+	var after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.memory.HeapInUse = after.HeapInuse
+	s.memory.TotalAlloc += after.TotalAlloc - before.TotalAlloc
+	return nil
+}
+
+// run initializes the server c is connected to and sends it steps.
+func (s *session) run(ctx context.Context, c *client.Client, steps []Step, opts Options) error {
+	if _, err := c.Initialize(ctx, opts.Initialize); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	inflight := make(chan struct{}, opts.Concurrency)
+	var wg sync.WaitGroup
+	var err error
+	for i, step := range steps {
+		var due time.Time
+		if due, err = pace(ctx, start, opts.Rate, step.Wait, i); err != nil {
+			break
+		}
+
+		if !step.Request {
+			if err = c.Notify(ctx, step.Method, params(step)); err != nil {
+				break
+			}
+			s.mu.Lock()
+			s.notifications++
+			s.mu.Unlock()
+			continue
+		}
+
+		select {
+		case inflight <- struct{}{}:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if err != nil {
+			break
+		}
+		wg.Add(1)
+		go func(step Step) {
+			defer func() {
+				<-inflight
+				wg.Done()
+			}()
+			s.call(ctx, c, step, due, opts.Timeout)
+		}(step)
+	}
+	wg.Wait()
+
+	s.mu.Lock()
+	s.elapsed += time.Since(start)
+	s.mu.Unlock()
+	return err
+}
+
+// pace waits until the step at index i is due, and returns when it is: at its
+// time in the schedule starting at start if steps are sent at a fixed rate, or
+// after its wait otherwise. The first step is sent right away.
+func pace(ctx context.Context, start time.Time, rate float64, wait time.Duration, i int) (time.Time, error) {
+	due := time.Now()
+	switch {
+	case rate > 0:
+		due = start.Add(time.Duration(float64(i) * float64(time.Second) / rate))
+	case i > 0:
+		due = due.Add(wait)
+	}
+
+	if d := time.Until(due); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+	return due, ctx.Err()
+}
+
+// call sends the request of step, due at the given time, and records its
+// latency since then, or its failure.
+func (s *session) call(ctx context.Context, c *client.Client, step Step, due time.Time, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := c.Call(ctx, step.Method, params(step), nil)
+	latency := time.Since(due)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Failures, such as timeouts, would skew the latencies of responses.
+	if err != nil {
+		s.errors[step.Method]++
+		return
+	}
+	s.latencies[step.Method] = append(s.latencies[step.Method], latency)
+}
+
+// params returns the parameters of step to send them, omitted if empty.
+func params(step Step) interface{} {
+	if len(step.Params) == 0 {
+		return nil
+	}
+	return step.Params
+}
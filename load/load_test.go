@@ -0,0 +1,269 @@
+package load
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/record"
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/goodgophers/golsp-sdk/textedit"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestServer returns a server syncing documents incrementally. Requests run
+// concurrently with the notifications preceding them, so that requests on
+// positions it does not know yet are answered with null results.
+func newTestServer() *server.Server {
+	s := server.NewServer(context.Background())
+	kind := lsp.TDSKIncremental
+	s.Capabilities().TextDocumentSync = &lsp.TextDocumentSyncOptionsOrKind{Kind: &kind}
+	s.Capabilities().HoverProvider = true
+	s.Capabilities().CompletionProvider = &lsp.CompletionOptions{TriggerCharacters: []string{"."}}
+
+	var mu sync.Mutex
+	documents := make(map[lsp.DocumentURI]string)
+	s.On("textDocument/didOpen", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p lsp.DidOpenTextDocumentParams
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		documents[p.TextDocument.URI] = p.TextDocument.Text
+		return nil, nil
+	})
+	s.On("textDocument/didChange", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p lsp.DidChangeTextDocumentParams
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		text := documents[p.TextDocument.URI]
+		for _, change := range p.ContentChanges {
+			var err error
+			if text, err = textedit.Apply(text, []lsp.TextEdit{{Range: *change.Range, NewText: change.Text}}); err != nil {
+				return nil, err
+			}
+		}
+		documents[p.TextDocument.URI] = text
+		return nil, nil
+	})
+	s.On("textDocument/didClose", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p lsp.DidCloseTextDocumentParams
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		delete(documents, p.TextDocument.URI)
+		return nil, nil
+	})
+
+	// word returns the text before the position of a request, or false if
+	// the position is not in an open document.
+	word := func(params *fastjson.RawMessage) (string, bool, error) {
+		var p lsp.TextDocumentPositionParams
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return "", false, err
+		}
+		mu.Lock()
+		text, ok := documents[p.TextDocument.URI]
+		mu.Unlock()
+		if !ok {
+			return "", false, nil
+		}
+		offset, err := textedit.NewMapper(text).Offset(p.Position)
+		if err != nil {
+			return "", false, nil
+		}
+		return text[strings.LastIndexAny(text[:offset], " \t\n")+1 : offset], true, nil
+	}
+	s.On("textDocument/hover", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		w, ok, err := word(params)
+		if err != nil || !ok {
+			return nil, err
+		}
+		return lsp.Hover{Contents: []lsp.MarkedString{lsp.RawMarkedString(w)}}, nil
+	})
+	s.On("textDocument/completion", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		w, ok, err := word(params)
+		if err != nil || !ok {
+			return nil, err
+		}
+		return lsp.CompletionList{Items: []lsp.CompletionItem{{Label: w + "Println"}}}, nil
+	})
+	s.On("textDocument/slow", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	s.On("textDocument/sleep", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		time.Sleep(20 * time.Millisecond)
+		return nil, nil
+	})
+	return s
+}
+
+func TestSynthetic(t *testing.T) {
+	s := Synthetic{Files: 3, Lines: 10, Edits: 50, Completions: 20, Hovers: 10, Seed: 1}
+	steps := s.Steps()
+	assert.Equal(t, steps, s.Steps())
+
+	counts := make(map[string]int)
+	for _, step := range steps {
+		counts[step.Method]++
+		assert.Equal(t, step.Method == "textDocument/completion" || step.Method == "textDocument/hover", step.Request, step.Method)
+	}
+	assert.Equal(t, map[string]int{
+		"textDocument/didOpen":    3,
+		"textDocument/didChange":  50,
+		"textDocument/completion": 20,
+		"textDocument/hover":      10,
+		"textDocument/didClose":   3,
+	}, counts)
+
+	assert.Equal(t, "textDocument/didOpen", steps[0].Method)
+	assert.JSONEq(t, `{"textDocument":{"uri":"file:///load/file0.go"}}`, string(steps[len(steps)-3].Params))
+	assert.NotContains(t, string(steps[3].Params), "null")
+}
+
+func TestRunServer(t *testing.T) {
+	steps := Synthetic{Files: 5, Lines: 20, Edits: 200, Completions: 100, Hovers: 50}.Steps()
+	r, err := RunServer(context.Background(), newTestServer(), steps, Options{Concurrency: 4})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, 150, r.Requests)
+	assert.Equal(t, 210, r.Notifications)
+	assert.Equal(t, 0, r.Errors)
+	if !assert.Len(t, r.Methods, 2) {
+		return
+	}
+	assert.Equal(t, "textDocument/completion", r.Methods[0].Method)
+	assert.Equal(t, 100, r.Methods[0].Count)
+	assert.Equal(t, "textDocument/hover", r.Methods[1].Method)
+	assert.Equal(t, 50, r.Methods[1].Count)
+
+	assert.Equal(t, "total", r.Latency.Method)
+	assert.Equal(t, 150, r.Latency.Count)
+	assert.True(t, r.Latency.P50 > 0)
+	assert.True(t, r.Latency.P50 <= r.Latency.P95 && r.Latency.P95 <= r.Latency.P99 && r.Latency.P99 <= r.Latency.Max)
+	assert.True(t, r.Throughput() > 0)
+	assert.True(t, r.Memory.HeapInUse > 0)
+	assert.True(t, r.Memory.TotalAlloc > 0)
+}
+
+func TestRunErrors(t *testing.T) {
+	steps := []Step{
+		{Method: "textDocument/hover", Params: json.RawMessage(`[]`), Request: true},
+		{Method: "textDocument/slow", Request: true},
+		{Method: "textDocument/unknown", Request: true},
+	}
+	r, err := RunServer(context.Background(), newTestServer(), steps, Options{Timeout: 50 * time.Millisecond})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, 3, r.Requests)
+	assert.Equal(t, 3, r.Errors)
+	for _, m := range r.Methods {
+		assert.Equal(t, 1, m.Count, m.Method)
+		assert.Equal(t, 1, m.Errors, m.Method)
+	}
+	// Failed requests, such as the one timing out, have no latency.
+	assert.Equal(t, time.Duration(0), r.Latency.Max)
+}
+
+func TestRate(t *testing.T) {
+	steps := Synthetic{Files: 1, Lines: 5, Hovers: 9}.Steps()
+	r, err := RunServer(context.Background(), newTestServer(), steps, Options{Rate: 100})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// 11 steps at 100 per second take at least 100ms.
+	assert.True(t, r.Duration >= 100*time.Millisecond, r.Duration)
+}
+
+func TestRateLatency(t *testing.T) {
+	steps := make([]Step, 5)
+	for i := range steps {
+		steps[i] = Step{Method: "textDocument/sleep", Request: true}
+	}
+	r, err := RunServer(context.Background(), newTestServer(), steps, Options{Rate: 1000, Concurrency: 1})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Requests are due every millisecond, but sent one at a time: the last
+	// one waits for the 4 before it to be responded to.
+	assert.True(t, r.Latency.Max >= 80*time.Millisecond, r.Latency.Max)
+}
+
+func TestRecorded(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	entry := func(ms int, direction record.Direction, message string) record.Entry {
+		m := fastjson.RawMessage(message)
+		return record.Entry{Time: start.Add(time.Duration(ms) * time.Millisecond), Direction: direction, Message: &m}
+	}
+	steps := Recorded([]record.Entry{
+		entry(0, record.In, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`),
+		entry(5, record.Out, `{"jsonrpc":"2.0","id":1,"result":{}}`),
+		entry(10, record.In, `{"jsonrpc":"2.0","method":"initialized","params":{}}`),
+		entry(20, record.In, `{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///a.go"}}}`),
+		entry(25, record.Out, `{"jsonrpc":"2.0","id":1,"method":"workspace/configuration","params":{}}`),
+		entry(30, record.In, `{"jsonrpc":"2.0","id":1,"result":[]}`),
+		entry(70, record.In, `{"jsonrpc":"2.0","id":2,"method":"textDocument/hover","params":{"position":{}}}`),
+		{Time: start.Add(80 * time.Millisecond), Direction: record.In, Raw: "garbage"},
+		entry(90, record.In, `{"jsonrpc":"2.0","id":3,"method":"shutdown"}`),
+	})
+
+	assert.Equal(t, []Step{
+		{Method: "textDocument/didOpen", Params: json.RawMessage(`{"textDocument":{"uri":"file:///a.go"}}`)},
+		{Method: "textDocument/hover", Params: json.RawMessage(`{"position":{}}`), Request: true, Wait: 50 * time.Millisecond},
+	}, steps)
+}
+
+func TestReportString(t *testing.T) {
+	r := &Report{
+		Duration:      2 * time.Second,
+		Requests:      4,
+		Notifications: 3,
+		Methods: []MethodStats{
+			statistics("textDocument/hover", []time.Duration{4 * time.Millisecond, time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond}, 0),
+		},
+		Memory: Memory{HeapInUse: 3 << 20, TotalAlloc: 1536},
+	}
+	r.Latency = r.Methods[0]
+	r.Latency.Method = "total"
+
+	assert.Equal(t, ""+
+		"method              requests  errors  mean   p50  p95  p99  max\n"+
+		"textDocument/hover  4         0       2.5ms  2ms  4ms  4ms  4ms\n"+
+		"total               4         0       2.5ms  2ms  4ms  4ms  4ms\n"+
+		"\n"+
+		"4 requests, 3 notifications in 2s: 2.0 requests/s\n"+
+		"memory: 3.0 MiB in use, 1.5 KiB allocated\n", r.String())
+}
+
+func TestPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := 100; i > 0; i-- {
+		latencies = append(latencies, time.Duration(i))
+	}
+	stats := statistics("m", latencies, 0)
+	assert.Equal(t, MethodStats{Method: "m", Count: 100, Mean: 50, P50: 50, P95: 95, P99: 99, Max: 100}, stats)
+}
+
+func BenchmarkSynthetic(b *testing.B) {
+	steps := Synthetic{Files: 20, Lines: 100, Edits: 500, Completions: 200, Hovers: 100}.Steps()
+	Benchmark(b, newTestServer, steps, Options{})
+}
@@ -0,0 +1,12 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package load
+
+import "os"
+
+// maxRSS returns 0: the peak resident set size of processes isn't reported
+// on this platform.
+func maxRSS(state *os.ProcessState) uint64 {
+	return 0
+}
@@ -0,0 +1,214 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"text/tabwriter"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/server"
+)
+
+// Report is the result of a load session.
+type Report struct {
+	// Duration is the time taken to send the steps and receive every
+	// response, initialization excluded.
+	Duration time.Duration
+
+	// Requests and Notifications are the number of messages sent, Errors the
+	// number of requests which failed or timed out.
+	Requests, Notifications, Errors int
+
+	// Methods are the statistics of the requests per method, ordered by
+	// method.
+	Methods []MethodStats
+
+	// Latency are the statistics of all requests.
+	Latency MethodStats
+
+	Memory Memory
+}
+
+// MethodStats are the statistics of the latency of the requests of a method.
+// Count is the number of requests sent, Errors the number of them which failed
+// or timed out; latencies are those of the others.
+type MethodStats struct {
+	Method        string
+	Count, Errors int
+
+	Mean, P50, P95, P99, Max time.Duration
+}
+
+// Memory is the memory used by the server, as far as measured.
+type Memory struct {
+	// HeapInUse is the heap in use once the session is over, after garbage
+	// collection, for servers run in-process.
+	HeapInUse uint64
+	// TotalAlloc is the number of bytes allocated during the session, for
+	// servers run in-process.
+	TotalAlloc uint64
+	// MaxRSS is the peak resident set size of server processes.
+	MaxRSS uint64
+}
+
+// Throughput returns the number of requests responded to per second.
+func (r *Report) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Requests) / r.Duration.Seconds()
+}
+
+// String formats r as a table of the statistics per method, followed by the
+// totals, e.g.
+//
+//	method                   requests  errors  mean    p50     p95     p99     max
+//	textDocument/completion  2000      0       1.2ms   1.1ms   2.3ms   4.1ms   9.8ms
+//	textDocument/hover       500       0       310µs   290µs   520µs   880µs   1.4ms
+//
+//	2500 requests, 2500 notifications in 4.2s: 595.2 requests/s
+//	memory: 48.0 MiB in use, 1.2 GiB allocated
+func (r *Report) String() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "method\trequests\terrors\tmean\tp50\tp95\tp99\tmax")
+	for _, m := range append(append([]MethodStats(nil), r.Methods...), r.Latency) {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\n", m.Method, m.Count, m.Errors,
+			round(m.Mean), round(m.P50), round(m.P95), round(m.P99), round(m.Max))
+	}
+	w.Flush()
+
+	fmt.Fprintf(&b, "\n%d requests, %d notifications in %s: %.1f requests/s\n",
+		r.Requests, r.Notifications, round(r.Duration), r.Throughput())
+	switch {
+	case r.Memory.MaxRSS > 0:
+		fmt.Fprintf(&b, "memory: %s peak resident\n", formatBytes(r.Memory.MaxRSS))
+	case r.Memory.HeapInUse > 0:
+		fmt.Fprintf(&b, "memory: %s in use, %s allocated\n", formatBytes(r.Memory.HeapInUse), formatBytes(r.Memory.TotalAlloc))
+	}
+	return b.String()
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, prefix := float64(n)/unit, 0
+	for value >= unit && prefix < 3 {
+		value /= unit
+		prefix++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGT"[prefix])
+}
+
+// report computes the report of the sessions run so far.
+func (s *session) report() *Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := &Report{Duration: s.elapsed, Notifications: s.notifications, Memory: s.memory}
+	methods := make(map[string]bool, len(s.latencies))
+	for method := range s.latencies {
+		methods[method] = true
+	}
+	for method := range s.errors {
+		methods[method] = true
+	}
+
+	var all []time.Duration
+	for method := range methods {
+		latencies := s.latencies[method]
+		stats := statistics(method, latencies, s.errors[method])
+		r.Methods = append(r.Methods, stats)
+		r.Requests += stats.Count
+		r.Errors += stats.Errors
+		all = append(all, latencies...)
+	}
+	sort.Slice(r.Methods, func(i, j int) bool { return r.Methods[i].Method < r.Methods[j].Method })
+	r.Latency = statistics("total", all, r.Errors)
+	return r
+}
+
+// statistics computes the statistics of the requests of a method, given the
+// latencies of the successful ones, which it sorts, and the number of errors.
+func statistics(method string, latencies []time.Duration, errors int) MethodStats {
+	stats := MethodStats{Method: method, Count: len(latencies) + errors, Errors: errors}
+	if len(latencies) == 0 {
+		return stats
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var total time.Duration
+	for _, d := range latencies {
+		total += d
+	}
+	stats.Mean = total / time.Duration(len(latencies))
+	stats.P50 = percentile(latencies, 50)
+	stats.P95 = percentile(latencies, 95)
+	stats.P99 = percentile(latencies, 99)
+	stats.Max = latencies[len(latencies)-1]
+	return stats
+}
+
+// percentile returns the p-th percentile of sorted latencies, by the nearest
+// rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	return sorted[(len(sorted)*p+99)/100-1]
+}
+
+// Benchmark runs b.N sessions of steps against the servers returned by
+// newServer, one per session, and reports the latency of their responses
+// across sessions as metrics of b, besides the time per session:
+//
+//	func BenchmarkTyping(b *testing.B) {
+//		steps := load.Synthetic{Files: 500, Edits: 1000, Completions: 1000}.Steps()
+//		load.Benchmark(b, newServer, steps, load.Options{})
+//	}
+//
+// The metrics are the p50, p95 and p99 latency of all requests in ns, the
+// p95 latency of each method, the requests responded to per second, and the
+// heap in use after the last session.
+func Benchmark(b *testing.B, newServer func() *server.Server, steps []Step, opts Options) *Report {
+	b.Helper()
+	opts = opts.withDefaults()
+
+	s := newSession()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := s.runServer(context.Background(), newServer(), steps, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	r := s.report()
+	r.ReportMetrics(b)
+	return r
+}
+
+// ReportMetrics reports the statistics of r as metrics of b, as documented by
+// Benchmark.
+func (r *Report) ReportMetrics(b *testing.B) {
+	b.ReportMetric(float64(r.Latency.P50), "p50-ns")
+	b.ReportMetric(float64(r.Latency.P95), "p95-ns")
+	b.ReportMetric(float64(r.Latency.P99), "p99-ns")
+	for _, m := range r.Methods {
+		b.ReportMetric(float64(m.P95), m.Method+"-p95-ns")
+	}
+	b.ReportMetric(r.Throughput(), "requests/s")
+	if r.Memory.HeapInUse > 0 {
+		b.ReportMetric(float64(r.Memory.HeapInUse), "heap-B")
+	}
+	if r.Memory.MaxRSS > 0 {
+		b.ReportMetric(float64(r.Memory.MaxRSS), "rss-B")
+	}
+}
@@ -0,0 +1,226 @@
+package load
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/record"
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// root is the directory of synthetic documents.
+const root = "/load"
+
+// Synthetic generates a session opening documents, typing into them and
+// requesting completions and hovers as an editor would, e.g. a completion
+// storm with as many completions as edits. The session is the same for the
+// same parameters.
+type Synthetic struct {
+	// Files is the number of documents opened, 10 by default. They are
+	// closed at the end of the session.
+	Files int
+	// Lines is the number of lines of each document, 100 by default.
+	Lines int
+	// Language is the language of the documents, "go" by default, which the
+	// extension of their names is.
+	Language string
+
+	// Edits is the number of characters typed, each sent as an incremental
+	// change. Typing moves to another line, or another document, from time
+	// to time.
+	Edits int
+	// Completions is the number of completion requests, sent where typing
+	// is; Hovers the number of hover requests, sent anywhere in the
+	// documents. Both are interleaved evenly with the edits.
+	Completions, Hovers int
+
+	// Seed seeds the choices of documents and positions.
+	Seed int64
+}
+
+// Steps returns the steps of the session.
+func (s Synthetic) Steps() []Step {
+	if s.Files <= 0 {
+		s.Files = 10
+	}
+	if s.Lines <= 0 {
+		s.Lines = 100
+	}
+	if s.Language == "" {
+		s.Language = "go"
+	}
+	g := &generator{Synthetic: s, rand: rand.New(rand.NewSource(s.Seed))}
+
+	for i := 0; i < s.Files; i++ {
+		g.open(i)
+	}
+
+	// Completions and hovers are spread among the edits, in the order given
+	// by a shuffle.
+	kinds := make([]byte, 0, s.Edits+s.Completions+s.Hovers)
+	kinds = append(kinds, []byte(strings.Repeat("e", s.Edits))...)
+	kinds = append(kinds, []byte(strings.Repeat("c", s.Completions))...)
+	kinds = append(kinds, []byte(strings.Repeat("h", s.Hovers))...)
+	g.rand.Shuffle(len(kinds), func(i, j int) { kinds[i], kinds[j] = kinds[j], kinds[i] })
+	for _, kind := range kinds {
+		switch kind {
+		case 'e':
+			g.edit()
+		case 'c':
+			g.request("textDocument/completion", g.cursor())
+		case 'h':
+			g.request("textDocument/hover", g.anywhere())
+		}
+	}
+
+	for i := 0; i < s.Files; i++ {
+		g.close(i)
+	}
+	return g.steps
+}
+
+// generator generates a synthetic session, tracking the length of the lines
+// of the documents to send valid positions.
+type generator struct {
+	Synthetic
+	rand  *rand.Rand
+	steps []Step
+
+	lines    [][]int // the length of the lines of each document
+	versions []int
+	file     int          // the document typed into
+	position lsp.Position // where typing is
+}
+
+func (g *generator) uri(i int) lsp.DocumentURI {
+	return uri.File(path.Join(root, fmt.Sprintf("file%d.%s", i, g.Language))).DocumentURI()
+}
+
+func (g *generator) add(method string, params interface{}, request bool) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		panic(fmt.Errorf("[load] %s: %v", method, err))
+	}
+	g.steps = append(g.steps, Step{Method: method, Params: data, Request: request})
+}
+
+func (g *generator) open(i int) {
+	var text strings.Builder
+	lengths := make([]int, g.Lines)
+	for l := range lengths {
+		line := fmt.Sprintf("\tvalue%d := compute(%d, %q)", l, l*i, g.Language)
+		text.WriteString(line + "\n")
+		lengths[l] = len(line)
+	}
+	g.lines = append(g.lines, lengths)
+	g.versions = append(g.versions, 1)
+
+	g.add("textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: g.uri(i), LanguageID: g.Language, Version: 1, Text: text.String()},
+	}, false)
+}
+
+func (g *generator) close(i int) {
+	g.add("textDocument/didClose", lsp.DidCloseTextDocumentParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: g.uri(i)},
+	}, false)
+}
+
+// edit types a character at the end of the line typing is on, after moving
+// to another line one time in ten, and to another document one time in fifty.
+func (g *generator) edit() {
+	switch n := g.rand.Intn(50); {
+	case n == 0:
+		g.file = g.rand.Intn(g.Files)
+		fallthrough
+	case n < 5:
+		g.position.Line = g.rand.Intn(g.Lines)
+	}
+	g.position.Character = g.lines[g.file][g.position.Line]
+
+	const alphabet = "abcdefghijklmnopqrstuvwxyz."
+	g.versions[g.file]++
+	g.add("textDocument/didChange", changeParams{
+		TextDocument: lsp.VersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: g.uri(g.file)},
+			Version:                g.versions[g.file],
+		},
+		ContentChanges: []change{{
+			Range: lsp.Range{Start: g.position, End: g.position},
+			Text:  string(alphabet[g.rand.Intn(len(alphabet))]),
+		}},
+	}, false)
+	g.lines[g.file][g.position.Line]++
+}
+
+// changeParams are the parameters of an incremental didChange notification.
+// lsp.TextDocumentContentChangeEvent would encode a range length.
+type changeParams struct {
+	TextDocument   lsp.VersionedTextDocumentIdentifier `json:"textDocument"`
+	ContentChanges []change                            `json:"contentChanges"`
+}
+
+type change struct {
+	Range lsp.Range `json:"range"`
+	Text  string    `json:"text"`
+}
+
+// cursor returns the position typing is at.
+func (g *generator) cursor() lsp.TextDocumentPositionParams {
+	position := g.position
+	position.Character = g.lines[g.file][position.Line]
+	return lsp.TextDocumentPositionParams{TextDocument: lsp.TextDocumentIdentifier{URI: g.uri(g.file)}, Position: position}
+}
+
+// anywhere returns a random position in a random document.
+func (g *generator) anywhere() lsp.TextDocumentPositionParams {
+	file := g.rand.Intn(g.Files)
+	line := g.rand.Intn(g.Lines)
+	return lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: g.uri(file)},
+		Position:     lsp.Position{Line: line, Character: g.rand.Intn(g.lines[file][line] + 1)},
+	}
+}
+
+func (g *generator) request(method string, params lsp.TextDocumentPositionParams) {
+	g.add(method, params, true)
+}
+
+// Recorded returns the steps of a recorded session: the requests and
+// notifications received by the server, after the wait between them as
+// recorded. The lifecycle messages are left out, as Run initializes the
+// server itself, and so are the responses to the requests of the server.
+func Recorded(entries []record.Entry) []Step {
+	var steps []Step
+	var last time.Time
+	for _, e := range entries {
+		if e.Direction != record.In || e.Message == nil {
+			continue
+		}
+		var msg struct {
+			ID     *json.RawMessage `json:"id"`
+			Method string           `json:"method"`
+			Params json.RawMessage  `json:"params"`
+		}
+		if err := json.Unmarshal(*e.Message, &msg); err != nil || msg.Method == "" {
+			continue
+		}
+		switch msg.Method {
+		case "initialize", "initialized", "shutdown", "exit":
+			continue
+		}
+
+		step := Step{Method: msg.Method, Params: msg.Params, Request: msg.ID != nil}
+		if !last.IsZero() && e.Time.After(last) {
+			step.Wait = e.Time.Sub(last)
+		}
+		last = e.Time
+		steps = append(steps, step)
+	}
+	return steps
+}
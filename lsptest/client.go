@@ -0,0 +1,116 @@
+// Package lsptest runs servers built on the SDK against a fake client, for
+// testing handlers without network or processes.
+//
+// The client is a client.Client connected to the server over an in-memory
+// pipe, so that handlers run exactly as in production:
+//
+//	c := lsptest.New(s)
+//	defer c.Close()
+//
+//	if _, err := c.Initialize(ctx, lsptest.InitializeParams{}); err != nil {
+//		t.Fatal(err)
+//	}
+//	if err := c.OpenDocument(ctx, "file:///main.go", "go", "package main"); err != nil {
+//		t.Fatal(err)
+//	}
+//	hover, err := c.Hover(ctx, "file:///main.go", lsp.Position{Character: 9})
+package lsptest
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/goodgophers/golsp-sdk/client"
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/intel-go/fastjson"
+)
+
+// ErrClosed is returned when sending a message once the connection to the
+// server is closed.
+var ErrClosed = client.ErrClosed
+
+// RequestHandler answers a request sent by the server to the client.
+type RequestHandler func(ctx context.Context, params *fastjson.RawMessage) (result interface{}, err error)
+
+// Client is a fake client connected to a server, with the requests and
+// notifications of client.Client.
+//
+// Requests sent by the server are answered by the handlers registered with
+// HandleRequest; the server's own capability registrations, configuration and
+// progress requests are answered with empty results by default. Notifications
+// sent by the server are captured, see Notifications.
+type Client struct {
+	*client.Client
+	served chan error
+
+	mu            sync.Mutex
+	closed        bool
+	serveErr      error
+	notifications []Notification
+	arrived       chan struct{} // closed and replaced when a notification arrives
+	consumed      map[string]int
+}
+
+// New connects a fake client to s, which serves it until the client is
+// closed.
+func New(s *server.Server) *Client {
+	clientConn, serverConn := net.Pipe()
+
+	c := &Client{
+		Client:   client.New(context.Background(), clientConn),
+		served:   make(chan error, 1),
+		arrived:  make(chan struct{}),
+		consumed: make(map[string]int),
+	}
+	c.OnNotification(func(ctx context.Context, method string, params *fastjson.RawMessage) {
+		c.capture(Notification{Method: method, Params: params})
+	})
+
+	go func() {
+		c.served <- s.Serve(serverConn)
+	}()
+	go func() {
+		<-c.Done()
+		c.close()
+	}()
+
+	return c
+}
+
+// HandleRequest registers h to answer the requests sent by the server for
+// method, replacing the default handler, if any. Requests without handler are
+// answered with a MethodNotFound error.
+func (c *Client) HandleRequest(method string, h RequestHandler) {
+	c.On(method, server.CallbackFunc(h))
+}
+
+// Close shuts the server down, with the shutdown request and the exit
+// notification, then returns the error the server was served with.
+func (c *Client) Close() error {
+	_ = c.Client.Close()
+	return c.wait()
+}
+
+// wait waits for the server to stop serving the client.
+func (c *Client) wait() error {
+	err, ok := <-c.served
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ok {
+		c.serveErr = err
+		close(c.served)
+	}
+	return c.serveErr
+}
+
+// close wakes up the callers waiting for notifications, once the connection
+// is closed.
+func (c *Client) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	close(c.arrived)
+}
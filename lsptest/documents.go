@@ -0,0 +1,40 @@
+package lsptest
+
+import (
+	"context"
+
+	"github.com/goodgophers/golsp-sdk/client"
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// WorkspaceFolder is a workspace folder opened in the client.
+type WorkspaceFolder = client.WorkspaceFolder
+
+// InitializeParams are the parameters of the initialize request sent by the
+// client. Capabilities can be any value encoding to the capabilities object,
+// so that tests can declare any capability, e.g.
+//
+//	Capabilities: map[string]interface{}{
+//		"window": map[string]interface{}{"showDocument": map[string]interface{}{"support": true}},
+//	}
+type InitializeParams = client.InitializeParams
+
+// OpenDocument sends a textDocument/didOpen notification for the document at
+// u, of the given language and content.
+func (c *Client) OpenDocument(ctx context.Context, u uri.URI, languageID, text string) error {
+	return c.DidOpen(ctx, u, languageID, text)
+}
+
+// ChangeDocument sends a textDocument/didChange notification replacing the
+// content of the document at u with text. Versions are incremented from the
+// one the document was opened with.
+func (c *Client) ChangeDocument(ctx context.Context, u uri.URI, text string) error {
+	return c.DidChange(ctx, u, lsp.TextDocumentContentChangeEvent{Text: text})
+}
+
+// CloseDocument sends a textDocument/didClose notification for the document
+// at u.
+func (c *Client) CloseDocument(ctx context.Context, u uri.URI) error {
+	return c.DidClose(ctx, u)
+}
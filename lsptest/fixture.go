@@ -0,0 +1,332 @@
+package lsptest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/intel-go/fastjson"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// DefaultTimeout bounds the requests run at each marker.
+const DefaultTimeout = 10 * time.Second
+
+// MarkerFunc runs the request of a marker against the server, and returns
+// its result, which is recorded in the golden file. Returning an error fails
+// the test of the marker.
+type MarkerFunc func(ctx context.Context, c *Client, m Marker) (interface{}, error)
+
+// Fixtures runs requests at the markers of fixture files against a server,
+// and compares their results with golden files.
+//
+// Each fixture file with markers has a golden file next to it, named after it
+// with the .golden extension. Golden files hold a section per marker, with
+// the result of its request as indented JSON. Setting Update, or running
+// tests with the -update flag of test binaries defining one, rewrites them.
+//
+// Besides the markers handled by Markers, fixtures can use the following
+// markers:
+//
+//	hover()              textDocument/hover at the marker.
+//	hover("text")        same, checking that the contents hold text.
+//	complete("a", "b")   textDocument/completion at the marker, checking that
+//	                     the labels a and b are among the items. The labels of
+//	                     the items are recorded.
+//	diag("text")         checks that the server published a diagnostic whose
+//	                     message holds text, at the marker's range or starting
+//	                     at its position.
+type Fixtures struct {
+	// Dir is the directory holding the fixture files, walked recursively.
+	Dir string
+
+	// NewServer returns the server the fixtures run against.
+	NewServer func() *server.Server
+
+	// InitializeParams are the parameters of the initialize request. The root
+	// URI defaults to Dir.
+	InitializeParams InitializeParams
+
+	// LanguageID returns the language of a fixture file, given its path
+	// relative to Dir. It defaults to the extension of the file, e.g. "go".
+	LanguageID func(file string) string
+
+	// Markers handles markers by name, overriding the built-in markers.
+	Markers map[string]MarkerFunc
+
+	// Timeout bounds the request of each marker, DefaultTimeout if zero.
+	Timeout time.Duration
+
+	// Update rewrites the golden files with the results instead of comparing
+	// them. It defaults to the value of the -update flag, if the test binary
+	// defines one.
+	Update bool
+}
+
+type fixtureFile struct {
+	path    string // relative to Dir
+	uri     uri.URI
+	content string
+	markers []Marker
+}
+
+// Run runs the markers of every fixture file as subtests of t.
+func (f *Fixtures) Run(t *testing.T) {
+	files, err := f.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := f.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	c := New(f.NewServer())
+	defer func() {
+		cancel()
+		if err := c.Close(); err != nil {
+			t.Errorf("[lsptest] serve: %+v", err)
+		}
+	}()
+
+	params := f.InitializeParams
+	if params.RootURI == "" {
+		params.RootURI = uri.File(f.Dir)
+	}
+	if _, err := c.Initialize(ctx, params); err != nil {
+		t.Fatalf("[lsptest] initialize: %+v", err)
+	}
+	for _, file := range files {
+		if err := c.OpenDocument(ctx, file.uri, f.languageID(file.path), file.content); err != nil {
+			t.Fatalf("[lsptest] open %s: %+v", file.path, err)
+		}
+	}
+
+	for _, file := range files {
+		if len(file.markers) == 0 {
+			continue
+		}
+
+		var golden bytes.Buffer
+		for _, m := range file.markers {
+			t.Run(m.String(), func(t *testing.T) {
+				markerCtx, cancel := context.WithTimeout(context.Background(), timeout)
+				defer cancel()
+
+				result, err := f.run(markerCtx, c, m)
+				if err != nil {
+					t.Error(err)
+				}
+				fmt.Fprintf(&golden, "-- %s --\n%s\n", m, formatResult(result))
+			})
+		}
+
+		f.compare(t, file.path+".golden", golden.String())
+	}
+}
+
+// load reads and parses the fixture files of Dir, sorted by path.
+func (f *Fixtures) load() ([]fixtureFile, error) {
+	var files []fixtureFile
+	err := filepath.Walk(f.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasSuffix(path, ".golden") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(f.Dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		file := fixtureFile{path: filepath.ToSlash(rel), uri: uri.File(abs)}
+		if file.markers, file.content, err = ParseMarkers(file.path, file.uri, string(data)); err != nil {
+			return err
+		}
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files, nil
+}
+
+func (f *Fixtures) languageID(file string) string {
+	if f.LanguageID != nil {
+		return f.LanguageID(file)
+	}
+	return strings.TrimPrefix(filepath.Ext(file), ".")
+}
+
+// run runs the request of m.
+func (f *Fixtures) run(ctx context.Context, c *Client, m Marker) (interface{}, error) {
+	if fn, ok := f.Markers[m.Name]; ok {
+		return fn(ctx, c, m)
+	}
+
+	switch m.Name {
+	case "hover":
+		return hoverMarker(ctx, c, m)
+	case "complete":
+		return completeMarker(ctx, c, m)
+	case "diag":
+		return diagMarker(ctx, c, m)
+	}
+	return nil, fmt.Errorf("[lsptest] %s: unknown marker", m)
+}
+
+// updating reports whether the golden files are rewritten.
+func (f *Fixtures) updating() bool {
+	if f.Update {
+		return true
+	}
+	update := flag.Lookup("update")
+	return update != nil && update.Value.String() == "true"
+}
+
+// compare compares got with the golden file at path, relative to Dir, or
+// rewrites it when updating.
+func (f *Fixtures) compare(t *testing.T, path, got string) {
+	golden := filepath.Join(f.Dir, filepath.FromSlash(path))
+	if f.updating() {
+		if err := ioutil.WriteFile(golden, []byte(got), 0644); err != nil {
+			t.Errorf("[lsptest] update %s: %+v", path, err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Errorf("[lsptest] %+v (run with -update to create it)", err)
+		return
+	}
+	if string(want) != got {
+		diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(want)),
+			B:        difflib.SplitLines(got),
+			FromFile: path,
+			ToFile:   "got",
+			Context:  3,
+		})
+		t.Errorf("[lsptest] results differ from %s (run with -update to accept them):\n%s", path, diff)
+	}
+}
+
+// formatResult formats the result of a marker as indented JSON.
+func formatResult(result interface{}) string {
+	data, err := fastjson.Marshal(result)
+	if err != nil {
+		return fmt.Sprintf("error: %v", err)
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, data, "", "  "); err != nil {
+		return string(data)
+	}
+	return indented.String()
+}
+
+func hoverMarker(ctx context.Context, c *Client, m Marker) (interface{}, error) {
+	hover, err := c.Hover(ctx, m.URI, m.Pos)
+	if err != nil || len(m.Args) == 0 {
+		return hover, err
+	}
+
+	text, err := m.StringArg(0)
+	if err != nil {
+		return hover, err
+	}
+	if hover == nil {
+		return hover, fmt.Errorf("[lsptest] %s: no hover, expected %q", m, text)
+	}
+	for _, content := range hover.Contents {
+		if strings.Contains(content.Value, text) {
+			return hover, nil
+		}
+	}
+	return hover, fmt.Errorf("[lsptest] %s: hover doesn't contain %q", m, text)
+}
+
+func completeMarker(ctx context.Context, c *Client, m Marker) (interface{}, error) {
+	list, err := c.Completion(ctx, m.URI, m.Pos)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := []string{}
+	if list != nil {
+		for _, item := range list.Items {
+			labels = append(labels, item.Label)
+		}
+	}
+
+	for i := range m.Args {
+		label, err := m.StringArg(i)
+		if err != nil {
+			return labels, err
+		}
+		found := false
+		for _, l := range labels {
+			found = found || l == label
+		}
+		if !found {
+			return labels, fmt.Errorf("[lsptest] %s: missing completion %q", m, label)
+		}
+	}
+	return labels, nil
+}
+
+func diagMarker(ctx context.Context, c *Client, m Marker) (interface{}, error) {
+	text, err := m.StringArg(0)
+	if err != nil {
+		return nil, err
+	}
+
+	diagnostics, ok := c.Diagnostics(m.URI)
+	if !ok {
+		if diagnostics, err = c.WaitDiagnostics(ctx, m.URI); err != nil {
+			return nil, fmt.Errorf("[lsptest] %s: no diagnostics published: %v", m, err)
+		}
+	}
+
+	var matched []lsp.Diagnostic
+	for _, d := range diagnostics {
+		at := d.Range.Start == m.Pos
+		if m.Range != nil {
+			at = d.Range == *m.Range
+		}
+		if at && strings.Contains(d.Message, text) {
+			matched = append(matched, d)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("[lsptest] %s: no diagnostic containing %q", m, text)
+	}
+	return matched, nil
+}
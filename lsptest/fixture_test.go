@@ -0,0 +1,97 @@
+package lsptest
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"sync"
+	"testing"
+	"unicode"
+
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/goodgophers/golsp-sdk/textedit"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// update rewrites the golden files of the fixtures, lsptest defining no flag
+// of its own.
+var update = flag.Bool("update", false, "update the golden files of fixtures")
+
+// newFixtureServer returns a server hovering words, completing Print
+// functions and reporting TODO comments.
+func newFixtureServer() *server.Server {
+	s := server.NewServer(context.Background())
+	s.Capabilities().HoverProvider = true
+
+	var (
+		mu        sync.Mutex
+		documents = make(map[lsp.DocumentURI]string)
+	)
+	s.On("textDocument/didOpen", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p lsp.DidOpenTextDocumentParams
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		documents[p.TextDocument.URI] = p.TextDocument.Text
+		mu.Unlock()
+
+		text := p.TextDocument.Text
+		mapper := textedit.NewMapper(text)
+		diagnostics := []lsp.Diagnostic{}
+		for offset := strings.Index(text, "TODO"); offset >= 0; {
+			r, err := mapper.Range(offset, offset+len("TODO"))
+			if err != nil {
+				return nil, err
+			}
+			diagnostics = append(diagnostics, lsp.Diagnostic{Range: r, Severity: lsp.Information, Message: "to do"})
+
+			next := strings.Index(text[offset+1:], "TODO")
+			if next < 0 {
+				break
+			}
+			offset += next + 1
+		}
+		return nil, s.Notify(ctx, "textDocument/publishDiagnostics", lsp.PublishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: diagnostics})
+	})
+	s.On("textDocument/hover", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p lsp.TextDocumentPositionParams
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		text := documents[p.TextDocument.URI]
+		mu.Unlock()
+
+		offset, err := textedit.NewMapper(text).Offset(p.Position)
+		if err != nil {
+			return nil, err
+		}
+		end := strings.IndexFunc(text[offset:], func(r rune) bool { return !unicode.IsLetter(r) })
+		if end <= 0 {
+			return nil, nil
+		}
+		return lsp.Hover{Contents: []lsp.MarkedString{{Language: "go", Value: "package " + text[offset:offset+end]}}}, nil
+	})
+	s.On("textDocument/completion", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		return lsp.CompletionList{Items: []lsp.CompletionItem{{Label: "Printf"}, {Label: "Println"}}}, nil
+	})
+
+	return s
+}
+
+func TestFixtures(t *testing.T) {
+	f := Fixtures{
+		Dir:       "testdata/fixtures",
+		NewServer: newFixtureServer,
+		Update:    *update,
+		Markers: map[string]MarkerFunc{
+			"words": func(ctx context.Context, c *Client, m Marker) (interface{}, error) {
+				return m.Args, nil
+			},
+		},
+	}
+	f.Run(t)
+}
@@ -0,0 +1,123 @@
+package lsptest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer() *server.Server {
+	s := server.NewServer(context.Background())
+	s.Capabilities().HoverProvider = true
+
+	s.On("textDocument/didOpen", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p lsp.DidOpenTextDocumentParams
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+
+		var settings []interface{}
+		if err := s.Call(ctx, "workspace/configuration", lsp.ConfigurationParams{Items: []lsp.ConfigurationItem{{Section: "go"}}}, &settings); err != nil {
+			return nil, err
+		}
+		return nil, s.Notify(ctx, "textDocument/publishDiagnostics", lsp.PublishDiagnosticsParams{
+			URI:         p.TextDocument.URI,
+			Diagnostics: []lsp.Diagnostic{{Message: p.TextDocument.Text, Source: p.TextDocument.LanguageID}},
+		})
+	})
+	s.On("textDocument/hover", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p lsp.TextDocumentPositionParams
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Position.Line > 0 {
+			return nil, nil
+		}
+		return lsp.Hover{Contents: []lsp.MarkedString{{Language: "go", Value: "func main()"}}}, nil
+	})
+	s.On("textDocument/completion", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		return []lsp.CompletionItem{{Label: "Println"}}, nil
+	})
+
+	return s
+}
+
+func TestClient(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	c := New(newTestServer())
+
+	_, err := c.Hover(ctx, "file:///main.go", lsp.Position{})
+	assert.Equal(t, server.ErrorCodeServerNotInitialized, err.(*jsonrpc.Error).Code)
+
+	result, err := c.Initialize(ctx, InitializeParams{RootURI: "file:///"})
+	assert.NoError(t, err)
+	assert.True(t, result.Capabilities.HoverProvider)
+
+	assert.NoError(t, c.OpenDocument(ctx, "file:///main.go", "go", "package main"))
+	assert.NoError(t, c.OpenDocument(ctx, "file:///util.go", "go", "package util"))
+
+	diagnostics, err := c.WaitDiagnostics(ctx, "file:///util.go")
+	assert.NoError(t, err)
+	assert.Equal(t, []lsp.Diagnostic{{Message: "package util", Source: "go"}}, diagnostics)
+	diagnostics, err = c.WaitDiagnostics(ctx, "file:///main.go")
+	assert.NoError(t, err)
+	assert.Equal(t, []lsp.Diagnostic{{Message: "package main", Source: "go"}}, diagnostics)
+
+	diagnostics, ok := c.Diagnostics("file:///main.go")
+	assert.True(t, ok)
+	assert.Equal(t, []lsp.Diagnostic{{Message: "package main", Source: "go"}}, diagnostics)
+	_, ok = c.Diagnostics("file:///other.go")
+	assert.False(t, ok)
+	assert.Len(t, c.Notifications("textDocument/publishDiagnostics"), 2)
+
+	hover, err := c.Hover(ctx, "file:///main.go", lsp.Position{Character: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, &lsp.Hover{Contents: []lsp.MarkedString{{Language: "go", Value: "func main()"}}}, hover)
+	hover, err = c.Hover(ctx, "file:///main.go", lsp.Position{Line: 1})
+	assert.NoError(t, err)
+	assert.Nil(t, hover)
+
+	list, err := c.Completion(ctx, "file:///main.go", lsp.Position{})
+	assert.NoError(t, err)
+	assert.Equal(t, &lsp.CompletionList{Items: []lsp.CompletionItem{{Label: "Println"}}}, list)
+
+	assert.NoError(t, c.Close())
+	_, err = c.Hover(ctx, "file:///main.go", lsp.Position{})
+	assert.Equal(t, ErrClosed, err)
+	_, err = c.WaitNotification(ctx, "window/logMessage")
+	assert.Equal(t, ErrClosed, err)
+}
+
+func TestHandleRequest(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	s := server.NewServer(context.Background())
+	c := New(s)
+	defer c.Close()
+
+	c.HandleRequest("window/showMessageRequest", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p lsp.ShowMessageRequestParams
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return p.Actions[1], nil
+	})
+	_, err := c.Initialize(ctx, InitializeParams{})
+	assert.NoError(t, err)
+
+	item, err := s.ShowMessageRequest(ctx, lsp.Info, "Reload?", lsp.MessageActionItem{Title: "Yes"}, lsp.MessageActionItem{Title: "No"})
+	assert.NoError(t, err)
+	assert.Equal(t, &lsp.MessageActionItem{Title: "No"}, item)
+
+	err = s.Call(ctx, "workspace/unknown", nil, nil)
+	assert.Equal(t, jsonrpc.ErrorCodeMethodNotFound, err.(*jsonrpc.Error).Code)
+}
@@ -0,0 +1,148 @@
+package lsptest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/goodgophers/golsp-sdk/textedit"
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/intel-go/fastjson"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// markerPattern matches markers, e.g. /*@complete("Println")*/.
+var markerPattern = regexp.MustCompile(`/\*@([A-Za-z_][A-Za-z0-9_]*)\((.*?)\)\*/`)
+
+const (
+	rangeStart = "«"
+	rangeEnd   = "»"
+)
+
+// Marker is a marker of a fixture file, telling a request to run at its
+// position.
+//
+// Markers are comments of the form /*@name(args)*/, where args are JSON values
+// separated by commas. The position of a marker is where the comment starts.
+// A marker immediately following a range delimited by « and », as in
+// «fmt.Println»/*@hover()*/, applies to that range, and its position is the
+// start of the range.
+//
+// Markers and range delimiters are removed from the content of fixture files
+// before they are opened.
+type Marker struct {
+	Name string
+	Args []interface{}
+
+	File  string // the path of the fixture file, relative to the fixtures directory
+	URI   uri.URI
+	Pos   lsp.Position
+	Range *lsp.Range
+}
+
+// String returns the name and 1-based location of m, e.g.
+// "complete main.go:3:9".
+func (m Marker) String() string {
+	return fmt.Sprintf("%s %s:%d:%d", m.Name, m.File, m.Pos.Line+1, m.Pos.Character+1)
+}
+
+// StringArg returns the i-th argument of m as a string.
+func (m Marker) StringArg(i int) (string, error) {
+	if i >= len(m.Args) {
+		return "", fmt.Errorf("[lsptest] %s: missing argument %d", m, i+1)
+	}
+	s, ok := m.Args[i].(string)
+	if !ok {
+		return "", fmt.Errorf("[lsptest] %s: argument %d is not a string", m, i+1)
+	}
+	return s, nil
+}
+
+// ParseMarkers parses the markers of content, and returns them along with
+// content stripped of markers and range delimiters. file and u identify the
+// fixture file in the returned markers.
+func ParseMarkers(file string, u uri.URI, content string) ([]Marker, string, error) {
+	type pending struct {
+		name       string
+		args       string
+		offset     int
+		start, end int // the offsets of the attached range, if start >= 0
+	}
+
+	var (
+		b          strings.Builder
+		markers    []pending
+		rangeOpen  = -1
+		lastStart  = -1
+		lastEnd    = -1
+		remaining  = content
+		lineNumber = 1
+	)
+	for remaining != "" {
+		switch {
+		case strings.HasPrefix(remaining, rangeStart):
+			if rangeOpen >= 0 {
+				return nil, "", fmt.Errorf("[lsptest] %s:%d: nested range", file, lineNumber)
+			}
+			rangeOpen = b.Len()
+			remaining = remaining[len(rangeStart):]
+
+		case strings.HasPrefix(remaining, rangeEnd):
+			if rangeOpen < 0 {
+				return nil, "", fmt.Errorf("[lsptest] %s:%d: unopened range", file, lineNumber)
+			}
+			lastStart, lastEnd, rangeOpen = rangeOpen, b.Len(), -1
+			remaining = remaining[len(rangeEnd):]
+
+		case strings.HasPrefix(remaining, "/*@"):
+			loc := markerPattern.FindStringSubmatchIndex(remaining)
+			if loc == nil || loc[0] != 0 {
+				return nil, "", fmt.Errorf("[lsptest] %s:%d: malformed marker", file, lineNumber)
+			}
+			m := pending{name: remaining[loc[2]:loc[3]], args: remaining[loc[4]:loc[5]], offset: b.Len(), start: -1}
+			if lastEnd == b.Len() {
+				m.start, m.end = lastStart, lastEnd
+			}
+			markers = append(markers, m)
+			remaining = remaining[loc[1]:]
+
+		default:
+			if remaining[0] == '\n' {
+				lineNumber++
+			}
+			b.WriteByte(remaining[0])
+			remaining = remaining[1:]
+		}
+	}
+	if rangeOpen >= 0 {
+		return nil, "", fmt.Errorf("[lsptest] %s: unclosed range", file)
+	}
+
+	stripped := b.String()
+	mapper := textedit.NewMapper(stripped)
+	var result []Marker
+	for _, p := range markers {
+		m := Marker{Name: p.name, File: file, URI: u}
+		if err := fastjson.Unmarshal([]byte("["+p.args+"]"), &m.Args); err != nil {
+			return nil, "", fmt.Errorf("[lsptest] %s: invalid arguments %q: %v", file, p.args, err)
+		}
+
+		offset := p.offset
+		if p.start >= 0 {
+			r, err := mapper.Range(p.start, p.end)
+			if err != nil {
+				return nil, "", err
+			}
+			m.Range = &r
+			offset = p.start
+		}
+		pos, err := mapper.Position(offset)
+		if err != nil {
+			return nil, "", err
+		}
+		m.Pos = pos
+
+		result = append(result, m)
+	}
+	return result, stripped, nil
+}
@@ -0,0 +1,98 @@
+package lsptest
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMarkers(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		markers []Marker
+		text    string
+		err     string
+	}{
+		{
+			name:    "none",
+			content: "package main\n",
+			text:    "package main\n",
+		},
+		{
+			name:    "position",
+			content: "fmt.Pr/*@complete(\"Println\", 2)*/\n",
+			markers: []Marker{{Name: "complete", Args: []interface{}{"Println", 2.0}, Pos: lsp.Position{Character: 6}}},
+			text:    "fmt.Pr\n",
+		},
+		{
+			name:    "range",
+			content: "x\n«héllo»/*@hover()*/ «world»\n",
+			markers: []Marker{{
+				Name:  "hover",
+				Args:  []interface{}{},
+				Pos:   lsp.Position{Line: 1},
+				Range: &lsp.Range{Start: lsp.Position{Line: 1}, End: lsp.Position{Line: 1, Character: 5}},
+			}},
+			text: "x\nhéllo world\n",
+		},
+		{
+			name:    "range not attached",
+			content: "«a» /*@hover()*/",
+			markers: []Marker{{Name: "hover", Args: []interface{}{}, Pos: lsp.Position{Character: 2}}},
+			text:    "a ",
+		},
+		{
+			name:    "unclosed range",
+			content: "«a",
+			err:     "[lsptest] main.go: unclosed range",
+		},
+		{
+			name:    "unopened range",
+			content: "\na»",
+			err:     "[lsptest] main.go:2: unopened range",
+		},
+		{
+			name:    "malformed marker",
+			content: "/*@hover*/",
+			err:     "[lsptest] main.go:1: malformed marker",
+		},
+		{
+			name:    "invalid arguments",
+			content: "/*@hover(fmt)*/",
+			err:     "[lsptest] main.go: invalid arguments \"fmt\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			markers, text, err := ParseMarkers("main.go", "file:///main.go", tt.content)
+			if tt.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tt.err)
+				}
+				return
+			}
+
+			assert.NoError(t, err)
+			for i := range tt.markers {
+				tt.markers[i].File = "main.go"
+				tt.markers[i].URI = "file:///main.go"
+			}
+			assert.Equal(t, tt.markers, markers)
+			assert.Equal(t, tt.text, text)
+		})
+	}
+}
+
+func TestMarkerString(t *testing.T) {
+	m := Marker{Name: "hover", File: "a/main.go", Pos: lsp.Position{Line: 2, Character: 4}}
+	assert.Equal(t, "hover a/main.go:3:5", m.String())
+
+	_, err := m.StringArg(0)
+	assert.EqualError(t, err, "[lsptest] hover a/main.go:3:5: missing argument 1")
+	m.Args = []interface{}{1.0}
+	_, err = m.StringArg(0)
+	assert.EqualError(t, err, "[lsptest] hover a/main.go:3:5: argument 1 is not a string")
+}
@@ -0,0 +1,128 @@
+package lsptest
+
+import (
+	"context"
+
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/intel-go/fastjson"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// Notification is a notification sent by the server.
+type Notification struct {
+	Method string
+	Params *fastjson.RawMessage
+}
+
+// Decode decodes the parameters of n into v.
+func (n Notification) Decode(v interface{}) error {
+	if n.Params == nil {
+		return fastjson.Unmarshal([]byte("null"), v)
+	}
+	return fastjson.Unmarshal(*n.Params, v)
+}
+
+// capture records a notification sent by the server.
+func (c *Client) capture(n Notification) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.notifications = append(c.notifications, n)
+	close(c.arrived)
+	c.arrived = make(chan struct{})
+}
+
+// Notifications returns the notifications of the given method sent by the
+// server so far, or all of them if method is empty.
+func (c *Client) Notifications(method string) []Notification {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var notifications []Notification
+	for _, n := range c.notifications {
+		if method == "" || n.Method == method {
+			notifications = append(notifications, n)
+		}
+	}
+	return notifications
+}
+
+// WaitNotification waits for the server to send a notification of the given
+// method, and returns it. Successive calls return successive notifications:
+// each call returns the first notification of the method not returned yet,
+// which may have been sent before the call.
+func (c *Client) WaitNotification(ctx context.Context, method string) (Notification, error) {
+	return c.waitFor(ctx, method, func(n Notification) bool {
+		return n.Method == method
+	})
+}
+
+// Diagnostics returns the diagnostics of the document at u last published by
+// the server, if any.
+func (c *Client) Diagnostics(u uri.URI) ([]lsp.Diagnostic, bool) {
+	notifications := c.Notifications("textDocument/publishDiagnostics")
+	for i := len(notifications) - 1; i >= 0; i-- {
+		if p, ok := diagnosticsOf(notifications[i], u); ok {
+			return p.Diagnostics, true
+		}
+	}
+	return nil, false
+}
+
+// WaitDiagnostics waits for the server to publish diagnostics for the
+// document at u, and returns them. Like WaitNotification, successive calls
+// for a document return successive publications.
+func (c *Client) WaitDiagnostics(ctx context.Context, u uri.URI) ([]lsp.Diagnostic, error) {
+	n, err := c.waitFor(ctx, "textDocument/publishDiagnostics "+string(u), func(n Notification) bool {
+		_, ok := diagnosticsOf(n, u)
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p, _ := diagnosticsOf(n, u)
+	return p.Diagnostics, nil
+}
+
+// diagnosticsOf decodes n if it publishes the diagnostics of the document at
+// u.
+func diagnosticsOf(n Notification, u uri.URI) (lsp.PublishDiagnosticsParams, bool) {
+	var p lsp.PublishDiagnosticsParams
+	if n.Method != "textDocument/publishDiagnostics" || n.Decode(&p) != nil {
+		return p, false
+	}
+	return p, uri.FromDocumentURI(p.URI) == u
+}
+
+// waitFor waits for a notification matching match, and returns it. The
+// notifications returned for key are tracked, so that successive calls with
+// the same key return successive notifications.
+func (c *Client) waitFor(ctx context.Context, key string, match func(n Notification) bool) (Notification, error) {
+	for {
+		c.mu.Lock()
+		seen := 0
+		for _, n := range c.notifications {
+			if !match(n) {
+				continue
+			}
+			if seen == c.consumed[key] {
+				c.consumed[key]++
+				c.mu.Unlock()
+				return n, nil
+			}
+			seen++
+		}
+		arrived, closed := c.arrived, c.closed
+		c.mu.Unlock()
+
+		if closed {
+			return Notification{}, ErrClosed
+		}
+		select {
+		case <-arrived:
+		case <-ctx.Done():
+			return Notification{}, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package main
+
+import "fmt"
+
+func main() {
+	fmt.Pr/*@complete("Println", "Printf")*/
+	«fmt»/*@hover("fmt")*/.Println("hello")
+	// «TODO»/*@diag("to do")*/: say goodbye
+	/*@words("hello")*/
+}
@@ -0,0 +1,233 @@
+package mux
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/sourcegraph/go-lsp"
+)
+
+// providers are the paths of the server capabilities merged from the
+// backends'. Others are not advertised, as the multiplexer cannot route their
+// requests, e.g. workspace.fileOperations.
+var providers = []string{
+	"hoverProvider",
+	"completionProvider",
+	"signatureHelpProvider",
+	"declarationProvider",
+	"definitionProvider",
+	"typeDefinitionProvider",
+	"implementationProvider",
+	"referencesProvider",
+	"documentHighlightProvider",
+	"documentSymbolProvider",
+	"codeActionProvider",
+	"codeLensProvider",
+	"documentLinkProvider",
+	"colorProvider",
+	"documentFormattingProvider",
+	"documentRangeFormattingProvider",
+	"renameProvider",
+	"foldingRangeProvider",
+	"selectionRangeProvider",
+	"inlayHintProvider",
+	"workspaceSymbolProvider",
+	"executeCommandProvider",
+	"workspace.workspaceFolders",
+}
+
+// mergeCapabilities returns the capabilities the multiplexer advertises for
+// the backends conns.
+func mergeCapabilities(conns []*conn) map[string]interface{} {
+	merged := make(map[string]interface{})
+	for _, path := range providers {
+		var value interface{}
+		for _, c := range conns {
+			value = mergeValues(value, capability(c.capabilities, path))
+		}
+		if enabled(value) {
+			setCapability(merged, path, value)
+		}
+	}
+
+	sync := syncOptions{OpenClose: true, Change: lsp.TDSKFull}
+	for _, c := range conns {
+		if c.sync.Change == lsp.TDSKIncremental {
+			sync.Change = lsp.TDSKIncremental
+		}
+		sync.WillSave = sync.WillSave || c.sync.WillSave
+		sync.WillSaveWaitUntil = sync.WillSaveWaitUntil || c.sync.WillSaveWaitUntil
+		sync.Save = sync.Save || c.sync.Save
+		sync.SaveText = sync.SaveText || c.sync.SaveText
+	}
+	textDocumentSync := map[string]interface{}{
+		"openClose":         sync.OpenClose,
+		"change":            sync.Change,
+		"willSave":          sync.WillSave,
+		"willSaveWaitUntil": sync.WillSaveWaitUntil,
+	}
+	if sync.Save {
+		textDocumentSync["save"] = map[string]interface{}{"includeText": sync.SaveText}
+	}
+	merged["textDocumentSync"] = textDocumentSync
+
+	return merged
+}
+
+// mergeValues merges the values a and b of a capability, where a has
+// precedence: lists are joined, flags are set if set in either, options
+// objects are merged field by field, and options prevail over a plain true.
+// Other values are a's, unless a is missing or false.
+func mergeValues(a, b interface{}) interface{} {
+	switch {
+	case !enabled(a):
+		if b == nil {
+			return a
+		}
+		return b
+	case !enabled(b):
+		return a
+	}
+
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok {
+			return a
+		}
+		merged := make(map[string]interface{}, len(a)+len(b))
+		for k, v := range a {
+			merged[k] = v
+		}
+		for k, v := range b {
+			merged[k] = mergeValues(merged[k], v)
+		}
+		return merged
+
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok {
+			return a
+		}
+		return union(a, b)
+
+	case bool:
+		// a is true, b may detail the options.
+		if _, ok := b.(map[string]interface{}); ok {
+			return b
+		}
+	}
+	return a
+}
+
+// union returns the elements of a followed by the ones of b missing from a.
+func union(a, b []interface{}) []interface{} {
+	merged := append([]interface{}(nil), a...)
+	seen := make(map[string]bool, len(a))
+	for _, v := range a {
+		seen[key(v)] = true
+	}
+	for _, v := range b {
+		if k := key(v); !seen[k] {
+			seen[k] = true
+			merged = append(merged, v)
+		}
+	}
+	return merged
+}
+
+func key(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// enabled reports whether a capability value denotes a supported feature.
+func enabled(v interface{}) bool {
+	return v != nil && v != false
+}
+
+// capability returns the value at path in capabilities, a dot-separated list
+// of fields, e.g. "completionProvider.resolveProvider".
+func capability(capabilities map[string]interface{}, path string) interface{} {
+	var value interface{} = capabilities
+	for _, field := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[field]
+	}
+	return value
+}
+
+// setCapability sets the value at path in capabilities, creating the objects
+// along it.
+func setCapability(capabilities map[string]interface{}, path string, value interface{}) {
+	fields := strings.Split(path, ".")
+	for _, field := range fields[:len(fields)-1] {
+		object, ok := capabilities[field].(map[string]interface{})
+		if !ok {
+			object = make(map[string]interface{})
+			capabilities[field] = object
+		}
+		capabilities = object
+	}
+	capabilities[fields[len(fields)-1]] = value
+}
+
+// provides reports whether the backend c handles the requests of method, which
+// it advertises with the capability at path, or registered dynamically.
+func (m *Mux) provides(c *conn, method, path string) bool {
+	if enabled(capability(c.capabilities, path)) {
+		return true
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, registered := range c.registrations {
+		if registered.Method == method {
+			return true
+		}
+	}
+	return false
+}
+
+// syncOptions are the text document synchronization options of a backend.
+type syncOptions struct {
+	OpenClose         bool
+	Change            lsp.TextDocumentSyncKind
+	WillSave          bool
+	WillSaveWaitUntil bool
+	Save              bool
+	SaveText          bool
+}
+
+// decodeSyncOptions decodes the textDocumentSync capability of a backend,
+// either a TextDocumentSyncKind or TextDocumentSyncOptions.
+func decodeSyncOptions(v interface{}) syncOptions {
+	switch v := v.(type) {
+	case float64:
+		// Like editors, the multiplexer sends open, close and save
+		// notifications to the servers advertising a kind only.
+		return syncOptions{OpenClose: true, Change: lsp.TextDocumentSyncKind(v), Save: true}
+
+	case map[string]interface{}:
+		o := syncOptions{
+			OpenClose:         v["openClose"] == true,
+			WillSave:          v["willSave"] == true,
+			WillSaveWaitUntil: v["willSaveWaitUntil"] == true,
+		}
+		if change, ok := v["change"].(float64); ok {
+			o.Change = lsp.TextDocumentSyncKind(change)
+		}
+		switch save := v["save"].(type) {
+		case bool:
+			o.Save = save
+		case map[string]interface{}:
+			o.Save, o.SaveText = true, save["includeText"] == true
+		}
+		return o
+	}
+	return syncOptions{}
+}
@@ -0,0 +1,208 @@
+package mux
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/goodgophers/golsp-sdk/textedit"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// document is a document opened by the editor. Its content is tracked to send
+// full changes to the backends which don't support incremental ones.
+type document struct {
+	language string
+	text     string
+	stale    bool // a change could not be applied to text
+}
+
+// language returns the language of the document at u, or "" if it isn't open.
+func (m *Mux) language(u lsp.DocumentURI) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if d, ok := m.documents[u]; ok {
+		return d.language
+	}
+	return ""
+}
+
+// apply applies changes to the content of d. Once a change fails, d is stale
+// and incremental changes are ignored until one replaces the whole content.
+func (d *document) apply(changes []lsp.TextDocumentContentChangeEvent) error {
+	var err error
+	for _, change := range changes {
+		if change.Range == nil {
+			d.text, d.stale = change.Text, false
+			continue
+		}
+		if d.stale {
+			continue
+		}
+
+		var text string
+		if text, err = textedit.Apply(d.text, []lsp.TextEdit{{Range: *change.Range, NewText: change.Text}}); err != nil {
+			d.stale = true
+			continue
+		}
+		d.text = text
+	}
+	return err
+}
+
+func (m *Mux) didOpen(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	var p lsp.DidOpenTextDocumentParams
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.documents[p.TextDocument.URI] = &document{language: p.TextDocument.LanguageID, text: p.TextDocument.Text}
+	m.mu.Unlock()
+
+	for _, c := range m.connections() {
+		if c.sync.OpenClose && c.handles(p.TextDocument.URI, p.TextDocument.LanguageID) {
+			m.notify(ctx, c, "textDocument/didOpen", rawParams(params))
+		}
+	}
+	return nil, nil
+}
+
+func (m *Mux) didChange(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	var p lsp.DidChangeTextDocumentParams
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	d, ok := m.documents[p.TextDocument.URI]
+	if !ok {
+		m.mu.Unlock()
+		m.Logger().Errorf("[mux] textDocument/didChange: %s is not open", p.TextDocument.URI)
+		return nil, nil
+	}
+	err := d.apply(p.ContentChanges)
+	language, text, stale := d.language, d.text, d.stale
+	m.mu.Unlock()
+	if err != nil {
+		m.Logger().Errorf("[mux] textDocument/didChange: %s: %v", p.TextDocument.URI, err)
+	}
+
+	// The content of a stale document is unknown, so the backends syncing
+	// full documents are sent nothing until the editor sends it again.
+
+	full := fullChangeParams{TextDocument: p.TextDocument, ContentChanges: []fullChange{{Text: text}}}
+	for _, c := range m.connections() {
+		if !c.handles(p.TextDocument.URI, language) {
+			continue
+		}
+		switch c.sync.Change {
+		case lsp.TDSKIncremental:
+			m.notify(ctx, c, "textDocument/didChange", rawParams(params))
+		case lsp.TDSKFull:
+			if !stale {
+				m.notify(ctx, c, "textDocument/didChange", full)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// fullChangeParams are the parameters of a didChange notification replacing
+// the content of a document. lsp.TextDocumentContentChangeEvent would encode a
+// null range.
+type fullChangeParams struct {
+	TextDocument   lsp.VersionedTextDocumentIdentifier `json:"textDocument"`
+	ContentChanges []fullChange                        `json:"contentChanges"`
+}
+
+type fullChange struct {
+	Text string `json:"text"`
+}
+
+func (m *Mux) didClose(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	var p lsp.DidCloseTextDocumentParams
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	language := m.language(p.TextDocument.URI)
+	m.mu.Lock()
+	delete(m.documents, p.TextDocument.URI)
+	m.mu.Unlock()
+
+	for _, c := range m.connections() {
+		if c.sync.OpenClose && c.handles(p.TextDocument.URI, language) {
+			m.notify(ctx, c, "textDocument/didClose", rawParams(params))
+		}
+	}
+	return nil, nil
+}
+
+func (m *Mux) didSave(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	return m.documentNotification(ctx, "textDocument/didSave", params, func(c *conn) bool { return c.sync.Save })
+}
+
+func (m *Mux) willSave(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	return m.documentNotification(ctx, "textDocument/willSave", params, func(c *conn) bool { return c.sync.WillSave })
+}
+
+// documentNotification sends a notification on a document to the backends
+// which handle it and want the notification.
+func (m *Mux) documentNotification(ctx context.Context, method string, params *fastjson.RawMessage, wants func(c *conn) bool) (interface{}, error) {
+	var p struct {
+		TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+	}
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	language := m.language(p.TextDocument.URI)
+	for _, c := range m.connections() {
+		if wants(c) && c.handles(p.TextDocument.URI, language) {
+			m.notify(ctx, c, method, rawParams(params))
+		}
+	}
+	return nil, nil
+}
+
+// publishDiagnostics returns a callback merging the diagnostics published by
+// the backend c with the ones of the other backends for the same document.
+func (m *Mux) publishDiagnostics(c *conn) server.CallbackFunc {
+	return func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p struct {
+			URI         lsp.DocumentURI   `json:"uri"`
+			Version     *int              `json:"version,omitempty"`
+			Diagnostics []json.RawMessage `json:"diagnostics"`
+		}
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+
+		// The lock is held while notifying the editor, so that the last
+		// diagnostics it receives are the latest merged.
+		m.diagnosticsMu.Lock()
+		defer m.diagnosticsMu.Unlock()
+
+		published := m.diagnostics[p.URI]
+		if published == nil {
+			published = make(map[int][]json.RawMessage)
+			m.diagnostics[p.URI] = published
+		}
+		published[c.index] = p.Diagnostics
+
+		merged := []json.RawMessage{}
+		for i := range m.backends {
+			merged = append(merged, published[i]...)
+		}
+		if len(merged) == 0 {
+			delete(m.diagnostics, p.URI)
+		}
+
+		p.Diagnostics = merged
+		return nil, m.Notify(ctx, "textDocument/publishDiagnostics", p)
+	}
+}
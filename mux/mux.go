@@ -0,0 +1,390 @@
+// Package mux fronts several language servers as one, for editors running a
+// single server per document.
+//
+//	m := mux.New(ctx,
+//		mux.Backend{Name: "go", Selector: glob.DocumentSelector{{Language: "go"}}, Connect: startGopls},
+//		mux.Backend{Name: "spell", Connect: startSpellChecker},
+//	)
+//	m.StartStdio()
+//
+// The multiplexer connects to its backends when the editor sends initialize,
+// forwards the request to each of them and responds with their merged
+// capabilities. It then:
+//
+//   - keeps the documents opened by the editor in sync in the backends whose
+//     selector matches them, converting incremental changes to full ones for
+//     the backends which only support those,
+//   - sends the requests on a document to the backends which match it and
+//     advertise the capability, merging list results such as completion
+//     items, code actions and locations, and taking the first non-null result
+//     in the order of the backends otherwise,
+//   - routes resolve requests to the backend which produced the item, and
+//     commands to the backend which advertises them,
+//   - merges the diagnostics the backends publish for each document,
+//   - forwards the requests and notifications the backends send to the
+//     editor.
+//
+// Capabilities the multiplexer cannot route, e.g. semantic tokens whose
+// legends differ between servers, are not advertised.
+package mux
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/goodgophers/golsp-sdk/client"
+	"github.com/goodgophers/golsp-sdk/glob"
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// Backend is a language server fronted by a Mux.
+type Backend struct {
+	// Name identifies the backend in the logs sent to the editor.
+	Name string
+
+	// Selector denotes the documents the backend handles, all of them if
+	// empty.
+	Selector glob.DocumentSelector
+
+	// Connect connects to the backend, e.g. with client.Start. The connection
+	// lasts until the editor shuts the multiplexer down, and ctx with it.
+	Connect func(ctx context.Context) (*client.Client, error)
+}
+
+// Mux is a language server forwarding the messages of the editor to backend
+// servers. Its embedded server is served like any other, e.g. with
+// StartStdio.
+type Mux struct {
+	*server.Server
+
+	ctx      context.Context
+	backends []Backend
+
+	mu        sync.RWMutex
+	conns     []*conn // the backends initialized successfully, in order
+	documents map[lsp.DocumentURI]*document
+
+	diagnosticsMu sync.Mutex
+	diagnostics   map[lsp.DocumentURI]map[int][]json.RawMessage // by backend
+
+	closeOnce sync.Once
+}
+
+// conn is the connection to a backend.
+type conn struct {
+	index    int // of the backend
+	name     string
+	selector glob.DocumentSelector
+	client   *client.Client
+
+	capabilities map[string]interface{}
+	sync         syncOptions
+
+	// registrations are the capabilities the backend registered
+	// dynamically, by ID. They are guarded by the mutex of the Mux.
+	registrations map[string]registration
+}
+
+// New returns a multiplexer fronting backends, in order of precedence.
+func New(ctx context.Context, backends ...Backend) *Mux {
+	m := &Mux{
+		Server:      server.NewServer(ctx),
+		ctx:         ctx,
+		backends:    backends,
+		documents:   make(map[lsp.DocumentURI]*document),
+		diagnostics: make(map[lsp.DocumentURI]map[int][]json.RawMessage),
+	}
+
+	m.On("initialize", m.initialize)
+	m.On("shutdown", m.shutdown)
+	m.OnExit(m.close)
+	for _, method := range broadcastNotifications {
+		m.On(method, m.broadcast(method))
+	}
+
+	m.On("textDocument/didOpen", m.didOpen)
+	m.On("textDocument/didChange", m.didChange)
+	m.On("textDocument/didClose", m.didClose)
+	m.On("textDocument/didSave", m.didSave)
+	m.On("textDocument/willSave", m.willSave)
+
+	for method, r := range routes {
+		m.On(method, m.request(method, r))
+	}
+	for method, provider := range resolveProviders {
+		m.On(method, m.resolve(method, provider))
+	}
+	m.On("workspace/executeCommand", m.executeCommand)
+
+	return m
+}
+
+// broadcastNotifications are the notifications of the editor sent to every
+// backend.
+var broadcastNotifications = []string{
+	"initialized",
+	"$/setTrace",
+	"workspace/didChangeConfiguration",
+	"workspace/didChangeWatchedFiles",
+	"workspace/didChangeWorkspaceFolders",
+}
+
+// editorRequests are the requests of the backends forwarded to the editor,
+// besides the registration of capabilities.
+var editorRequests = []string{
+	"window/showMessageRequest",
+	"window/showDocument",
+	"window/workDoneProgress/create",
+	"workspace/applyEdit",
+	"workspace/configuration",
+	"workspace/workspaceFolders",
+	"workspace/codeLens/refresh",
+	"workspace/inlayHint/refresh",
+}
+
+// editorNotifications are the notifications of the backends forwarded to the
+// editor, besides log messages and diagnostics.
+var editorNotifications = []string{
+	"window/showMessage",
+	"$/progress",
+	"$/logTrace",
+	"telemetry/event",
+}
+
+// initializeResult is the response of the multiplexer to initialize.
+type initializeResult struct {
+	Capabilities map[string]interface{} `json:"capabilities"`
+	ServerInfo   serverInfo             `json:"serverInfo"`
+}
+
+type serverInfo struct {
+	Name string `json:"name"`
+}
+
+// initialize connects to the backends and initializes them with the
+// parameters sent by the editor. Backends failing to are left out of the
+// session, unless they all fail.
+func (m *Mux) initialize(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	conns := make([]*conn, len(m.backends))
+	var wg sync.WaitGroup
+	for i, b := range m.backends {
+		wg.Add(1)
+		go func(i int, b Backend) {
+			defer wg.Done()
+
+			c, err := m.connect(ctx, i, b, params)
+			if err != nil {
+				m.Logger().Errorf("[mux] %s: initialize: %v", b.Name, err)
+				return
+			}
+			conns[i] = c
+		}(i, b)
+	}
+	wg.Wait()
+
+	var initialized []*conn
+	for _, c := range conns {
+		if c != nil {
+			initialized = append(initialized, c)
+		}
+	}
+	if len(initialized) == 0 {
+		return nil, errors.New("[mux] no backend could be initialized")
+	}
+
+	m.mu.Lock()
+	m.conns = initialized
+	m.mu.Unlock()
+
+	return initializeResult{
+		Capabilities: mergeCapabilities(initialized),
+		ServerInfo:   serverInfo{Name: "golsp-sdk mux"},
+	}, nil
+}
+
+// connect connects to the backend b, at index i, and initializes it.
+func (m *Mux) connect(ctx context.Context, i int, b Backend, params *fastjson.RawMessage) (*conn, error) {
+	cl, err := b.Connect(m.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &conn{index: i, name: b.Name, selector: b.Selector, client: cl, registrations: make(map[string]registration)}
+	m.forward(c)
+
+	var result struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	if err := cl.Call(ctx, "initialize", rawParams(params), &result); err != nil {
+		_ = cl.Close()
+		return nil, err
+	}
+	c.capabilities = result.Capabilities
+	c.sync = decodeSyncOptions(result.Capabilities["textDocumentSync"])
+	return c, nil
+}
+
+// forward registers the callbacks forwarding the messages of the backend c to
+// the editor.
+func (m *Mux) forward(c *conn) {
+	for _, method := range editorRequests {
+		c.client.On(method, m.forwardRequest(method))
+	}
+	for _, method := range editorNotifications {
+		method := method
+		c.client.On(method, func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+			return nil, m.Notify(ctx, method, rawParams(params))
+		})
+	}
+
+	c.client.On("client/registerCapability", m.registerCapability(c))
+	c.client.On("client/unregisterCapability", m.unregisterCapability(c))
+	c.client.On("textDocument/publishDiagnostics", m.publishDiagnostics(c))
+	c.client.On("window/logMessage", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p lsp.LogMessageParams
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		p.Message = "[" + c.name + "] " + p.Message
+		return nil, m.Notify(ctx, "window/logMessage", p)
+	})
+}
+
+// forwardRequest returns a callback forwarding requests of a backend to the
+// editor, and its response back.
+func (m *Mux) forwardRequest(method string) server.CallbackFunc {
+	return func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var result json.RawMessage
+		if err := m.Call(ctx, method, rawParams(params), &result); err != nil {
+			return nil, err
+		}
+		if len(result) == 0 {
+			return nil, nil
+		}
+		return result, nil
+	}
+}
+
+// registration is a capability a backend registers dynamically.
+type registration struct {
+	ID              string          `json:"id"`
+	Method          string          `json:"method"`
+	RegisterOptions json.RawMessage `json:"registerOptions,omitempty"`
+}
+
+// registerCapability forwards the capabilities the backend c registers to the
+// editor, and routes their requests to c once registered.
+func (m *Mux) registerCapability(c *conn) server.CallbackFunc {
+	forward := m.forwardRequest("client/registerCapability")
+	return func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p struct {
+			Registrations []registration `json:"registrations"`
+		}
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+
+		result, err := forward(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+
+		m.mu.Lock()
+		for _, r := range p.Registrations {
+			c.registrations[r.ID] = r
+		}
+		m.mu.Unlock()
+		return result, nil
+	}
+}
+
+// unregisterCapability forwards the capabilities the backend c unregisters to
+// the editor.
+func (m *Mux) unregisterCapability(c *conn) server.CallbackFunc {
+	forward := m.forwardRequest("client/unregisterCapability")
+	return func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p struct {
+			// The misspelling is the specification's.
+			Unregistrations []registration `json:"unregisterations"`
+		}
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+
+		m.mu.Lock()
+		for _, r := range p.Unregistrations {
+			delete(c.registrations, r.ID)
+		}
+		m.mu.Unlock()
+		return forward(ctx, params)
+	}
+}
+
+// broadcast returns a callback sending a notification of the editor to every
+// backend.
+func (m *Mux) broadcast(method string) server.CallbackFunc {
+	return func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		for _, c := range m.connections() {
+			m.notify(ctx, c, method, rawParams(params))
+		}
+		return nil, nil
+	}
+}
+
+// notify sends a notification to the backend c, logging failures.
+func (m *Mux) notify(ctx context.Context, c *conn, method string, params interface{}) {
+	if err := c.client.Notify(ctx, method, params); err != nil {
+		m.Logger().Errorf("[mux] %s: %s: %v", c.name, method, err)
+	}
+}
+
+// shutdown shuts the backends down along with the multiplexer.
+func (m *Mux) shutdown(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	m.close()
+	return nil, nil
+}
+
+// close shuts the backends down and closes the connections to them, once.
+func (m *Mux) close() {
+	m.closeOnce.Do(func() {
+		var wg sync.WaitGroup
+		for _, c := range m.connections() {
+			wg.Add(1)
+			go func(c *conn) {
+				defer wg.Done()
+				if err := c.client.Close(); err != nil {
+					m.Logger().Errorf("[mux] %s: close: %v", c.name, err)
+				}
+			}(c)
+		}
+		wg.Wait()
+	})
+}
+
+// connections returns the backends initialized successfully.
+func (m *Mux) connections() []*conn {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.conns
+}
+
+// handles reports whether the backend c handles the document at u, of the
+// given language.
+func (c *conn) handles(u lsp.DocumentURI, language string) bool {
+	return len(c.selector) == 0 || c.selector.Matches(uri.FromDocumentURI(u), language)
+}
+
+// rawParams returns the parameters of a message to forward them as is.
+func rawParams(params *fastjson.RawMessage) interface{} {
+	if params == nil {
+		return nil
+	}
+	return json.RawMessage(*params)
+}
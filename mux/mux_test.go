@@ -0,0 +1,435 @@
+package mux
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/client"
+	"github.com/goodgophers/golsp-sdk/glob"
+	"github.com/goodgophers/golsp-sdk/lsptest"
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+)
+
+// testBackend is a backend server reporting the notifications it receives on
+// documents.
+type testBackend struct {
+	*server.Server
+	received chan string
+}
+
+func newTestBackend(name string, sync lsp.TextDocumentSyncKind) *testBackend {
+	b := &testBackend{Server: server.NewServer(context.Background()), received: make(chan string, 100)}
+	b.Capabilities().TextDocumentSync = &lsp.TextDocumentSyncOptionsOrKind{Kind: &sync}
+
+	record := func(method string) {
+		b.On(method, func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+			b.received <- method + " " + string(*params)
+			if method != "textDocument/didOpen" {
+				return nil, nil
+			}
+
+			var p lsp.DidOpenTextDocumentParams
+			if err := jsonrpc.Unmarshal(params, &p); err != nil {
+				return nil, err
+			}
+			return nil, b.Notify(ctx, "textDocument/publishDiagnostics", lsp.PublishDiagnosticsParams{
+				URI:         p.TextDocument.URI,
+				Diagnostics: []lsp.Diagnostic{{Message: name, Source: name}},
+			})
+		})
+	}
+	record("textDocument/didOpen")
+	record("textDocument/didChange")
+	record("textDocument/didClose")
+	return b
+}
+
+// next returns the next notification received by b.
+func (b *testBackend) next(t *testing.T) string {
+	t.Helper()
+
+	select {
+	case n := <-b.received:
+		return n
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification received")
+		return ""
+	}
+}
+
+func (b *testBackend) connect(ctx context.Context) (*client.Client, error) {
+	clientConn, serverConn := net.Pipe()
+	go func() {
+		_ = b.Serve(serverConn)
+	}()
+	return client.New(ctx, clientConn), nil
+}
+
+// newGoBackend returns a backend for Go documents, syncing them incrementally.
+func newGoBackend() *testBackend {
+	b := newTestBackend("go", lsp.TDSKIncremental)
+	b.Capabilities().HoverProvider = true
+	b.Capabilities().DefinitionProvider = true
+	b.Capabilities().CompletionProvider = &lsp.CompletionOptions{ResolveProvider: true, TriggerCharacters: []string{"."}}
+
+	b.On("textDocument/hover", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		return lsp.Hover{Contents: []lsp.MarkedString{lsp.RawMarkedString("func main()")}}, nil
+	})
+	b.On("textDocument/definition", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		return lsp.Location{URI: "file:///main.go"}, nil
+	})
+	b.On("textDocument/completion", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		return map[string]interface{}{
+			"isIncomplete": true,
+			"itemDefaults": map[string]interface{}{"data": "fmt"},
+			"items":        []map[string]interface{}{{"label": "Println"}},
+		}, nil
+	})
+	b.On("completionItem/resolve", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var item map[string]interface{}
+		if err := jsonrpc.Unmarshal(params, &item); err != nil {
+			return nil, err
+		}
+		item["detail"] = item["data"].(string) + "." + item["label"].(string)
+		return item, nil
+	})
+	b.Command("go.test", func(ctx context.Context, args []string) (interface{}, error) {
+		return "ok", nil
+	})
+	return b
+}
+
+// newSpellBackend returns a backend for any document, syncing them fully.
+func newSpellBackend() *testBackend {
+	b := newTestBackend("spell", lsp.TDSKFull)
+	b.Capabilities().DefinitionProvider = true
+	b.Capabilities().CompletionProvider = &lsp.CompletionOptions{}
+	b.Capabilities().CodeActionProvider = true
+
+	b.On("textDocument/definition", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		return []lsp.Location{{URI: "file:///words.txt"}}, nil
+	})
+	b.On("textDocument/completion", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		return []map[string]interface{}{{"label": "spelling"}}, nil
+	})
+	b.On("textDocument/codeAction", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		return []map[string]interface{}{
+			{"title": "Fix spelling", "kind": "quickfix"},
+			{"title": "Add to dictionary", "command": "spell.add"},
+		}, nil
+	})
+	b.Command("spell.language", func(ctx context.Context, args []string) (interface{}, error) {
+		var settings []string
+		err := b.Call(ctx, "workspace/configuration", lsp.ConfigurationParams{Items: []lsp.ConfigurationItem{{Section: "spell.language"}}}, &settings)
+		return settings, err
+	})
+	return b
+}
+
+// session initializes a multiplexer fronting the Go and spelling backends
+// with a fake editor.
+func session(t *testing.T) (editor *lsptest.Client, goBackend, spellBackend *testBackend) {
+	t.Helper()
+
+	goBackend, spellBackend = newGoBackend(), newSpellBackend()
+	m := New(context.Background(),
+		Backend{Name: "go", Selector: glob.DocumentSelector{{Language: "go"}}, Connect: goBackend.connect},
+		Backend{Name: "spell", Connect: spellBackend.connect},
+	)
+	editor = lsptest.New(m.Server)
+	_, err := editor.Initialize(context.Background(), lsptest.InitializeParams{})
+	assert.NoError(t, err)
+	return editor, goBackend, spellBackend
+}
+
+func TestInitialize(t *testing.T) {
+	goBackend, spellBackend := newGoBackend(), newSpellBackend()
+	m := New(context.Background(),
+		Backend{Name: "go", Connect: goBackend.connect},
+		Backend{Name: "spell", Connect: spellBackend.connect},
+		Backend{Name: "broken", Connect: func(ctx context.Context) (*client.Client, error) {
+			return nil, errors.New("not installed")
+		}},
+	)
+	editor := lsptest.New(m.Server)
+	defer editor.Close()
+
+	var result struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	assert.NoError(t, editor.Call(context.Background(), "initialize", lsptest.InitializeParams{Capabilities: map[string]interface{}{}}, &result))
+	assert.Equal(t, map[string]interface{}{
+		"textDocumentSync":   map[string]interface{}{"openClose": true, "change": float64(2), "willSave": false, "willSaveWaitUntil": false, "save": map[string]interface{}{"includeText": false}},
+		"hoverProvider":      true,
+		"definitionProvider": true,
+		"codeActionProvider": true,
+		"completionProvider": map[string]interface{}{"resolveProvider": true, "triggerCharacters": []interface{}{"."}},
+		"executeCommandProvider": map[string]interface{}{
+			"commands": []interface{}{"go.test", "spell.language"},
+		},
+	}, result.Capabilities)
+
+	// Logs are sent once the editor is initialized.
+	assert.NoError(t, editor.Notify(context.Background(), "initialized", struct{}{}))
+	n, err := editor.WaitNotification(context.Background(), "window/logMessage")
+	assert.NoError(t, err)
+	var log lsp.LogMessageParams
+	assert.NoError(t, n.Decode(&log))
+	assert.Equal(t, "[mux] broken: initialize: not installed", log.Message)
+}
+
+func TestInitializeWithoutBackends(t *testing.T) {
+	editor := lsptest.New(New(context.Background()).Server)
+	defer editor.Close()
+
+	_, err := editor.Initialize(context.Background(), lsptest.InitializeParams{})
+	assert.EqualError(t, err, "jsonrpc: code: -32603, message: [mux] no backend could be initialized, data: <nil>")
+}
+
+func TestMergeCapabilities(t *testing.T) {
+	merged := mergeCapabilities([]*conn{
+		{capabilities: map[string]interface{}{
+			"workspace": map[string]interface{}{
+				"workspaceFolders": map[string]interface{}{"supported": true},
+				"fileOperations":   map[string]interface{}{"didRename": map[string]interface{}{"filters": []interface{}{}}},
+			},
+		}},
+		{capabilities: map[string]interface{}{
+			"workspace": map[string]interface{}{"workspaceFolders": map[string]interface{}{"changeNotifications": true}},
+		}},
+	})
+
+	// File operations are not routed, hence not advertised.
+	assert.Equal(t, map[string]interface{}{
+		"workspaceFolders": map[string]interface{}{"supported": true, "changeNotifications": true},
+	}, merged["workspace"])
+}
+
+func TestMergeValues(t *testing.T) {
+	for _, tc := range []struct {
+		A, B, Expected interface{}
+	}{
+		{nil, true, true},
+		{false, nil, false},
+		{false, true, true},
+		{true, map[string]interface{}{"workDoneProgress": true}, map[string]interface{}{"workDoneProgress": true}},
+		{map[string]interface{}{"workDoneProgress": true}, true, map[string]interface{}{"workDoneProgress": true}},
+		{
+			map[string]interface{}{"codeActionKinds": []interface{}{"quickfix"}},
+			map[string]interface{}{"codeActionKinds": []interface{}{"refactor", "quickfix"}, "resolveProvider": true},
+			map[string]interface{}{"codeActionKinds": []interface{}{"quickfix", "refactor"}, "resolveProvider": true},
+		},
+	} {
+		assert.Equal(t, tc.Expected, mergeValues(tc.A, tc.B), "%v and %v", tc.A, tc.B)
+	}
+}
+
+func TestDocuments(t *testing.T) {
+	ctx := context.Background()
+	editor, goBackend, spellBackend := session(t)
+	defer editor.Close()
+
+	main, notes := uri.File("/main.go"), uri.File("/notes.txt")
+	assert.NoError(t, editor.OpenDocument(ctx, main, "go", "package main\n"))
+	assert.NoError(t, editor.OpenDocument(ctx, notes, "plaintext", "hello"))
+	assert.NoError(t, editor.Notify(ctx, "textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": main, "version": 2},
+		"contentChanges": []interface{}{map[string]interface{}{"range": lsp.Range{Start: lsp.Position{Line: 1}, End: lsp.Position{Line: 1}}, "text": "func main() {}\n"}},
+	}))
+	assert.NoError(t, editor.CloseDocument(ctx, notes))
+
+	assert.Equal(t, `textDocument/didOpen {"textDocument":{"uri":"file:///main.go","languageId":"go","version":1,"text":"package main\n"}}`, goBackend.next(t))
+	assert.Equal(t, `textDocument/didChange {"contentChanges":[{"range":{"start":{"line":1,"character":0},"end":{"line":1,"character":0}},"text":"func main() {}\n"}],"textDocument":{"uri":"file:///main.go","version":2}}`, goBackend.next(t))
+
+	assert.Equal(t, `textDocument/didOpen {"textDocument":{"uri":"file:///main.go","languageId":"go","version":1,"text":"package main\n"}}`, spellBackend.next(t))
+	assert.Equal(t, `textDocument/didOpen {"textDocument":{"uri":"file:///notes.txt","languageId":"plaintext","version":1,"text":"hello"}}`, spellBackend.next(t))
+	assert.Equal(t, `textDocument/didChange {"textDocument":{"uri":"file:///main.go","version":2},"contentChanges":[{"text":"package main\nfunc main() {}\n"}]}`, spellBackend.next(t))
+	assert.Equal(t, `textDocument/didClose {"textDocument":{"uri":"file:///notes.txt"}}`, spellBackend.next(t))
+
+	select {
+	case n := <-goBackend.received:
+		t.Errorf("unexpected notification to the Go backend: %s", n)
+	default:
+	}
+}
+
+func TestStaleDocument(t *testing.T) {
+	ctx := context.Background()
+	editor, goBackend, spellBackend := session(t)
+	defer editor.Close()
+
+	main := uri.File("/main.go")
+	assert.NoError(t, editor.OpenDocument(ctx, main, "go", "package main\n"))
+	assert.NoError(t, editor.Notify(ctx, "textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": main, "version": 2},
+		"contentChanges": []interface{}{map[string]interface{}{"range": lsp.Range{Start: lsp.Position{Line: 5}, End: lsp.Position{Line: 5}}, "text": "func main() {}\n"}},
+	}))
+	assert.NoError(t, editor.Notify(ctx, "textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": main, "version": 3},
+		"contentChanges": []interface{}{map[string]interface{}{"range": lsp.Range{Start: lsp.Position{Line: 1}, End: lsp.Position{Line: 1}}, "text": "func main() {}\n"}},
+	}))
+	assert.NoError(t, editor.Notify(ctx, "textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": main, "version": 4},
+		"contentChanges": []interface{}{map[string]interface{}{"text": "package lib\n"}},
+	}))
+
+	goBackend.next(t)
+	assert.Contains(t, goBackend.next(t), `"version":2`)
+	assert.Contains(t, goBackend.next(t), `"version":3`)
+	assert.Contains(t, goBackend.next(t), `"version":4`)
+
+	spellBackend.next(t)
+	assert.Equal(t, `textDocument/didChange {"textDocument":{"uri":"file:///main.go","version":4},"contentChanges":[{"text":"package lib\n"}]}`, spellBackend.next(t))
+}
+
+func TestDiagnostics(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	editor, _, _ := session(t)
+	defer editor.Close()
+
+	main := uri.File("/main.go")
+	assert.NoError(t, editor.OpenDocument(ctx, main, "go", "package main\n"))
+
+	var sources []string
+	for len(sources) < 2 {
+		diagnostics, err := editor.WaitDiagnostics(ctx, main)
+		if !assert.NoError(t, err) {
+			return
+		}
+		sources = sources[:0]
+		for _, d := range diagnostics {
+			sources = append(sources, d.Source)
+		}
+	}
+	assert.Equal(t, []string{"go", "spell"}, sources)
+}
+
+func TestRequests(t *testing.T) {
+	ctx := context.Background()
+	editor, _, _ := session(t)
+	defer editor.Close()
+
+	main, notes := uri.File("/main.go"), uri.File("/notes.txt")
+	assert.NoError(t, editor.OpenDocument(ctx, main, "go", "package main\n"))
+	assert.NoError(t, editor.OpenDocument(ctx, notes, "plaintext", "hello"))
+
+	hover, err := editor.Hover(ctx, main, lsp.Position{})
+	assert.NoError(t, err)
+	assert.Equal(t, &lsp.Hover{Contents: []lsp.MarkedString{lsp.RawMarkedString("func main()")}}, hover)
+	hover, err = editor.Hover(ctx, notes, lsp.Position{})
+	assert.NoError(t, err)
+	assert.Nil(t, hover)
+
+	var locations []lsp.Location
+	assert.NoError(t, editor.Call(ctx, "textDocument/definition", lsp.TextDocumentPositionParams{TextDocument: lsp.TextDocumentIdentifier{URI: main.DocumentURI()}}, &locations))
+	assert.Equal(t, []lsp.Location{{URI: "file:///main.go"}, {URI: "file:///words.txt"}}, locations)
+
+	var actions []map[string]interface{}
+	assert.NoError(t, editor.Call(ctx, "textDocument/codeAction", map[string]interface{}{"textDocument": map[string]interface{}{"uri": notes}, "context": map[string]interface{}{"diagnostics": []interface{}{}}}, &actions))
+	assert.Equal(t, []map[string]interface{}{
+		{"title": "Fix spelling", "kind": "quickfix", "data": map[string]interface{}{"golspMuxBackend": float64(1)}},
+		{"title": "Add to dictionary", "command": "spell.add"},
+	}, actions)
+}
+
+func TestCompletion(t *testing.T) {
+	ctx := context.Background()
+	editor, _, _ := session(t)
+	defer editor.Close()
+
+	main := uri.File("/main.go")
+	assert.NoError(t, editor.OpenDocument(ctx, main, "go", "package main\n"))
+
+	list, err := editor.Completion(ctx, main, lsp.Position{})
+	assert.NoError(t, err)
+	assert.True(t, list.IsIncomplete)
+	var labels []string
+	for _, item := range list.Items {
+		labels = append(labels, item.Label)
+	}
+	assert.Equal(t, []string{"Println", "spelling"}, labels)
+
+	var triggered struct {
+		Items []json.RawMessage `json:"items"`
+	}
+	params := map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": main},
+		"position":     lsp.Position{},
+		"context":      map[string]interface{}{"triggerKind": 2, "triggerCharacter": "."},
+	}
+	assert.NoError(t, editor.Call(ctx, "textDocument/completion", params, &triggered))
+	if !assert.Len(t, triggered.Items, 1) {
+		return
+	}
+	assert.JSONEq(t, `{"label":"Println","data":{"golspMuxBackend":0,"data":"fmt"}}`, string(triggered.Items[0]))
+
+	var resolved map[string]interface{}
+	assert.NoError(t, editor.Call(ctx, "completionItem/resolve", triggered.Items[0], &resolved))
+	assert.Equal(t, "fmt.Println", resolved["detail"])
+	assert.Equal(t, map[string]interface{}{"golspMuxBackend": float64(0), "data": "fmt"}, resolved["data"])
+
+	var rpcErr *jsonrpc.Error
+	err = editor.Call(ctx, "completionItem/resolve", map[string]interface{}{"label": "Println"}, nil)
+	if assert.True(t, errors.As(err, &rpcErr)) {
+		assert.Equal(t, jsonrpc.ErrorCodeInvalidParams, rpcErr.Code)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	ctx := context.Background()
+	editor, _, _ := session(t)
+	defer editor.Close()
+
+	editor.HandleRequest("workspace/configuration", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		return []string{"en-GB"}, nil
+	})
+
+	var result interface{}
+	assert.NoError(t, editor.Call(ctx, "workspace/executeCommand", map[string]interface{}{"command": "go.test", "arguments": []string{}}, &result))
+	assert.Equal(t, "ok", result)
+	assert.NoError(t, editor.Call(ctx, "workspace/executeCommand", map[string]interface{}{"command": "spell.language", "arguments": []string{}}, &result))
+	assert.Equal(t, []interface{}{"en-GB"}, result)
+
+	err := editor.Call(ctx, "workspace/executeCommand", map[string]interface{}{"command": "go.build"}, nil)
+	assert.EqualError(t, err, `jsonrpc: code: -32602, message: unknown command "go.build", data: <nil>`)
+}
+
+func TestRegisteredCommands(t *testing.T) {
+	ctx := context.Background()
+	goBackend := newGoBackend()
+	registered := make(chan error, 1)
+	goBackend.OnInitialized(func(ctx context.Context) {
+		goBackend.Command("go.generate", func(ctx context.Context, args []string) (interface{}, error) {
+			return "generated", nil
+		})
+		_, err := goBackend.RegisterCapability(ctx, "workspace/executeCommand", lsp.ExecuteCommandOptions{Commands: []string{"go.generate"}})
+		registered <- err
+	})
+	m := New(ctx, Backend{Name: "go", Connect: goBackend.connect})
+
+	editor := lsptest.New(m.Server)
+	defer editor.Close()
+	editor.HandleRequest("client/registerCapability", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		return nil, nil
+	})
+	_, err := editor.Initialize(ctx, lsptest.InitializeParams{Capabilities: map[string]interface{}{
+		"workspace": map[string]interface{}{"executeCommand": map[string]interface{}{"dynamicRegistration": true}},
+	}})
+	if !assert.NoError(t, err) || !assert.NoError(t, <-registered) {
+		return
+	}
+
+	var result interface{}
+	assert.NoError(t, editor.Call(ctx, "workspace/executeCommand", map[string]interface{}{"command": "go.generate", "arguments": []string{}}, &result))
+	assert.Equal(t, "generated", result)
+}
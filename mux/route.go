@@ -0,0 +1,452 @@
+package mux
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// strategy is how the results of the backends to a request are merged.
+type strategy int
+
+const (
+	// first takes the first non-null result, in the order of the backends.
+	first strategy = iota
+	// concat joins the results, lists or single items, in a list.
+	concat
+	// completion joins completion lists, or bare lists of items.
+	completion
+)
+
+// route tells which backends handle the requests of a method, and how their
+// results are merged.
+type route struct {
+	provider string // the capability of the backends handling the method
+	merge    strategy
+	resolve  bool // whether the items returned are resolved later
+}
+
+// routes are the routes of the requests of the editor, besides resolve
+// requests and commands.
+var routes = map[string]route{
+	"textDocument/hover":             {provider: "hoverProvider"},
+	"textDocument/completion":        {provider: "completionProvider", merge: completion, resolve: true},
+	"textDocument/signatureHelp":     {provider: "signatureHelpProvider"},
+	"textDocument/declaration":       {provider: "declarationProvider", merge: concat},
+	"textDocument/definition":        {provider: "definitionProvider", merge: concat},
+	"textDocument/typeDefinition":    {provider: "typeDefinitionProvider", merge: concat},
+	"textDocument/implementation":    {provider: "implementationProvider", merge: concat},
+	"textDocument/references":        {provider: "referencesProvider", merge: concat},
+	"textDocument/documentHighlight": {provider: "documentHighlightProvider", merge: concat},
+	"textDocument/documentSymbol":    {provider: "documentSymbolProvider", merge: concat},
+	"textDocument/codeAction":        {provider: "codeActionProvider", merge: concat, resolve: true},
+	"textDocument/codeLens":          {provider: "codeLensProvider", merge: concat, resolve: true},
+	"textDocument/documentLink":      {provider: "documentLinkProvider", merge: concat, resolve: true},
+	"textDocument/documentColor":     {provider: "colorProvider", merge: concat},
+	"textDocument/colorPresentation": {provider: "colorProvider", merge: concat},
+	"textDocument/formatting":        {provider: "documentFormattingProvider"},
+	"textDocument/rangeFormatting":   {provider: "documentRangeFormattingProvider"},
+	"textDocument/rename":            {provider: "renameProvider"},
+	"textDocument/prepareRename":     {provider: "renameProvider.prepareProvider"},
+	"textDocument/foldingRange":      {provider: "foldingRangeProvider", merge: concat},
+	"textDocument/selectionRange":    {provider: "selectionRangeProvider"},
+	"textDocument/inlayHint":         {provider: "inlayHintProvider", merge: concat, resolve: true},
+	"textDocument/willSaveWaitUntil": {provider: "textDocumentSync.willSaveWaitUntil"},
+	"workspace/symbol":               {provider: "workspaceSymbolProvider", merge: concat},
+}
+
+// resolveProviders are the capabilities of the backends resolving items, by
+// resolve method.
+var resolveProviders = map[string]string{
+	"completionItem/resolve": "completionProvider.resolveProvider",
+	"codeAction/resolve":     "codeActionProvider.resolveProvider",
+	"codeLens/resolve":       "codeLensProvider.resolveProvider",
+	"documentLink/resolve":   "documentLinkProvider.resolveProvider",
+	"inlayHint/resolve":      "inlayHintProvider.resolveProvider",
+}
+
+// requestParams are the parameters of requests used to route them.
+type requestParams struct {
+	TextDocument *lsp.TextDocumentIdentifier `json:"textDocument"`
+	Context      *struct {
+		TriggerKind      int      `json:"triggerKind"`      // of completion
+		TriggerCharacter string   `json:"triggerCharacter"` // of completion
+		Only             []string `json:"only"`             // of code actions
+	} `json:"context"`
+}
+
+// request returns a callback sending the requests of method to the backends
+// routed to, and merging their results.
+func (m *Mux) request(method string, r route) server.CallbackFunc {
+	return func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var p requestParams
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+
+		replies, err := m.fanOut(ctx, m.targets(method, r, p), method, params)
+		if err != nil || len(replies) == 0 {
+			return nil, err
+		}
+
+		switch r.merge {
+		case concat:
+			return concatResults(replies, r.resolve)
+		case completion:
+			return mergeCompletions(replies)
+		}
+		return replies[0].result, nil
+	}
+}
+
+// targets returns the backends the request of method is routed to.
+func (m *Mux) targets(method string, r route, p requestParams) []*conn {
+	var language string
+	if p.TextDocument != nil {
+		language = m.language(p.TextDocument.URI)
+	}
+
+	var targets []*conn
+	for _, c := range m.connections() {
+		switch {
+		case !m.provides(c, method, r.provider):
+		case p.TextDocument != nil && !c.handles(p.TextDocument.URI, language):
+		case method == "textDocument/completion" && !triggeredBy(c, p):
+		case method == "textDocument/codeAction" && !offersKinds(c, p):
+		default:
+			targets = append(targets, c)
+		}
+	}
+	return targets
+}
+
+// triggeredBy reports whether the backend c completes after the character
+// the completion request p was triggered by, if any.
+func triggeredBy(c *conn, p requestParams) bool {
+	const triggerCharacter = 2
+	if p.Context == nil || p.Context.TriggerKind != triggerCharacter {
+		return true
+	}
+
+	characters, _ := capability(c.capabilities, "completionProvider.triggerCharacters").([]interface{})
+	for _, character := range characters {
+		if character == p.Context.TriggerCharacter {
+			return true
+		}
+	}
+	return false
+}
+
+// offersKinds reports whether the backend c may offer the kinds of code
+// actions the request p is restricted to, if any.
+func offersKinds(c *conn, p requestParams) bool {
+	if p.Context == nil || len(p.Context.Only) == 0 {
+		return true
+	}
+
+	kinds, ok := capability(c.capabilities, "codeActionProvider.codeActionKinds").([]interface{})
+	if !ok {
+		return true
+	}
+	for _, kind := range kinds {
+		k, _ := kind.(string)
+		for _, only := range p.Context.Only {
+			// Kinds are hierarchical: "refactor" covers "refactor.extract".
+			if k == only || strings.HasPrefix(k, only+".") || strings.HasPrefix(only, k+".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reply is the response of a backend to a request.
+type reply struct {
+	conn   *conn
+	result json.RawMessage
+}
+
+// fanOut sends a request to the backends targets, and returns their non-null
+// results in the order of the backends. Failures are logged, and the first is
+// returned only if every backend fails.
+func (m *Mux) fanOut(ctx context.Context, targets []*conn, method string, params *fastjson.RawMessage) ([]reply, error) {
+	results := make([]json.RawMessage, len(targets))
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, c := range targets {
+		wg.Add(1)
+		go func(i int, c *conn) {
+			defer wg.Done()
+			errs[i] = c.client.Call(ctx, method, rawParams(params), &results[i])
+		}(i, c)
+	}
+	wg.Wait()
+
+	var replies []reply
+	failed := 0
+	for i, c := range targets {
+		switch {
+		case errs[i] != nil:
+			failed++
+		case !isNull(results[i]):
+			replies = append(replies, reply{conn: c, result: results[i]})
+		}
+	}
+	if failed > 0 && failed == len(targets) {
+		return nil, errs[0]
+	}
+	for i, c := range targets {
+		if errs[i] != nil {
+			m.Logger().Warningf("[mux] %s: %s: %v", c.name, method, errs[i])
+		}
+	}
+	return replies, nil
+}
+
+// concatResults joins the results of replies in a list, marking the items
+// with the backend which returned them if they are resolved later.
+func concatResults(replies []reply, resolve bool) (interface{}, error) {
+	items := []json.RawMessage{}
+	for _, r := range replies {
+		start := len(items)
+		if isArray(r.result) {
+			var list []json.RawMessage
+			if err := json.Unmarshal(r.result, &list); err != nil {
+				return nil, err
+			}
+			items = append(items, list...)
+		} else {
+			items = append(items, r.result)
+		}
+
+		if resolve {
+			for i := start; i < len(items); i++ {
+				items[i] = wrapData(items[i], r.conn)
+			}
+		}
+	}
+	return items, nil
+}
+
+// completionList is a list of completion items, as returned by backends.
+type completionList struct {
+	IsIncomplete bool                       `json:"isIncomplete"`
+	ItemDefaults map[string]json.RawMessage `json:"itemDefaults,omitempty"`
+	Items        []json.RawMessage          `json:"items"`
+}
+
+// mergeCompletions joins the completion lists of replies. The list is
+// incomplete if any is, and the defaults of each list are applied to its
+// items since they may differ between backends.
+func mergeCompletions(replies []reply) (interface{}, error) {
+	merged := completionList{Items: []json.RawMessage{}}
+	for _, r := range replies {
+		var list completionList
+		if isArray(r.result) {
+			if err := json.Unmarshal(r.result, &list.Items); err != nil {
+				return nil, err
+			}
+		} else if err := json.Unmarshal(r.result, &list); err != nil {
+			return nil, err
+		}
+
+		merged.IsIncomplete = merged.IsIncomplete || list.IsIncomplete
+		for _, item := range list.Items {
+			merged.Items = append(merged.Items, wrapData(applyDefaults(item, list.ItemDefaults), r.conn))
+		}
+	}
+	return merged, nil
+}
+
+// applyDefaults sets the fields of the completion item missing from it to
+// the defaults of its list.
+func applyDefaults(item json.RawMessage, defaults map[string]json.RawMessage) json.RawMessage {
+	if len(defaults) == 0 {
+		return item
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(item, &fields); err != nil {
+		return item
+	}
+
+	for _, name := range []string{"commitCharacters", "insertTextFormat", "insertTextMode", "data"} {
+		if _, ok := fields[name]; !ok && defaults[name] != nil {
+			fields[name] = defaults[name]
+		}
+	}
+	if _, ok := fields["textEdit"]; !ok && defaults["editRange"] != nil {
+		newText := fields["textEditText"]
+		if newText == nil {
+			newText = fields["label"]
+		}
+		var editRange map[string]json.RawMessage
+		if err := json.Unmarshal(defaults["editRange"], &editRange); err == nil {
+			if _, ok := editRange["insert"]; ok {
+				// An insert and a replace range.
+				editRange["newText"] = newText
+				fields["textEdit"], _ = json.Marshal(editRange)
+			} else {
+				fields["textEdit"], _ = json.Marshal(map[string]json.RawMessage{"range": defaults["editRange"], "newText": newText})
+			}
+		}
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return item
+	}
+	return data
+}
+
+// resolveData replaces the data of the items returned by backends, so that
+// resolve requests are routed to the backend which returned the item.
+type resolveData struct {
+	Backend *int            `json:"golspMuxBackend"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// wrapData replaces the data of item with a resolveData referring to c.
+// Commands, which aren't resolved, are left unchanged.
+func wrapData(item json.RawMessage, c *conn) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(item, &fields); err != nil {
+		return item
+	}
+	if command := bytes.TrimSpace(fields["command"]); len(command) > 0 && command[0] == '"' {
+		return item
+	}
+
+	index := c.index
+	data, err := json.Marshal(resolveData{Backend: &index, Data: fields["data"]})
+	if err != nil {
+		return item
+	}
+	fields["data"] = data
+	wrapped, err := json.Marshal(fields)
+	if err != nil {
+		return item
+	}
+	return wrapped
+}
+
+// resolve returns a callback sending resolve requests to the backend which
+// returned the item, if it resolves items with the capability at provider.
+// Other items are returned unchanged.
+func (m *Mux) resolve(method, provider string) server.CallbackFunc {
+	return func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var item map[string]json.RawMessage
+		if err := jsonrpc.Unmarshal(params, &item); err != nil {
+			return nil, err
+		}
+
+		var data resolveData
+		if err := json.Unmarshal(item["data"], &data); err != nil || data.Backend == nil || m.backend(*data.Backend) == nil {
+			rpcErr := jsonrpc.ErrInvalidParams()
+			rpcErr.Message = fmt.Sprintf("%s: item not returned by a backend", method)
+			return nil, rpcErr
+		}
+		c := m.backend(*data.Backend)
+		if !m.provides(c, method, provider) {
+			return json.RawMessage(*params), nil
+		}
+
+		if data.Data == nil {
+			delete(item, "data")
+		} else {
+			item["data"] = data.Data
+		}
+		var result json.RawMessage
+		if err := c.client.Call(ctx, method, item, &result); err != nil {
+			return nil, err
+		}
+		return wrapData(result, c), nil
+	}
+}
+
+// backend returns the backend at index, if initialized.
+func (m *Mux) backend(index int) *conn {
+	for _, c := range m.connections() {
+		if c.index == index {
+			return c
+		}
+	}
+	return nil
+}
+
+// executeCommand sends commands to the first backend advertising them, or
+// registering them dynamically.
+func (m *Mux) executeCommand(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	var p struct {
+		Command string `json:"command"`
+	}
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	for _, c := range m.connections() {
+		if !m.hasCommand(c, p.Command) {
+			continue
+		}
+
+		var result json.RawMessage
+		if err := c.client.Call(ctx, "workspace/executeCommand", rawParams(params), &result); err != nil {
+			return nil, err
+		}
+		if len(result) == 0 {
+			return nil, nil
+		}
+		return result, nil
+	}
+
+	rpcErr := jsonrpc.ErrInvalidParams()
+	rpcErr.Message = fmt.Sprintf("unknown command %q", p.Command)
+	return nil, rpcErr
+}
+
+// hasCommand reports whether the backend c advertises command, or registered
+// it dynamically.
+func (m *Mux) hasCommand(c *conn, command string) bool {
+	commands, _ := capability(c.capabilities, "executeCommandProvider.commands").([]interface{})
+	for _, advertised := range commands {
+		if advertised == command {
+			return true
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, registered := range c.registrations {
+		if registered.Method != "workspace/executeCommand" {
+			continue
+		}
+		var options lsp.ExecuteCommandOptions
+		if err := json.Unmarshal(registered.RegisterOptions, &options); err != nil {
+			continue
+		}
+		for _, name := range options.Commands {
+			if name == command {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isNull(raw json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(raw)
+	return len(trimmed) == 0 || string(trimmed) == "null"
+}
+
+func isArray(raw json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(raw)
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
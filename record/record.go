@@ -0,0 +1,125 @@
+// Package record records the messages exchanged in LSP sessions, and replays
+// them against servers.
+//
+// Recordings are JSONL files: each line is an Entry holding a message, when
+// it was exchanged and in which direction. Servers record their sessions with
+// server.Server's Record or RecordFile methods, or when the GOLSP_RECORD
+// environment variable holds the path of the file to record to. Replay feeds
+// a recording into a fresh server and reports how its responses differ from
+// the recorded ones, which turns a session from a user's editor into a
+// deterministic regression test.
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/intel-go/fastjson"
+)
+
+// Env is the environment variable holding the path of the file servers
+// record their sessions to.
+const Env = "GOLSP_RECORD"
+
+// Direction tells whether a message was received or sent by the server.
+type Direction string
+
+const (
+	// In is the direction of the messages received by the server.
+	In Direction = "in"
+	// Out is the direction of the messages sent by the server.
+	Out Direction = "out"
+)
+
+// Entry is a recorded message.
+type Entry struct {
+	Time      time.Time            `json:"time"`
+	Direction Direction            `json:"direction"`
+	Message   *fastjson.RawMessage `json:"message,omitempty"`
+
+	// Raw holds the content of messages that are not valid JSON, in which
+	// case Message is empty.
+	Raw string `json:"raw,omitempty"`
+}
+
+// Data returns the content of the message of e.
+func (e Entry) Data() []byte {
+	if e.Message == nil {
+		return []byte(e.Raw)
+	}
+	return *e.Message
+}
+
+// Recorder writes the entries of a session. It is safe for concurrent use.
+type Recorder struct {
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+// NewRecorder returns a recorder writing entries to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: w}
+}
+
+// Record writes an entry for the message data, exchanged now in the given
+// direction. It returns the first error writing an entry, after which no
+// entry is written anymore.
+func (r *Recorder) Record(direction Direction, data []byte) error {
+	e := Entry{Time: time.Now().UTC(), Direction: direction}
+	if json.Valid(data) {
+		message := fastjson.RawMessage(data)
+		e.Message = &message
+	} else {
+		e.Raw = string(data)
+	}
+
+	line, err := fastjson.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err == nil {
+		_, r.err = r.w.Write(append(line, '\n'))
+	}
+	return r.err
+}
+
+// Read reads the entries of a recording.
+func Read(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var e Entry
+		if err := fastjson.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("[record] line %d: %v", line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// ReadFile reads the entries of the recording at path.
+func ReadFile(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Read(f)
+}
@@ -0,0 +1,172 @@
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/wire"
+	"github.com/intel-go/fastjson"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	var b bytes.Buffer
+	r := NewRecorder(&b)
+	assert.NoError(t, r.Record(In, []byte("{\n  \"id\": 1,\n  \"method\": \"m\"\n}")))
+	assert.NoError(t, r.Record(Out, []byte("{oops")))
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	assert.Len(t, lines, 2)
+
+	entries, err := Read(&b)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, In, entries[0].Direction)
+		assert.JSONEq(t, `{"id": 1, "method": "m"}`, string(entries[0].Data()))
+		assert.WithinDuration(t, time.Now(), entries[0].Time, time.Minute)
+		assert.Equal(t, Out, entries[1].Direction)
+		assert.Equal(t, "{oops", string(entries[1].Data()))
+	}
+
+	_, err = Read(strings.NewReader(lines[0] + "\n\n{\n"))
+	assert.EqualError(t, err, "[record] line 3: unexpected end of JSON input")
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, io.ErrShortWrite
+}
+
+func TestRecorderError(t *testing.T) {
+	r := NewRecorder(failingWriter{})
+	assert.Equal(t, io.ErrShortWrite, r.Record(In, []byte("{}")))
+	assert.Equal(t, io.ErrShortWrite, r.Record(In, []byte("{}")))
+}
+
+// fakeServer responds to requests with their parameters, prefixed with its
+// version. Its "ask" requests are responded to with the answer of the client
+// to an "ask" request.
+type fakeServer struct {
+	version string
+}
+
+func (s fakeServer) Serve(rwc io.ReadWriteCloser) error {
+	defer rwc.Close()
+
+	reader := bufio.NewReader(rwc)
+	seq := 0
+	for {
+		data, err := wire.ReadMessage(reader)
+		if err != nil {
+			return nil
+		}
+		var msg struct {
+			ID     *fastjson.RawMessage `json:"id"`
+			Method string               `json:"method"`
+			Params interface{}          `json:"params"`
+			Result interface{}          `json:"result"`
+		}
+		if err := fastjson.Unmarshal(data, &msg); err != nil {
+			return err
+		}
+
+		switch {
+		case msg.Method == "exit":
+			return nil
+		case msg.Method == "ask":
+			seq++
+			if err := wire.WriteMessage(rwc, map[string]interface{}{"jsonrpc": "2.0", "id": seq, "method": "ask"}); err != nil {
+				return err
+			}
+			data, err := wire.ReadMessage(reader)
+			if err != nil {
+				return err
+			}
+			if err := fastjson.Unmarshal(data, &msg.Result); err != nil {
+				return err
+			}
+			if err := wire.WriteMessage(rwc, map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": msg.Result}); err != nil {
+				return err
+			}
+		case msg.ID != nil:
+			result := fmt.Sprintf("%s %v", s.version, msg.Params)
+			if err := wire.WriteMessage(rwc, map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": result}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func entry(direction Direction, message string) Entry {
+	raw := fastjson.RawMessage(message)
+	return Entry{Direction: direction, Message: &raw}
+}
+
+func TestReplay(t *testing.T) {
+	entries := []Entry{
+		entry(In, `{"jsonrpc": "2.0", "id": 1, "method": "echo", "params": "a"}`),
+		entry(Out, `{"jsonrpc": "2.0", "id": 1, "result": "v1 a"}`),
+		entry(In, `{"jsonrpc": "2.0", "method": "note"}`),
+		entry(In, `{"jsonrpc": "2.0", "id": 2, "method": "ask"}`),
+		entry(Out, `{"jsonrpc": "2.0", "id": 1, "method": "ask"}`),
+		entry(In, `{"jsonrpc": "2.0", "id": 1, "result": {"answer": 42}}`),
+		entry(Out, `{"jsonrpc": "2.0", "id": 2, "result": {"id": 1, "jsonrpc": "2.0", "result": {"answer": 42}}}`),
+		entry(In, `{"jsonrpc": "2.0", "id": "3", "method": "echo", "params": "b"}`),
+		entry(Out, `{"jsonrpc": "2.0", "id": "3", "result": "v1 b"}`),
+		entry(In, `{"jsonrpc": "2.0", "method": "exit"}`),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	report, err := Replay(ctx, fakeServer{"v1"}, entries)
+	assert.NoError(t, err)
+	assert.True(t, report.OK(), report.String())
+	assert.Equal(t, 3, report.Responses)
+
+	report, err = Replay(ctx, fakeServer{"v2"}, entries)
+	assert.NoError(t, err)
+	assert.False(t, report.OK())
+	if assert.Len(t, report.Differences, 2) {
+		d := report.Differences[0]
+		assert.Equal(t, "1", d.ID)
+		assert.Equal(t, "echo", d.Method)
+		assert.Equal(t, "--- recorded\n+++ replayed\n@@ -1,4 +1,4 @@\n {\n   \"id\": 1,\n-  \"result\": \"v1 a\"\n+  \"result\": \"v2 a\"\n }\n", d.Diff())
+		assert.Equal(t, `"3"`, report.Differences[1].ID)
+	}
+	assert.Contains(t, report.String(), "2/3 responses differ")
+}
+
+func TestReplayTimeout(t *testing.T) {
+	entries := []Entry{
+		entry(In, `{"jsonrpc": "2.0", "method": "note"}`),
+		entry(In, `{"jsonrpc": "2.0", "id": 1, "method": "echo", "params": "a"}`),
+		entry(Out, `{"jsonrpc": "2.0", "id": 1, "result": "a"}`),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	report, err := Replay(ctx, silentServer{}, entries)
+	assert.NoError(t, err)
+	if assert.Len(t, report.Differences, 1) {
+		assert.Equal(t, "", report.Differences[0].Got)
+	}
+	assert.Contains(t, report.String(), "echo (1):\nno response")
+}
+
+// silentServer reads messages without ever responding.
+type silentServer struct{}
+
+func (silentServer) Serve(rwc io.ReadWriteCloser) error {
+	_, err := io.Copy(ioutil.Discard, rwc)
+	return err
+}
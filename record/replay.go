@@ -0,0 +1,322 @@
+package record
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/goodgophers/golsp-sdk/wire"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Server serves a client over a stream, e.g. a *server.Server.
+type Server interface {
+	Serve(rwc io.ReadWriteCloser) error
+}
+
+// Difference is a response of the replayed server differing from the
+// recorded one.
+type Difference struct {
+	ID     string // the ID of the request, as JSON
+	Method string // the method of the request
+
+	// Want and Got are the recorded and replayed responses, as indented JSON.
+	// Got is empty if the replayed server did not respond.
+	Want, Got string
+}
+
+// Diff returns the unified diff between the recorded and replayed responses.
+func (d Difference) Diff() string {
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(d.Want),
+		B:        difflib.SplitLines(d.Got),
+		FromFile: "recorded",
+		ToFile:   "replayed",
+		Context:  3,
+	})
+	return diff
+}
+
+// Report is the result of a replay.
+type Report struct {
+	// Responses is the number of recorded responses compared.
+	Responses int
+	// Differences are the responses that differ, ordered as recorded.
+	Differences []Difference
+}
+
+// OK reports whether the replayed server responded as recorded.
+func (r *Report) OK() bool {
+	return len(r.Differences) == 0
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d/%d responses differ", len(r.Differences), r.Responses)
+	for _, d := range r.Differences {
+		fmt.Fprintf(&b, "\n\n%s (%s):\n", d.Method, d.ID)
+		if d.Got == "" {
+			b.WriteString("no response\n")
+			continue
+		}
+		b.WriteString(d.Diff())
+	}
+	return b.String()
+}
+
+// message holds the fields of JSON-RPC messages replays look at.
+type message struct {
+	ID     *fastjson.RawMessage `json:"id,omitempty"`
+	Method string               `json:"method,omitempty"`
+}
+
+func decode(data []byte) (message, bool) {
+	var msg message
+	err := fastjson.Unmarshal(data, &msg)
+	return msg, err == nil
+}
+
+func (m message) key() string {
+	if m.ID == nil {
+		return ""
+	}
+	return strings.TrimSpace(string(*m.ID))
+}
+
+// Replay feeds the messages of a recording received by the server into s, and
+// compares the responses of s with the recorded ones.
+//
+// Messages are fed in the recorded order. A message recorded after the
+// response to a request is fed only once s responded to that request, so that
+// replays are deterministic. Requests s sends are answered with the responses
+// recorded for the requests with the same ID, or with a MethodNotFound error.
+//
+// Replay returns once every message is fed and s responded to every request
+// or stopped serving, or once ctx is done: the requests s did not respond to
+// are then reported as differences.
+func Replay(ctx context.Context, s Server, entries []Entry) (*Report, error) {
+	r := newReplay(entries)
+
+	clientConn, serverConn := net.Pipe()
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(serverConn)
+	}()
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		r.read(clientConn)
+	}()
+
+	err := r.feed(ctx, clientConn)
+	if err == nil {
+		err = r.wait(ctx, r.order)
+	}
+	clientConn.Close()
+	<-read
+	if serveErr := <-served; err == nil && serveErr != nil && serveErr != io.ErrClosedPipe {
+		err = serveErr
+	}
+	if err != nil && err != ctx.Err() {
+		return nil, err
+	}
+	return r.report(), nil
+}
+
+type replay struct {
+	entries []Entry
+	methods map[string]string // the methods of the recorded requests, by ID
+	want    map[string][]byte // the recorded responses, by ID
+	answers map[string][]byte // the responses to the server's requests, by ID
+	order   []string          // the IDs of the recorded responses, in order
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	got     map[string][]byte
+	closed  bool          // whether the connection is closed
+	arrived chan struct{} // closed and replaced when a response arrives
+}
+
+func newReplay(entries []Entry) *replay {
+	r := &replay{
+		entries: entries,
+		methods: make(map[string]string),
+		want:    make(map[string][]byte),
+		answers: make(map[string][]byte),
+		got:     make(map[string][]byte),
+		arrived: make(chan struct{}),
+	}
+
+	for _, e := range entries {
+		msg, ok := decode(e.Data())
+		if !ok || msg.ID == nil {
+			continue
+		}
+		switch {
+		case e.Direction == In && msg.Method != "":
+			r.methods[msg.key()] = msg.Method
+		case e.Direction == In:
+			r.answers[msg.key()] = e.Data()
+		case e.Direction == Out && msg.Method == "":
+			if _, ok := r.methods[msg.key()]; ok {
+				r.want[msg.key()] = e.Data()
+				r.order = append(r.order, msg.key())
+			}
+		}
+	}
+	return r
+}
+
+// feed writes the requests and notifications of the recording to w.
+func (r *replay) feed(ctx context.Context, w io.Writer) error {
+	var awaited []string
+	for _, e := range r.entries {
+		msg, ok := decode(e.Data())
+		switch {
+		case e.Direction == Out:
+			if ok && msg.Method == "" && r.want[msg.key()] != nil {
+				awaited = append(awaited, msg.key())
+			}
+			continue
+		case ok && msg.Method == "" && msg.ID != nil:
+			// Responses to the server's requests are sent when it sends them.
+			continue
+		}
+
+		if err := r.wait(ctx, awaited); err != nil {
+			return err
+		}
+		awaited = nil
+		if err := r.write(w, e.Data()); err != nil {
+			if err == io.ErrClosedPipe {
+				// The server exited.
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// read reads the messages sent by the server until the connection is closed,
+// recording its responses and answering its requests.
+func (r *replay) read(rw io.ReadWriter) {
+	defer func() {
+		r.mu.Lock()
+		r.closed = true
+		close(r.arrived)
+		r.mu.Unlock()
+	}()
+
+	reader := bufio.NewReader(rw)
+	for {
+		data, err := wire.ReadMessage(reader)
+		if err != nil {
+			return
+		}
+
+		msg, ok := decode(data)
+		switch {
+		case !ok || msg.ID == nil:
+			continue
+
+		case msg.Method == "":
+			r.mu.Lock()
+			r.got[msg.key()] = data
+			close(r.arrived)
+			r.arrived = make(chan struct{})
+			r.mu.Unlock()
+
+		default:
+			answer, ok := r.answers[msg.key()]
+			if !ok {
+				answer, _ = fastjson.Marshal(struct {
+					Version string               `json:"jsonrpc"`
+					ID      *fastjson.RawMessage `json:"id"`
+					Error   *jsonrpc.Error       `json:"error"`
+				}{jsonrpc.Version, msg.ID, jsonrpc.ErrMethodNotFound()})
+			}
+			go func() {
+				_ = r.write(rw, answer)
+			}()
+		}
+	}
+}
+
+// wait waits for the server to respond to the requests with the given IDs,
+// or to close the connection.
+func (r *replay) wait(ctx context.Context, ids []string) error {
+	for _, id := range ids {
+		for {
+			r.mu.Lock()
+			_, ok := r.got[id]
+			arrived, closed := r.arrived, r.closed
+			r.mu.Unlock()
+			if closed {
+				return nil
+			}
+			if ok {
+				break
+			}
+
+			select {
+			case <-arrived:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
+
+func (r *replay) write(w io.Writer, data []byte) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	_, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	return err
+}
+
+func (r *replay) report() *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := &Report{Responses: len(r.order)}
+	for _, id := range r.order {
+		want := indent(r.want[id])
+		got := ""
+		if data, ok := r.got[id]; ok {
+			got = indent(data)
+		}
+		if got != want {
+			report.Differences = append(report.Differences, Difference{ID: id, Method: r.methods[id], Want: want, Got: got})
+		}
+	}
+	return report
+}
+
+// indent formats a message as indented JSON, with sorted object keys so that
+// equivalent messages compare equal.
+func indent(data []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return string(data)
+	}
+	if m, ok := v.(map[string]interface{}); ok {
+		delete(m, "jsonrpc")
+	}
+
+	indented, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return string(data)
+	}
+	return string(indented)
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/intel-go/fastjson"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// ServerCapabilities are the capabilities advertised by the server in its
+// response to the initialize request.
+//
+// It extends lsp.ServerCapabilities with capabilities introduced by later
+// versions of the LSP specification.
+type ServerCapabilities struct {
+	lsp.ServerCapabilities
+
+	// CodeActionProvider is either a bool or a *CodeActionOptions.
+	CodeActionProvider interface{} `json:"codeActionProvider,omitempty"`
+
+	Workspace *WorkspaceServerCapabilities `json:"workspace,omitempty"`
+}
+
+// WorkspaceServerCapabilities are the workspace specific capabilities of the
+// server.
+type WorkspaceServerCapabilities struct {
+	WorkspaceFolders *WorkspaceFoldersServerCapabilities `json:"workspaceFolders,omitempty"`
+	FileOperations   *FileOperationsServerCapabilities   `json:"fileOperations,omitempty"`
+}
+
+// WorkspaceFoldersServerCapabilities describes the support of the server for
+// multi-root workspaces.
+type WorkspaceFoldersServerCapabilities struct {
+	Supported bool `json:"supported,omitempty"`
+
+	// ChangeNotifications is either a bool telling whether the server wants to
+	// receive workspace/didChangeWorkspaceFolders notifications, or the ID
+	// under which the notification is registered dynamically.
+	ChangeNotifications interface{} `json:"changeNotifications,omitempty"`
+}
+
+// FileOperationsServerCapabilities tells which file operations the server
+// wants to be notified of, and for which files.
+type FileOperationsServerCapabilities struct {
+	DidCreate  *FileOperationRegistrationOptions `json:"didCreate,omitempty"`
+	WillCreate *FileOperationRegistrationOptions `json:"willCreate,omitempty"`
+	DidRename  *FileOperationRegistrationOptions `json:"didRename,omitempty"`
+	WillRename *FileOperationRegistrationOptions `json:"willRename,omitempty"`
+	DidDelete  *FileOperationRegistrationOptions `json:"didDelete,omitempty"`
+	WillDelete *FileOperationRegistrationOptions `json:"willDelete,omitempty"`
+}
+
+// FileOperationRegistrationOptions are the filters of the files a file
+// operation is sent for.
+type FileOperationRegistrationOptions struct {
+	Filters []FileOperationFilter `json:"filters"`
+}
+
+// FileOperationFilter denotes files by the scheme of their URI and a glob
+// pattern matching their path. An empty scheme matches any scheme.
+type FileOperationFilter struct {
+	Scheme  string               `json:"scheme,omitempty"`
+	Pattern FileOperationPattern `json:"pattern"`
+}
+
+// FileOperationPatternKind restricts a FileOperationPattern to files or
+// folders.
+type FileOperationPatternKind string
+
+const (
+	// FOPKFile matches files only.
+	FOPKFile FileOperationPatternKind = "file"
+	// FOPKFolder matches folders only.
+	FOPKFolder FileOperationPatternKind = "folder"
+)
+
+// FileOperationPattern is a glob pattern matching the paths of files or
+// folders.
+type FileOperationPattern struct {
+	Glob    string                       `json:"glob"`
+	Matches FileOperationPatternKind     `json:"matches,omitempty"`
+	Options *FileOperationPatternOptions `json:"options,omitempty"`
+}
+
+// FileOperationPatternOptions are the matching options of a
+// FileOperationPattern.
+type FileOperationPatternOptions struct {
+	IgnoreCase bool `json:"ignoreCase,omitempty"`
+}
+
+// CodeActionOptions describes the code actions supported by the server.
+type CodeActionOptions struct {
+	CodeActionKinds []lsp.CodeActionKind `json:"codeActionKinds,omitempty"`
+	ResolveProvider bool                 `json:"resolveProvider,omitempty"`
+}
+
+// InitializeResult is the result returned by the server to the initialize
+// request when the initialize callback doesn't provide one.
+type InitializeResult struct {
+	Capabilities ServerCapabilities `json:"capabilities"`
+}
+
+// Capabilities returns the capabilities the server advertises to clients.
+//
+// The returned value may be modified to declare capabilities until the server
+// is started.
+func (s *Server) Capabilities() *ServerCapabilities {
+	return &s.capabilities
+}
+
+// InitializeParams returns the parameters of the initialize request sent by
+// the client, or nil if the server has not been initialized yet.
+func (s *Server) InitializeParams() *lsp.InitializeParams {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.initializeParams
+}
+
+// DecodeInitializeParams decodes the parameters of the initialize request
+// into v, for access to the parameters lsp.InitializeParams doesn't define. It
+// returns ErrNotInitialized if the server has not been initialized yet.
+func (s *Server) DecodeInitializeParams(v interface{}) error {
+	s.mu.RLock()
+	raw := s.initializeRaw
+	s.mu.RUnlock()
+
+	if raw == nil {
+		return ErrNotInitialized
+	}
+	return fastjson.Unmarshal(*raw, v)
+}
+
+// ClientCapability looks up a capability sent by the client in its initialize
+// request, using its dotted path in the ClientCapabilities structure, e.g.
+// "textDocument.completion.completionList.itemDefaults".
+//
+// Unlike lsp.ClientCapabilities, this supports every capability defined by
+// the specification, including those introduced after the vendored types.
+func (s *Server) ClientCapability(path string) (value interface{}, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value = s.clientCapabilities
+	for _, key := range strings.Split(path, ".") {
+		object, isObject := value.(map[string]interface{})
+		if !isObject {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+
+	return value, value != nil
+}
+
+// ClientSupports reports whether the client capability at path is enabled,
+// that is, whether it is set to true, a non-empty string, a non-empty list or
+// an object.
+func (s *Server) ClientSupports(path string) bool {
+	value, ok := s.ClientCapability(path)
+	if !ok {
+		return false
+	}
+
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
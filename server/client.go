@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+)
+
+// client returns the connection to the client, if any.
+func (s *Server) client() (*conn, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.conn == nil {
+		return nil, ErrNoClient
+	}
+	return s.conn, nil
+}
+
+// Call sends a request to the client and waits for its response, which is
+// decoded into result unless it is nil. Errors returned by the client are
+// *jsonrpc.Error values.
+//
+// If ctx is cancelled before the client responds, the request is cancelled
+// with $/cancelRequest and ctx's error is returned.
+//
+// Requests can only be sent to clients connected over a stream, ErrNoClient
+// is returned otherwise.
+func (s *Server) Call(ctx context.Context, method string, params, result interface{}) error {
+	c, err := s.client()
+	if err != nil {
+		return err
+	}
+
+	return c.call(ctx, method, params, result)
+}
+
+// Notify sends a notification to the client.
+//
+// Notifications can only be sent to clients connected over a stream,
+// ErrNoClient is returned otherwise.
+func (s *Server) Notify(ctx context.Context, method string, params interface{}) error {
+	c, err := s.client()
+	if err != nil {
+		return err
+	}
+
+	return c.notify(method, params)
+}
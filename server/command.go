@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/sourcegraph/go-lsp"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Command registers fn as the handler of the named command, run in response
+// to workspace/executeCommand requests. Registered commands are advertised in
+// the executeCommandProvider capability.
+//
+// fn must have the signature func(ctx context.Context, args T) (interface{},
+// error), where T describes the arguments of the command:
+//
+//   - a slice or array receives the whole arguments array,
+//   - a struct receives each argument in its exported fields, in order,
+//   - any other type, including pointers, receives the single argument.
+//
+// Requests whose arguments cannot be decoded into T are rejected with an
+// InvalidParams error. Command panics if fn doesn't have the expected
+// signature.
+func (s *Server) Command(name string, fn interface{}) {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 2 || t.NumOut() != 2 ||
+		t.In(0) != contextType || t.Out(1) != errorType {
+		panic(fmt.Errorf("[server] command %s: expected func(context.Context, T) (interface{}, error), got %s", name, t))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.commands == nil {
+		s.commands = make(map[string]reflect.Value)
+		s.On("workspace/executeCommand", s.executeCommand)
+	}
+	if _, ok := s.commands[name]; !ok {
+		if s.capabilities.ExecuteCommandProvider == nil {
+			s.capabilities.ExecuteCommandProvider = &lsp.ExecuteCommandOptions{}
+		}
+		s.capabilities.ExecuteCommandProvider.Commands = append(s.capabilities.ExecuteCommandProvider.Commands, name)
+	}
+	s.commands[name] = v
+}
+
+func (s *Server) executeCommand(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	var p struct {
+		Command   string                `json:"command"`
+		Arguments []fastjson.RawMessage `json:"arguments"`
+	}
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	fn, ok := s.commands[p.Command]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, invalidParams(fmt.Sprintf("unknown command %q", p.Command))
+	}
+
+	args, err := decodeArguments(p.Arguments, fn.Type().In(1))
+	if err != nil {
+		return nil, invalidParams(fmt.Sprintf("command %s: %v", p.Command, err))
+	}
+
+	out := fn.Call([]reflect.Value{reflect.ValueOf(ctx), args})
+	if errValue := out[1].Interface(); errValue != nil {
+		return nil, errValue.(error)
+	}
+	return out[0].Interface(), nil
+}
+
+// decodeArguments decodes the arguments of a command into a new value of type
+// t, as documented by Server.Command.
+func decodeArguments(args []fastjson.RawMessage, t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t)
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		if args == nil {
+			args = []fastjson.RawMessage{}
+		}
+		data, err := fastjson.Marshal(args)
+		if err != nil {
+			return v, err
+		}
+		if err := fastjson.Unmarshal(data, v.Interface()); err != nil {
+			return v, err
+		}
+
+	case reflect.Struct:
+		var fields []int
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).PkgPath == "" {
+				fields = append(fields, i)
+			}
+		}
+		if len(args) > len(fields) {
+			return v, fmt.Errorf("expected at most %d arguments, got %d", len(fields), len(args))
+		}
+		for i, arg := range args {
+			if err := fastjson.Unmarshal(arg, v.Elem().Field(fields[i]).Addr().Interface()); err != nil {
+				return v, fmt.Errorf("argument %d: %v", i, err)
+			}
+		}
+
+	default:
+		if len(args) != 1 {
+			return v, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+		if err := fastjson.Unmarshal(args[0], v.Interface()); err != nil {
+			return v, err
+		}
+	}
+
+	return v.Elem(), nil
+}
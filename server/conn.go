@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"io"
+
+	"github.com/goodgophers/golsp-sdk/internal/rpc"
+	"github.com/goodgophers/golsp-sdk/record"
+	"github.com/osamingo/jsonrpc"
+)
+
+// conn is a connection to a client over a stream, dispatching the messages of
+// the client to the callbacks of the server.
+//
+// Notifications are dropped until the client is initialized, and requests are
+// checked against the lifecycle of the protocol. The lifecycle requests are
+// handled in order with notifications, as they change how the messages
+// following them are processed.
+type conn struct {
+	server       *Server
+	rpc          *rpc.Conn
+	recorder     *record.Recorder
+	recordFailed int32
+	exit         context.CancelFunc // stops serving, once the client exits
+}
+
+func newConn(s *Server, rwc io.ReadWriteCloser) *conn {
+	s.mu.RLock()
+	recorder := s.recorder
+	s.mu.RUnlock()
+
+	c := &conn{server: s, recorder: recorder}
+	opts := rpc.Options{
+		Strict: true,
+		Sync: func(method string) bool {
+			return method == "initialize" || method == "shutdown"
+		},
+		ErrClosed: ErrNoClient,
+		Received: func(msg *rpc.Message) {
+			if s.Trace() == TraceVerbose {
+				c.trace(msg)
+			}
+		},
+		// Messages are traced if the trace level is verbose when they are
+		// sent, not once they are written.
+		Sending: func(v interface{}) func() {
+			if s.Trace() != TraceVerbose {
+				return nil
+			}
+			return func() { c.trace(v) }
+		},
+		ReplyFailed: func(err error) {
+			s.logger.Errorf("[server] reply: %+v", err)
+		},
+	}
+	if recorder != nil {
+		opts.Record = c.record
+	}
+	c.rpc = rpc.New(rwc, rpc.HandlerFunc(c.handle), opts)
+	return c
+}
+
+// serve reads and dispatches messages until the client exits, the stream is
+// closed or ctx is cancelled.
+func (c *conn) serve(ctx context.Context) error {
+	ctx, c.exit = context.WithCancel(ctx)
+	defer c.exit()
+
+	return c.rpc.Serve(ctx)
+}
+
+// done returns a channel closed once serve returns.
+func (c *conn) done() <-chan struct{} {
+	return c.rpc.Done()
+}
+
+// handle dispatches a message of the client to the callback of its method.
+func (c *conn) handle(ctx context.Context, msg *rpc.Message) (interface{}, *jsonrpc.Error) {
+	if msg.ID == nil {
+		c.handleNotification(ctx, msg)
+		return nil, nil
+	}
+
+	if rpcErr := c.server.checkLifecycle(msg.Method); rpcErr != nil {
+		return nil, rpcErr
+	}
+	h, rpcErr := c.server.lspCallbacks.TakeMethod(&jsonrpc.Request{Version: msg.Version, Method: msg.Method})
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	return h.ServeJSONRPC(ctx, msg.Params)
+}
+
+func (c *conn) handleNotification(ctx context.Context, msg *rpc.Message) {
+	switch {
+	case msg.Method == "exit":
+		defer c.exit()
+	case !c.server.initialized():
+		return
+	}
+
+	h, rpcErr := c.server.lspCallbacks.TakeMethod(&jsonrpc.Request{Version: msg.Version, Method: msg.Method})
+	if rpcErr != nil {
+		// Notifications are never answered, even when unknown.
+		return
+	}
+	if _, rpcErr := h.ServeJSONRPC(ctx, msg.Params); rpcErr != nil {
+		c.server.logger.Errorf("[server] notification %s: %s", msg.Method, rpcErr.Message)
+	}
+}
+
+// call sends a request to the client and waits for its response, which is
+// decoded into result unless it is nil. If ctx is cancelled first, the request
+// is cancelled through $/cancelRequest.
+func (c *conn) call(ctx context.Context, method string, params, result interface{}) error {
+	return c.rpc.Call(ctx, method, params, result)
+}
+
+// notify sends a notification to the client.
+func (c *conn) notify(method string, params interface{}) error {
+	return c.rpc.Notify(method, params)
+}
@@ -0,0 +1,251 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/wire"
+	"github.com/intel-go/fastjson"
+	"github.com/stretchr/testify/assert"
+)
+
+// pipeClient is a client connected to a server over an in-memory pipe.
+type pipeClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+	done   chan error
+}
+
+func newPipeClient(t *testing.T, s *Server) *pipeClient {
+	t.Helper()
+
+	clientConn, serverConn := net.Pipe()
+	c := &pipeClient{t: t, conn: clientConn, reader: bufio.NewReader(clientConn), done: make(chan error, 1)}
+	go func() {
+		c.done <- s.Serve(serverConn)
+	}()
+	return c
+}
+
+func (c *pipeClient) send(msg map[string]interface{}) {
+	c.t.Helper()
+
+	msg["jsonrpc"] = "2.0"
+	assert.NoError(c.t, wire.WriteMessage(c.conn, msg))
+}
+
+func (c *pipeClient) sendRaw(data string) {
+	c.t.Helper()
+
+	_, err := c.conn.Write([]byte(data))
+	assert.NoError(c.t, err)
+}
+
+func (c *pipeClient) receive() map[string]interface{} {
+	c.t.Helper()
+
+	assert.NoError(c.t, c.conn.SetReadDeadline(time.Now().Add(time.Second)))
+	data, err := wire.ReadMessage(c.reader)
+	if !assert.NoError(c.t, err) {
+		c.t.FailNow()
+	}
+
+	var msg map[string]interface{}
+	assert.NoError(c.t, fastjson.Unmarshal(data, &msg))
+	return msg
+}
+
+func (c *pipeClient) initialize(capabilities map[string]interface{}) {
+	c.t.Helper()
+
+	c.send(map[string]interface{}{"id": 0, "method": "initialize", "params": map[string]interface{}{"capabilities": capabilities}})
+	assert.Contains(c.t, c.receive(), "result")
+	c.send(map[string]interface{}{"method": "initialized", "params": map[string]interface{}{}})
+}
+
+func TestServe(t *testing.T) {
+	s := NewServer(context.Background())
+	s.On("test/echo", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		return params, nil
+	})
+	s.On("test/null", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		return nil, nil
+	})
+	blocked := make(chan struct{})
+	s.On("test/block", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		close(blocked)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	notified := make(chan string, 1)
+	s.On("test/notify", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		notified <- string(*params)
+		return nil, nil
+	})
+
+	c := newPipeClient(t, s)
+
+	c.send(map[string]interface{}{"id": 1, "method": "test/echo", "params": map[string]interface{}{}})
+	assert.Equal(t, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      float64(1),
+		"error":   map[string]interface{}{"code": float64(-32002), "message": "Server not initialized"},
+	}, c.receive())
+
+	c.initialize(nil)
+
+	c.send(map[string]interface{}{"id": "two", "method": "test/echo", "params": []interface{}{"hello"}})
+	assert.Equal(t, map[string]interface{}{"jsonrpc": "2.0", "id": "two", "result": []interface{}{"hello"}}, c.receive())
+
+	c.send(map[string]interface{}{"id": 3, "method": "test/null"})
+	assert.Equal(t, map[string]interface{}{"jsonrpc": "2.0", "id": float64(3), "result": nil}, c.receive())
+
+	c.send(map[string]interface{}{"method": "test/notify", "params": []interface{}{1}})
+	assert.Equal(t, "[1]", <-notified)
+
+	c.send(map[string]interface{}{"id": 4, "method": "test/unknown"})
+	assert.Equal(t, float64(-32601), c.receive()["error"].(map[string]interface{})["code"])
+
+	c.send(map[string]interface{}{"id": 5, "method": "test/block"})
+	<-blocked
+	c.send(map[string]interface{}{"method": "$/cancelRequest", "params": map[string]interface{}{"id": 5}})
+	assert.Equal(t, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      float64(5),
+		"error":   map[string]interface{}{"code": float64(-32800), "message": "Request cancelled"},
+	}, c.receive())
+
+	c.sendRaw("Content-Length: 9\r\n\r\n{invalid}")
+	assert.Equal(t, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      nil,
+		"error":   map[string]interface{}{"code": float64(-32700), "message": "Parse error"},
+	}, c.receive())
+
+	c.sendRaw("Content-Length: 2\r\n\r\n[]")
+	assert.Equal(t, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      nil,
+		"error":   map[string]interface{}{"code": float64(-32600), "message": "Invalid Request"},
+	}, c.receive())
+
+	c.send(map[string]interface{}{"id": 6, "method": "shutdown"})
+	assert.Equal(t, map[string]interface{}{"jsonrpc": "2.0", "id": float64(6), "result": nil}, c.receive())
+
+	c.send(map[string]interface{}{"id": 7, "method": "test/echo", "params": []interface{}{}})
+	assert.Equal(t, float64(-32600), c.receive()["error"].(map[string]interface{})["code"])
+
+	c.send(map[string]interface{}{"method": "exit"})
+	assert.NoError(t, <-c.done)
+}
+
+func TestInitializeRetry(t *testing.T) {
+	s := NewServer(context.Background())
+	failed := false
+	s.On("initialize", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		if !failed {
+			failed = true
+			return nil, errors.New("not ready")
+		}
+		return nil, nil
+	})
+
+	c := newPipeClient(t, s)
+	c.send(map[string]interface{}{"id": 0, "method": "initialize", "params": map[string]interface{}{"capabilities": map[string]interface{}{}}})
+	assert.Contains(t, c.receive(), "error")
+	assert.Nil(t, s.InitializeParams())
+
+	// The client can retry after a failed initialize request.
+	c.initialize(nil)
+	assert.NotNil(t, s.InitializeParams())
+
+	c.send(map[string]interface{}{"method": "exit"})
+	assert.NoError(t, <-c.done)
+}
+
+func TestServeClosesOnContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := newPipeClient(t, NewServer(ctx))
+
+	cancel()
+
+	select {
+	case err := <-c.done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("server did not stop")
+	}
+}
+
+func TestRegisterCapability(t *testing.T) {
+	s := NewServer(context.Background())
+	registered := make(chan error, 1)
+	s.On("initialized", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		_, err := s.RegisterCapability(ctx, "textDocument/formatting", map[string]interface{}{
+			"documentSelector": []interface{}{map[string]interface{}{"language": "go"}},
+		})
+		registered <- err
+		return nil, nil
+	})
+
+	_, err := s.RegisterCapability(context.Background(), "textDocument/formatting", nil)
+	assert.Equal(t, ErrNotInitialized, err)
+
+	c := newPipeClient(t, s)
+	c.initialize(map[string]interface{}{
+		"textDocument": map[string]interface{}{
+			"formatting": map[string]interface{}{"dynamicRegistration": true},
+		},
+	})
+
+	request := c.receive()
+	assert.Equal(t, "client/registerCapability", request["method"])
+	assert.Equal(t, map[string]interface{}{
+		"registrations": []interface{}{map[string]interface{}{
+			"id":              "1",
+			"method":          "textDocument/formatting",
+			"registerOptions": map[string]interface{}{"documentSelector": []interface{}{map[string]interface{}{"language": "go"}}},
+		}},
+	}, request["params"])
+	c.send(map[string]interface{}{"id": request["id"], "result": nil})
+
+	assert.NoError(t, <-registered)
+	assert.Equal(t, []string{"textDocument/formatting"}, registeredMethods(s))
+
+	_, err = s.RegisterCapability(context.Background(), "textDocument/rangeFormatting", nil)
+	assert.True(t, errors.Is(err, ErrDynamicRegistrationUnsupported))
+
+	c.send(map[string]interface{}{"id": 1, "method": "shutdown"})
+	request = c.receive()
+	assert.Equal(t, "client/unregisterCapability", request["method"])
+	assert.Equal(t, map[string]interface{}{
+		"unregisterations": []interface{}{map[string]interface{}{"id": "1", "method": "textDocument/formatting"}},
+	}, request["params"])
+	c.send(map[string]interface{}{"id": request["id"], "result": nil})
+
+	assert.Equal(t, map[string]interface{}{"jsonrpc": "2.0", "id": float64(1), "result": nil}, c.receive())
+	assert.Empty(t, s.Registrations())
+
+	c.send(map[string]interface{}{"method": "exit"})
+	assert.NoError(t, <-c.done)
+}
+
+func TestCallWithoutClient(t *testing.T) {
+	s := NewServer(context.Background())
+
+	assert.Equal(t, ErrNoClient, s.Call(context.Background(), "window/showMessageRequest", nil, nil))
+	assert.Equal(t, ErrNoClient, s.Notify(context.Background(), "window/logMessage", nil))
+}
+
+func registeredMethods(s *Server) []string {
+	var methods []string
+	for _, r := range s.Registrations() {
+		methods = append(methods, r.Method)
+	}
+	return methods
+}
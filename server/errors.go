@@ -0,0 +1,82 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/goodgophers/golsp-sdk/internal/rpc"
+	"github.com/osamingo/jsonrpc"
+)
+
+const (
+	// ErrorCodeServerNotInitialized is returned for requests received before
+	// the initialize request.
+	ErrorCodeServerNotInitialized jsonrpc.ErrorCode = -32002
+	// ErrorCodeUnknownError is returned for errors without a more specific
+	// code.
+	ErrorCodeUnknownError jsonrpc.ErrorCode = -32001
+	// ErrorCodeRequestCancelled is returned for requests cancelled by the
+	// client through $/cancelRequest.
+	ErrorCodeRequestCancelled jsonrpc.ErrorCode = rpc.ErrorCodeRequestCancelled
+	// ErrorCodeContentModified is returned when the content of a document
+	// changed while a request was being processed.
+	ErrorCodeContentModified jsonrpc.ErrorCode = -32801
+)
+
+var (
+	// ErrNoClient is returned when sending a message to the client while none
+	// is connected over a stream, e.g. when the server is serving over HTTP.
+	ErrNoClient = errors.New("[server] no client connected")
+
+	// ErrNotInitialized is returned when sending a request to the client before
+	// it initialized the server.
+	ErrNotInitialized = errors.New("[server] not initialized")
+)
+
+// UnsupportedError is returned when sending a request the client did not
+// declare support for in its capabilities.
+type UnsupportedError struct {
+	Method string
+	// Capability is the path of the client capability declaring support for
+	// Method.
+	Capability string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("[server] %s unsupported by client (%s)", e.Method, e.Capability)
+}
+
+// ErrServerNotInitialized returns a server not initialized error.
+func ErrServerNotInitialized() *jsonrpc.Error {
+	return &jsonrpc.Error{
+		Code:    ErrorCodeServerNotInitialized,
+		Message: "Server not initialized",
+	}
+}
+
+// ErrRequestCancelled returns a request cancelled error.
+func ErrRequestCancelled() *jsonrpc.Error {
+	return rpc.ErrRequestCancelled()
+}
+
+// ErrContentModified returns a content modified error.
+func ErrContentModified() *jsonrpc.Error {
+	return &jsonrpc.Error{
+		Code:    ErrorCodeContentModified,
+		Message: "Content modified",
+	}
+}
+
+// invalidParams returns an InvalidParams error with the given message.
+func invalidParams(message string) *jsonrpc.Error {
+	err := jsonrpc.ErrInvalidParams()
+	err.Message = message
+	return err
+}
+
+// invalidRequest returns an InvalidRequest error with the given message.
+func invalidRequest(message string) *jsonrpc.Error {
+	err := jsonrpc.ErrInvalidRequest()
+	err.Message = message
+	return err
+}
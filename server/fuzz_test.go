@@ -0,0 +1,288 @@
+//go:build go1.18
+// +build go1.18
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/wire"
+	"github.com/intel-go/fastjson"
+)
+
+// fuzzTimeout bounds how long fuzzed sessions may take, past which the server
+// is considered deadlocked.
+const fuzzTimeout = 5 * time.Second
+
+// newFuzzServer returns a server with handlers for requests, notifications
+// and commands.
+func newFuzzServer() *Server {
+	s := NewServer(context.Background())
+	s.On("fuzz/echo", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		return params, nil
+	})
+	s.On("fuzz/notify", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var v interface{}
+		if params != nil {
+			if err := fastjson.Unmarshal(*params, &v); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	s.Command("fuzz.add", func(ctx context.Context, args struct{ A, B int }) (interface{}, error) {
+		return args.A + args.B, nil
+	})
+	return s
+}
+
+// fuzzSession writes data to a new session of s, and returns the messages s
+// sent until it closed the connection. It fails if s panics or does not
+// close the connection within fuzzTimeout.
+func fuzzSession(t *testing.T, s *Server, data []byte) []map[string]interface{} {
+	clientConn, serverConn := net.Pipe()
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(serverConn)
+	}()
+
+	var messages []map[string]interface{}
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		reader := bufio.NewReader(clientConn)
+		for {
+			data, err := wire.ReadMessage(reader)
+			if err != nil {
+				return
+			}
+			var msg map[string]interface{}
+			if err := json.Unmarshal(data, &msg); err != nil {
+				t.Errorf("server sent invalid JSON: %s", data)
+				continue
+			}
+			messages = append(messages, msg)
+		}
+	}()
+	go func() {
+		// Writes fail once the server closed the connection, e.g. after a
+		// header error.
+		_, _ = clientConn.Write(data)
+	}()
+
+	select {
+	case <-served:
+	case <-time.After(fuzzTimeout):
+		t.Fatalf("server still serving after %v", fuzzTimeout)
+	}
+	clientConn.Close()
+	<-read
+	return messages
+}
+
+func frame(body []byte) []byte {
+	return append([]byte(fmt.Sprintf("Content-Length: %d\r\n\r\n", len(body))), body...)
+}
+
+// checkResponses checks that the responses among messages are well formed.
+func checkResponses(t *testing.T, messages []map[string]interface{}) {
+	t.Helper()
+
+	for _, msg := range messages {
+		if _, ok := msg["method"]; ok {
+			continue
+		}
+		_, hasID := msg["id"]
+		_, hasResult := msg["result"]
+		_, hasError := msg["error"]
+		if msg["jsonrpc"] != "2.0" || !hasID || hasResult == hasError {
+			t.Errorf("malformed response %v", msg)
+		}
+	}
+}
+
+// findResponse returns the response with the given ID, as JSON.
+func findResponse(messages []map[string]interface{}, id string) (map[string]interface{}, bool) {
+	for _, msg := range messages {
+		if _, ok := msg["method"]; ok {
+			continue
+		}
+		if data, _ := json.Marshal(msg["id"]); string(data) == id {
+			return msg, true
+		}
+	}
+	return nil, false
+}
+
+func errorCode(msg map[string]interface{}) float64 {
+	e, _ := msg["error"].(map[string]interface{})
+	code, _ := e["code"].(float64)
+	return code
+}
+
+// FuzzServeMessage feeds a message into an initialized session, between
+// initialize and shutdown, tracing messages verbosely or not. Bodies that are not JSON must be answered with
+// ParseError, JSON values that are not objects with InvalidRequest, and the
+// session must remain usable in both cases.
+func FuzzServeMessage(f *testing.F) {
+	for _, seed := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"fuzz/echo","params":[1]}`,
+		`{"jsonrpc":"2.0","id":"1","method":"fuzz/echo","params":{"a":null}}`,
+		`{"jsonrpc":"2.0","method":"fuzz/notify","params":{}}`,
+		`{"jsonrpc":"2.0","method":"fuzz/notify","params":"}"}`,
+		`{"jsonrpc":"2.0","id":1,"method":"workspace/executeCommand","params":{"command":"fuzz.add","arguments":[1,2]}}`,
+		`{"jsonrpc":"2.0","id":1,"method":"workspace/executeCommand","params":{"command":"fuzz.add","arguments":["1",{}]}}`,
+		`{"jsonrpc":"2.0","id":1,"method":"workspace/executeCommand","params":{"command":"fuzz.sub"}}`,
+		`{"jsonrpc":"2.0","id":1,"method":"workspace/executeCommand","params":[]}`,
+		`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":1}}`,
+		`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":[]}}`,
+		`{"jsonrpc":"2.0","method":"$/cancelRequest"}`,
+		`{"jsonrpc":"2.0","method":"$/setTrace","params":{"value":"verbose"}}`,
+		`{"jsonrpc":"2.0","id":1,"result":null}`,
+		`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"Method not found"}}`,
+		`{"jsonrpc":"2.0","result":1}`,
+		`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"x"}}`,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","id":1,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+		`{"jsonrpc":"2.0","id":1,"method":"fuzz/unknown"}`,
+		`{"jsonrpc":"2.0","id":1}`,
+		`{"jsonrpc":"2.0","id":{},"method":"fuzz/echo"}`,
+		`{"jsonrpc":"2.0","id":null,"method":"fuzz/echo"}`,
+		`{"jsonrpc":"1.0","id":1,"method":"fuzz/echo"}`,
+		`{"id":1,"method":"fuzz/echo"}`,
+		`{"jsonrpc":"2.0","method":5}`,
+		`{"jsonrpc":2}`,
+		`{}`,
+		`[]`,
+		`[{"jsonrpc":"2.0","id":1,"method":"fuzz/echo"}]`,
+		`[{"jsonrpc":"2.0","method":"fuzz/notify"},{"jsonrpc":"2.0","id":2,"method":"fuzz/echo"}]`,
+		`null`,
+		`"2.0"`,
+		`42`,
+		`{`,
+		`{invalid}`,
+		`{"jsonrpc":"2.0","id":1,"method":"fuzz/echo"`,
+		"\xff",
+		``,
+	} {
+		f.Add([]byte(seed), false)
+		f.Add([]byte(seed), true)
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte, verbose bool) {
+		trace := TraceOff
+		if verbose {
+			trace = TraceVerbose
+		}
+
+		var data []byte
+		data = append(data, frame([]byte(`{"jsonrpc":"2.0","id":"fuzz-initialize","method":"initialize","params":{"capabilities":{},"trace":"`+trace+`"}}`))...)
+		data = append(data, frame([]byte(`{"jsonrpc":"2.0","method":"initialized","params":{}}`))...)
+		data = append(data, frame(body)...)
+		data = append(data, frame([]byte(`{"jsonrpc":"2.0","id":"fuzz-shutdown","method":"shutdown"}`))...)
+		data = append(data, frame([]byte(`{"jsonrpc":"2.0","method":"exit"}`))...)
+
+		// Messages are read ahead of initialize being handled: the trace level
+		// is set beforehand for them to be traced.
+		s := newFuzzServer()
+		s.trace = trace
+		messages := fuzzSession(t, s, data)
+		checkResponses(t, messages)
+
+		trimmed := bytes.TrimSpace(body)
+		var want float64
+		switch {
+		case !json.Valid(body):
+			want = -32700
+		case trimmed[0] != '{':
+			want = -32600
+		default:
+			return
+		}
+
+		r, ok := findResponse(messages, "null")
+		if !ok || errorCode(r) != want {
+			t.Fatalf("expected an error %v with a null ID, got %v", want, messages)
+		}
+		if r, ok := findResponse(messages, `"fuzz-shutdown"`); !ok || r["error"] != nil {
+			t.Fatalf("expected shutdown to succeed, got %v", messages)
+		}
+	})
+}
+
+// FuzzServeStream feeds an arbitrary stream into a session. The server must
+// answer malformed headers with ParseError, and stop serving once the stream
+// ends.
+func FuzzServeStream(f *testing.F) {
+	for _, seed := range []string{
+		"Content-Length: 2\r\n\r\n{}",
+		"Content-Length: 2\r\n\r\n[]Content-Length: 4\r\n\r\nnull",
+		"Content-Length: 2\n\n{}",
+		"Content-Length: two\r\n\r\n{}",
+		"Content-Length: -1\r\n\r\n{}",
+		"Content-Length: 1000000000000\r\n\r\n{}",
+		"Content-Type: application/json\r\n\r\n{}",
+		"Content-Length: 10\r\n\r\n{}",
+		"Content-Length: 2\r\n",
+		"\r\n\r\n",
+		"",
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// The stream ends after data, which the server must notice even
+		// without exit.
+		var out bytes.Buffer
+		served := make(chan error, 1)
+		go func() {
+			served <- newFuzzServer().Serve(&streamConn{Reader: bytes.NewReader(data), Writer: &out})
+		}()
+		select {
+		case <-served:
+		case <-time.After(fuzzTimeout):
+			t.Fatalf("server still serving after %v", fuzzTimeout)
+		}
+
+		var messages []map[string]interface{}
+		reader := bufio.NewReader(&out)
+		for {
+			data, err := wire.ReadMessage(reader)
+			if err != nil {
+				break
+			}
+			var msg map[string]interface{}
+			if err := json.Unmarshal(data, &msg); err != nil {
+				t.Fatalf("server sent invalid JSON: %s", data)
+			}
+			messages = append(messages, msg)
+		}
+		checkResponses(t, messages)
+
+		_, err := wire.ReadMessage(bufio.NewReader(bytes.NewReader(data)))
+		if _, ok := err.(*wire.HeaderError); ok {
+			if r, ok := findResponse(messages, "null"); !ok || errorCode(r) != -32700 {
+				t.Fatalf("expected a ParseError with a null ID for %v, got %v", err, messages)
+			}
+		}
+	})
+}
+
+// streamConn is a connection reading from a Reader and writing to a Writer.
+type streamConn struct {
+	io.Reader
+	io.Writer
+}
+
+func (*streamConn) Close() error {
+	return nil
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"errors"
 
 	"github.com/intel-go/fastjson"
 	"github.com/osamingo/jsonrpc"
@@ -18,11 +19,18 @@ type handler struct {
 
 // newHandler takes a CallbackFunc and wraps it in the Handle method of a new
 // instance of handler.
-func newHandler(serverContext context.Context, do CallbackFunc) handler {
+//
+// The callback receives the context of the request, which derives from the
+// server's context.
+func newHandler(do CallbackFunc) handler {
 	wrapperFunc := func(ctx context.Context, params *fastjson.RawMessage) (result interface{}, rpcErr *jsonrpc.Error) {
-		res, err := do(serverContext, params)
+		res, err := do(ctx, params)
 		if err != nil {
-			jsonrpcErr := jsonrpc.ErrInternal()
+			var jsonrpcErr *jsonrpc.Error
+			if errors.As(err, &jsonrpcErr) {
+				return nil, jsonrpcErr
+			}
+			jsonrpcErr = jsonrpc.ErrInternal()
 			jsonrpcErr.Message = err.Error()
 			return nil, jsonrpcErr
 		}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// initialize wraps the initialize callback registered by the user, if any, so
+// that the server records the client's parameters and capabilities.
+//
+// When the callback is nil or returns a nil result, the server responds with
+// its own capabilities.
+func (s *Server) initialize(do CallbackFunc) CallbackFunc {
+	return func(ctx context.Context, params *fastjson.RawMessage) (result interface{}, err error) {
+		var initializeParams lsp.InitializeParams
+		if err := jsonrpc.Unmarshal(params, &initializeParams); err != nil {
+			return nil, err
+		}
+		var raw struct {
+			Capabilities map[string]interface{} `json:"capabilities"`
+		}
+		if err := jsonrpc.Unmarshal(params, &raw); err != nil {
+			return nil, err
+		}
+
+		s.mu.Lock()
+		s.initializeParams = &initializeParams
+		s.initializeRaw = params
+		s.clientCapabilities = raw.Capabilities
+		s.trace = initializeParams.Trace
+		s.mu.Unlock()
+
+		if do != nil {
+			result, err = do(ctx, params)
+			if err != nil {
+				// The client may retry initializing the server.
+				s.mu.Lock()
+				s.initializeParams = nil
+				s.initializeRaw = nil
+				s.clientCapabilities = nil
+				s.trace = ""
+				s.mu.Unlock()
+				return nil, err
+			}
+			if result != nil {
+				return result, nil
+			}
+		}
+
+		// Commands may be registered concurrently, which modifies the
+		// capabilities.
+		s.mu.RLock()
+		capabilities := s.capabilities
+		if provider := capabilities.ExecuteCommandProvider; provider != nil {
+			commands := *provider
+			commands.Commands = append([]string(nil), provider.Commands...)
+			capabilities.ExecuteCommandProvider = &commands
+		}
+		s.mu.RUnlock()
+
+		return &InitializeResult{Capabilities: capabilities}, nil
+	}
+}
+
+// initializedNotification wraps the initialized callback registered by the
+// user, if any, so that the hooks registered with OnInitialized run first.
+func (s *Server) initializedNotification(do CallbackFunc) CallbackFunc {
+	return func(ctx context.Context, params *fastjson.RawMessage) (result interface{}, err error) {
+		s.mu.RLock()
+		hooks := s.initializedHooks
+		s.mu.RUnlock()
+
+		for _, hook := range hooks {
+			hook(ctx)
+		}
+
+		if do != nil {
+			return do(ctx, params)
+		}
+		return nil, nil
+	}
+}
+
+// OnInitialized registers fn to be called when the client sends the
+// initialized notification, before the initialized callback registered with
+// On, if any. This is where features typically register capabilities with the
+// client, or fetch its settings.
+func (s *Server) OnInitialized(fn func(ctx context.Context)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.initializedHooks = append(s.initializedHooks, fn)
+}
+
+// OnShutdown registers fn to be called when the client sends the shutdown
+// request, before the shutdown callback registered with On, if any. Features
+// release their resources there.
+func (s *Server) OnShutdown(fn func(ctx context.Context)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.shutdownHooks = append(s.shutdownHooks, fn)
+}
+
+// shutdown wraps the shutdown callback registered by the user, if any, so
+// that the hooks registered with OnShutdown run, and the server releases what
+// it registered with the client before the connection ends.
+func (s *Server) shutdown(do CallbackFunc) CallbackFunc {
+	return func(ctx context.Context, params *fastjson.RawMessage) (result interface{}, err error) {
+		s.mu.Lock()
+		s.shuttingDown = true
+		hooks := s.shutdownHooks
+		s.mu.Unlock()
+
+		for _, hook := range hooks {
+			hook(ctx)
+		}
+
+		if err := s.unregisterAll(ctx); err != nil {
+			s.logger.Errorf("[server] shutdown: unregister capabilities: %+v", err)
+		}
+
+		if do != nil {
+			return do(ctx, params)
+		}
+		return nil, nil
+	}
+}
+
+// initialized reports whether the client sent the initialize request.
+func (s *Server) initialized() bool {
+	return s.InitializeParams() != nil
+}
+
+// checkLifecycle returns the error to respond with to a request for method,
+// given the current state of the server, or nil if the request may proceed.
+func (s *Server) checkLifecycle(method string) *jsonrpc.Error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	switch {
+	case s.shuttingDown:
+		return invalidRequest("Server is shutting down")
+	case method == "initialize" && s.initializeParams != nil:
+		return invalidRequest("Server already initialized")
+	case method != "initialize" && s.initializeParams == nil:
+		return ErrServerNotInitialized()
+	}
+	return nil
+}
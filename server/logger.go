@@ -0,0 +1,216 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/sourcegraph/go-lsp"
+)
+
+// maxPendingMessages is the number of messages the logger buffers until a
+// client is initialized. Later messages are written to stderr.
+const maxPendingMessages = 1000
+
+// Logger sends log messages to the client through window/logMessage
+// notifications, where editors show them in the output of the server.
+//
+// Messages logged while no client is initialized, including before the server
+// is started, are buffered and sent once a client connected over a stream
+// sends the initialized notification. Messages logged while serving over
+// HTTP, where the server cannot notify the client, are written to stderr with
+// the log package.
+//
+// Messages can additionally be written to a local sink, e.g. a log file, with
+// SetOutput.
+type Logger struct {
+	server *Server
+
+	mu      sync.Mutex
+	level   lsp.MessageType
+	sink    *log.Logger
+	ready   *conn // the connection whose client is initialized, if any
+	http    bool  // whether the server is serving over HTTP
+	pending []lsp.LogMessageParams
+}
+
+func newLogger(s *Server) *Logger {
+	return &Logger{server: s, level: lsp.Log}
+}
+
+// Logger returns the logger of the server, which the SDK logs with.
+func (s *Server) Logger() *Logger {
+	return s.logger
+}
+
+// SetLevel sets the least severe type of messages logged, lsp.Log by default.
+func (l *Logger) SetLevel(level lsp.MessageType) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.level = level
+}
+
+// Enabled reports whether messages of the given type are logged.
+func (l *Logger) Enabled(typ lsp.MessageType) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return typ <= l.level
+}
+
+// SetOutput sets a sink every message is written to as well, prefixed with the
+// date, time and type of the message. A nil w removes the sink.
+func (l *Logger) SetOutput(w io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if w == nil {
+		l.sink = nil
+		return
+	}
+	l.sink = log.New(w, "", log.LstdFlags)
+}
+
+// Errorf logs an error message.
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	l.Output(lsp.MTError, fmt.Sprintf(format, v...))
+}
+
+// Warningf logs a warning message.
+func (l *Logger) Warningf(format string, v ...interface{}) {
+	l.Output(lsp.MTWarning, fmt.Sprintf(format, v...))
+}
+
+// Infof logs an information message.
+func (l *Logger) Infof(format string, v ...interface{}) {
+	l.Output(lsp.Info, fmt.Sprintf(format, v...))
+}
+
+// Debugf logs a log message, the least severe type of message.
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	l.Output(lsp.Log, fmt.Sprintf(format, v...))
+}
+
+// Output logs a message of the given type. A trailing newline is removed.
+func (l *Logger) Output(typ lsp.MessageType, message string) {
+	message = strings.TrimSuffix(message, "\n")
+	params := lsp.LogMessageParams{Type: typ, Message: message}
+	c, err := l.server.client()
+
+	// The notification is sent once l is unlocked, so that a slow client
+	// does not block every goroutine logging.
+	l.mu.Lock()
+	if typ > l.level {
+		l.mu.Unlock()
+		return
+	}
+	if l.sink != nil {
+		l.sink.Printf("[%s] %s", typeName(typ), message)
+	}
+	ready := err == nil && c == l.ready
+	pending := !ready && !l.http && len(l.pending) < maxPendingMessages
+	if pending {
+		l.pending = append(l.pending, params)
+	}
+	l.mu.Unlock()
+
+	switch {
+	case ready:
+		l.send(c, params)
+	case !pending:
+		log.Println(message)
+	}
+}
+
+// Writer returns a writer logging each write as a message of the given type,
+// e.g. to redirect the output of the log package:
+//
+//	log.SetOutput(s.Logger().Writer(lsp.Info))
+func (l *Logger) Writer(typ lsp.MessageType) io.Writer {
+	return logWriter{logger: l, typ: typ}
+}
+
+// StdLogger returns a *log.Logger logging messages of the given type.
+func (l *Logger) StdLogger(typ lsp.MessageType) *log.Logger {
+	return log.New(l.Writer(typ), "", 0)
+}
+
+// serveHTTP writes the messages buffered so far to stderr, like the ones
+// logged from now on, as the server is serving over HTTP.
+func (l *Logger) serveHTTP() {
+	l.mu.Lock()
+	if l.http {
+		l.mu.Unlock()
+		return
+	}
+	l.http = true
+	pending := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+
+	for _, params := range pending {
+		log.Println(params.Message)
+	}
+}
+
+// flush sends the messages buffered until the client is initialized. The
+// messages logged while flushing are buffered too, and sent in order.
+func (l *Logger) flush(ctx context.Context) {
+	c, err := l.server.client()
+	if err != nil {
+		return
+	}
+
+	for {
+		l.mu.Lock()
+		pending := l.pending
+		l.pending = nil
+		if len(pending) == 0 {
+			l.ready = c
+		}
+		l.mu.Unlock()
+
+		if len(pending) == 0 {
+			return
+		}
+		for _, params := range pending {
+			l.send(c, params)
+		}
+	}
+}
+
+// send sends a window/logMessage notification over c, falling back to stderr
+// if it fails.
+func (l *Logger) send(c *conn, params lsp.LogMessageParams) {
+	if err := c.notify("window/logMessage", params); err != nil {
+		log.Println(params.Message)
+	}
+}
+
+// typeName returns the name of a message type, as written to the sink.
+func typeName(typ lsp.MessageType) string {
+	switch typ {
+	case lsp.MTError:
+		return "Error"
+	case lsp.MTWarning:
+		return "Warning"
+	case lsp.Info:
+		return "Info"
+	default:
+		return "Log"
+	}
+}
+
+type logWriter struct {
+	logger *Logger
+	typ    lsp.MessageType
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w.logger.Output(w.typ, string(p))
+	return len(p), nil
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger(t *testing.T) {
+	s := NewServer(context.Background())
+	var sink bytes.Buffer
+	s.Logger().SetOutput(&sink)
+	s.Logger().SetLevel(lsp.Info)
+
+	// Messages logged before the server is started are sent to the first
+	// client.
+	s.Logger().Infof("[server] no client")
+
+	c := newPipeClient(t, s)
+	c.send(map[string]interface{}{"id": 0, "method": "initialize", "params": map[string]interface{}{"capabilities": map[string]interface{}{}}})
+	assert.Contains(t, c.receive(), "result")
+
+	s.Logger().Errorf("loading %s: %v", "go.mod", "no such file")
+	s.Logger().Debugf("filtered out")
+	s.Logger().StdLogger(lsp.MTWarning).Println("deprecated setting")
+
+	c.send(map[string]interface{}{"method": "initialized", "params": map[string]interface{}{}})
+	assert.Equal(t, map[string]interface{}{"type": float64(3), "message": "[server] no client"}, c.receive()["params"])
+	assert.Equal(t, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "window/logMessage",
+		"params":  map[string]interface{}{"type": float64(1), "message": "loading go.mod: no such file"},
+	}, c.receive())
+	assert.Equal(t, map[string]interface{}{"type": float64(2), "message": "deprecated setting"}, c.receive()["params"])
+
+	go s.Logger().Infof("ready")
+	assert.Equal(t, map[string]interface{}{"type": float64(3), "message": "ready"}, c.receive()["params"])
+
+	assert.Regexp(t, `^\S+ \S+ \[Info\] \[server\] no client
+\S+ \S+ \[Error\] loading go.mod: no such file
+\S+ \S+ \[Warning\] deprecated setting
+\S+ \S+ \[Info\] ready
+$`, sink.String())
+
+	c.send(map[string]interface{}{"method": "exit"})
+	assert.NoError(t, <-c.done)
+}
+
+func TestLoggerOverHTTP(t *testing.T) {
+	var stderr bytes.Buffer
+	log.SetOutput(&stderr)
+	defer log.SetOutput(os.Stderr)
+
+	s := NewServer(context.Background())
+	s.Logger().Infof("before serving")
+	assert.Empty(t, stderr.String())
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"shutdown"}`)))
+	s.Logger().Infof("while serving")
+	assert.Regexp(t, `^\S+ \S+ before serving
+\S+ \S+ while serving
+$`, stderr.String())
+}
+
+// chanWriter sends what is written to it on a channel.
+type chanWriter chan string
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestLoggerStalledClient(t *testing.T) {
+	s := NewServer(context.Background())
+	written := make(chanWriter, 1)
+	s.Logger().SetOutput(written)
+
+	c := newPipeClient(t, s)
+	c.initialize(map[string]interface{}{})
+	go s.Logger().Infof("ready")
+	<-written
+	assert.Equal(t, map[string]interface{}{"type": float64(3), "message": "ready"}, c.receive()["params"])
+
+	// The client does not read the message, which blocks sending it.
+	go s.Logger().Infof("stalled")
+	assert.Contains(t, <-written, "stalled")
+
+	set := make(chan struct{})
+	go func() {
+		s.Logger().SetLevel(lsp.MTWarning)
+		close(set)
+	}()
+	select {
+	case <-set:
+	case <-time.After(time.Second):
+		t.Fatal("logger blocked by the client")
+	}
+
+	assert.Equal(t, map[string]interface{}{"type": float64(3), "message": "stalled"}, c.receive()["params"])
+	c.send(map[string]interface{}{"method": "exit"})
+	assert.NoError(t, <-c.done)
+}
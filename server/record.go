@@ -0,0 +1,47 @@
+package server
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/goodgophers/golsp-sdk/record"
+)
+
+// Record records the messages exchanged with the clients served over a
+// stream to w, as JSONL entries of package record, so that sessions can be
+// replayed with record.Replay. It only applies to the clients served after
+// it is called.
+func (s *Server) Record(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.recorder = record.NewRecorder(w)
+}
+
+// RecordFile records the messages exchanged with clients, like Record, to the
+// file at path, which is created or truncated. The file is kept open for all
+// the clients served, and closed when s' context is done.
+func (s *Server) RecordFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	s.Record(f)
+	go func() {
+		<-s.ctx.Done()
+		f.Close()
+	}()
+	return nil
+}
+
+// record records a message exchanged with the client. Only the first error
+// is logged, as logging it sends a message, which is recorded in turn; it is
+// logged asynchronously since messages are recorded while being written.
+func (c *conn) record(direction record.Direction, data []byte) {
+	err := c.recorder.Record(direction, data)
+	if err != nil && atomic.CompareAndSwapInt32(&c.recordFailed, 0, 1) {
+		go c.server.logger.Errorf("[server] record: %+v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/record"
+	"github.com/intel-go/fastjson"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRecordedServer(greeting string) *Server {
+	s := NewServer(context.Background())
+	s.On("greet", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		var name string
+		if err := s.Call(ctx, "whoami", nil, &name); err != nil {
+			return nil, err
+		}
+		return greeting + " " + name, nil
+	})
+	return s
+}
+
+func TestRecord(t *testing.T) {
+	var b bytes.Buffer
+	s := newRecordedServer("hello")
+	s.Record(&b)
+
+	c := newPipeClient(t, s)
+	c.initialize(nil)
+	c.send(map[string]interface{}{"id": 1, "method": "greet"})
+	request := c.receive()
+	assert.Equal(t, "whoami", request["method"])
+	c.send(map[string]interface{}{"id": request["id"], "result": "bob"})
+	assert.Equal(t, "hello bob", c.receive()["result"])
+	c.send(map[string]interface{}{"id": 2, "method": "shutdown"})
+	c.receive()
+	c.send(map[string]interface{}{"method": "exit"})
+	assert.NoError(t, <-c.done)
+
+	entries, err := record.Read(&b)
+	assert.NoError(t, err)
+	var directions []record.Direction
+	for _, e := range entries {
+		directions = append(directions, e.Direction)
+	}
+	assert.Equal(t, []record.Direction{
+		record.In, record.Out, record.In, // initialize, initialized
+		record.In, record.Out, record.In, record.Out, // greet, whoami
+		record.In, record.Out, record.In, // shutdown, exit
+	}, directions)
+	assert.JSONEq(t, `{"jsonrpc": "2.0", "id": 1, "result": "hello bob"}`, string(entries[6].Data()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	report, err := record.Replay(ctx, newRecordedServer("hello"), entries)
+	assert.NoError(t, err)
+	assert.True(t, report.OK(), report.String())
+	assert.Equal(t, 3, report.Responses)
+
+	report, err = record.Replay(ctx, newRecordedServer("hi"), entries)
+	assert.NoError(t, err)
+	if assert.Len(t, report.Differences, 1) {
+		assert.Equal(t, "greet", report.Differences[0].Method)
+		assert.Contains(t, report.Differences[0].Diff(), `+  "result": "hi bob"`)
+	}
+}
+
+func TestRecordEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "record")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.jsonl")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	os.Setenv(record.Env, path)
+	s := NewServer(ctx)
+	os.Unsetenv(record.Env)
+
+	// Every session is recorded, not only the first one.
+	for i := 0; i < 2; i++ {
+		c := newPipeClient(t, s)
+		c.initialize(nil)
+		c.send(map[string]interface{}{"method": "exit"})
+		assert.NoError(t, <-c.done)
+	}
+
+	entries, err := record.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 8)
+}
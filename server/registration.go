@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrDynamicRegistrationUnsupported is returned when registering a capability
+// the client cannot register dynamically.
+var ErrDynamicRegistrationUnsupported = errors.New("[server] dynamic registration unsupported by client")
+
+// Registration is a capability registered dynamically with the client.
+type Registration struct {
+	ID              string      `json:"id"`
+	Method          string      `json:"method"`
+	RegisterOptions interface{} `json:"registerOptions,omitempty"`
+}
+
+type registrationParams struct {
+	Registrations []Registration `json:"registrations"`
+}
+
+type unregistration struct {
+	ID     string `json:"id"`
+	Method string `json:"method"`
+}
+
+type unregistrationParams struct {
+	// Unregisterations is misspelled in the specification, and kept as is for
+	// backward compatibility.
+	Unregisterations []unregistration `json:"unregisterations"`
+}
+
+// dynamicRegistrationCapabilities maps the methods whose dynamic registration
+// support isn't advertised at "<scope>.<feature>.dynamicRegistration" to the
+// client capability advertising it.
+var dynamicRegistrationCapabilities = map[string]string{
+	"textDocument/didOpen":                   "textDocument.synchronization.dynamicRegistration",
+	"textDocument/didChange":                 "textDocument.synchronization.dynamicRegistration",
+	"textDocument/didClose":                  "textDocument.synchronization.dynamicRegistration",
+	"textDocument/didSave":                   "textDocument.synchronization.dynamicRegistration",
+	"textDocument/willSave":                  "textDocument.synchronization.dynamicRegistration",
+	"textDocument/willSaveWaitUntil":         "textDocument.synchronization.dynamicRegistration",
+	"textDocument/prepareCallHierarchy":      "textDocument.callHierarchy.dynamicRegistration",
+	"textDocument/prepareTypeHierarchy":      "textDocument.typeHierarchy.dynamicRegistration",
+	"textDocument/documentColor":             "textDocument.colorProvider.dynamicRegistration",
+	"workspace/didChangeWorkspaceFolders":    "workspace.workspaceFolders",
+	"workspace/willCreateFiles":              "workspace.fileOperations.dynamicRegistration",
+	"workspace/didCreateFiles":               "workspace.fileOperations.dynamicRegistration",
+	"workspace/willRenameFiles":              "workspace.fileOperations.dynamicRegistration",
+	"workspace/didRenameFiles":               "workspace.fileOperations.dynamicRegistration",
+	"workspace/willDeleteFiles":              "workspace.fileOperations.dynamicRegistration",
+	"workspace/didDeleteFiles":               "workspace.fileOperations.dynamicRegistration",
+	"notebookDocument/sync":                  "notebookDocument.synchronization.dynamicRegistration",
+	"textDocument/semanticTokens":            "textDocument.semanticTokens.dynamicRegistration",
+	"textDocument/semanticTokens/full":       "textDocument.semanticTokens.dynamicRegistration",
+	"textDocument/semanticTokens/range":      "textDocument.semanticTokens.dynamicRegistration",
+	"textDocument/semanticTokens/full/delta": "textDocument.semanticTokens.dynamicRegistration",
+}
+
+// dynamicRegistrationCapability returns the path of the client capability
+// advertising support for registering method dynamically.
+func dynamicRegistrationCapability(method string) string {
+	if path, ok := dynamicRegistrationCapabilities[method]; ok {
+		return path
+	}
+	return strings.Replace(method, "/", ".", 1) + ".dynamicRegistration"
+}
+
+// SupportsDynamicRegistration reports whether the client supports registering
+// method dynamically.
+func (s *Server) SupportsDynamicRegistration(method string) bool {
+	return s.ClientSupports(dynamicRegistrationCapability(method))
+}
+
+// RegisterCapability registers method with the client through
+// client/registerCapability, along with its registration options. This is
+// typically used once the client sent the initialized notification, for
+// capabilities depending on the workspace or on settings.
+//
+// ErrDynamicRegistrationUnsupported is returned if the client doesn't support
+// registering method dynamically. Registrations are tracked by the server,
+// which unregisters them when shutting down.
+func (s *Server) RegisterCapability(ctx context.Context, method string, options interface{}) (Registration, error) {
+	if !s.initialized() {
+		return Registration{}, ErrNotInitialized
+	}
+	if !s.SupportsDynamicRegistration(method) {
+		return Registration{}, fmt.Errorf("%w: %s", ErrDynamicRegistrationUnsupported, method)
+	}
+
+	r := Registration{
+		ID:              strconv.FormatInt(atomic.AddInt64(&s.registrationSeq, 1), 10),
+		Method:          method,
+		RegisterOptions: options,
+	}
+	if err := s.Call(ctx, "client/registerCapability", registrationParams{Registrations: []Registration{r}}, nil); err != nil {
+		return Registration{}, err
+	}
+
+	s.mu.Lock()
+	s.registrations = append(s.registrations, r)
+	s.mu.Unlock()
+
+	return r, nil
+}
+
+// Unregister unregisters a capability previously registered with
+// RegisterCapability.
+func (s *Server) Unregister(ctx context.Context, r Registration) error {
+	params := unregistrationParams{Unregisterations: []unregistration{{ID: r.ID, Method: r.Method}}}
+	if err := s.Call(ctx, "client/unregisterCapability", params, nil); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, registered := range s.registrations {
+		if registered.ID == r.ID {
+			s.registrations = append(s.registrations[:i:i], s.registrations[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Registrations returns the capabilities currently registered with the
+// client, in the order they were registered.
+func (s *Server) Registrations() []Registration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]Registration(nil), s.registrations...)
+}
+
+// unregisterAll unregisters every capability registered with the client.
+func (s *Server) unregisterAll(ctx context.Context) error {
+	s.mu.Lock()
+	registrations := s.registrations
+	s.registrations = nil
+	s.mu.Unlock()
+
+	if len(registrations) == 0 {
+		return nil
+	}
+
+	var params unregistrationParams
+	for _, r := range registrations {
+		params.Unregisterations = append(params.Unregisterations, unregistration{ID: r.ID, Method: r.Method})
+	}
+	return s.Call(ctx, "client/unregisterCapability", params, nil)
+}
@@ -2,15 +2,22 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
+	"sync"
 	"syscall"
 
+	"github.com/goodgophers/golsp-sdk/record"
 	"github.com/intel-go/fastjson"
 	"github.com/osamingo/jsonrpc"
+	"github.com/sourcegraph/go-lsp"
 )
 
 // CallbackFunc defines the function signature that should be implemented to
@@ -25,73 +32,207 @@ import (
 //
 // You can return either a result (typically a map[string]interface{} JSON
 // compatible type, or an error. Errors you return will be wrapped in a
-// jsonrpc.ErrInternal type.
+// jsonrpc.ErrInternal type, unless they already are a *jsonrpc.Error.
 type CallbackFunc func(ctx context.Context, params *fastjson.RawMessage) (result interface{}, err error)
 
 // Sever represents an LSP server able to handle connections over TCP or stdio.
 type Server struct {
-	ctx          context.Context // not used over stdio
-	lspCallbacks *jsonrpc.MethodRepository
-	httpServer   *http.Server // not used over stdio
+	ctx             context.Context
+	lspCallbacks    *jsonrpc.MethodRepository
+	httpServer      *http.Server // not used over stdio
+	capabilities    ServerCapabilities
+	registrationSeq int64
+	logger          *Logger
+	recorder        *record.Recorder // only used over streams
+
+	mu                 sync.RWMutex
+	conn               *conn // only used over stdio
+	initializeParams   *lsp.InitializeParams
+	initializeRaw      *fastjson.RawMessage
+	clientCapabilities map[string]interface{}
+	shuttingDown       bool
+	trace              lsp.Trace
+	commands           map[string]reflect.Value
+	registrations      []Registration
+	initializedHooks   []func(ctx context.Context)
+	shutdownHooks      []func(ctx context.Context)
+	exitHooks          []func()
+	exited             chan struct{} // closed when the watchdog terminates the server
 }
 
 // NewServer returns a new server using the provided context.
+//
+// If the GOLSP_RECORD environment variable is set, the server records its
+// sessions to the file it names, see RecordFile.
+//
+// The server handles the initialize request on its own, responding with the
+// capabilities declared through Capabilities. Registering an initialize
+// callback with On lets you inspect the request and override the response.
+// Likewise, the shutdown request releases the capabilities registered with
+// the client before calling the shutdown callback, if any.
 func NewServer(ctx context.Context) *Server {
-	return &Server{ctx: ctx, lspCallbacks: jsonrpc.NewMethodRepository()}
+	s := &Server{ctx: ctx, lspCallbacks: jsonrpc.NewMethodRepository(), exited: make(chan struct{})}
+	s.logger = newLogger(s)
+	s.OnInitialized(s.logger.flush)
+	s.On("initialize", nil)
+	s.On("initialized", nil)
+	s.On("shutdown", nil)
+	s.On("$/setTrace", nil)
+
+	if path := os.Getenv(record.Env); path != "" {
+		if err := s.RecordFile(path); err != nil {
+			s.logger.Errorf("[server] record: %+v", err)
+		}
+	}
+
+	return s
 }
 
 // StartTCP starts the server in TCP mode, listening to connections on the
 // specified port. The server listens until an OS termination signal is received
 // or s' context is cancelled.
 func (s *Server) StartTCP(port int) {
+	s.logger.serveHTTP()
 	httpHandler := http.NewServeMux()
-	httpHandler.Handle("/", s.lspCallbacks)
+	httpHandler.Handle("/", s)
 	s.httpServer = &http.Server{
 		Addr:    fmt.Sprintf(":%d", port),
 		Handler: httpHandler,
+		BaseContext: func(net.Listener) context.Context {
+			return s.ctx
+		},
 	}
 
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
-		log.Printf("[server] starting TCP on port %d...\n", port)
+		s.logger.Infof("[server] starting TCP on port %d...", port)
 		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("[server] listen: %+v\n", err)
 		}
 	}()
-	log.Printf("[server] started TCP on port %d\n", port)
+	s.logger.Infof("[server] started TCP on port %d", port)
 
 	select {
 	case <-s.ctx.Done():
-		log.Println("[server] stopping - context done")
+		s.logger.Infof("[server] stopping - context done")
 		s.Stop()
 	case <-done:
-		log.Println("[server] stopping - OS signal")
+		s.logger.Infof("[server] stopping - OS signal")
+		s.Stop()
+	case <-s.exitedChan():
+		s.logger.Infof("[server] stopping - parent process gone")
 		s.Stop()
 	}
+	s.runExitHooks()
 }
 
+// StartStdio starts the server in stdio mode, reading messages from stdin and
+// writing responses to stdout. The server runs until the client sends the exit
+// notification, stdin is closed or s' context is cancelled.
 func (s *Server) StartStdio() {
-	log.Println("[server] starting stdio...")
+	s.logger.Infof("[server] starting stdio...")
+	if err := s.Serve(stdio{}); err != nil {
+		s.logger.Errorf("[server] stdio: %+v", err)
+	}
+	s.logger.Infof("[server] exited properly")
+}
+
+// Serve serves a single client connected over rwc, using the base protocol of
+// the LSP specification, until the client sends the exit notification, rwc is
+// closed or s' context is cancelled. rwc is closed when Serve returns, after
+// which s may serve another client: the session is reset, and the hooks
+// registered with OnExit reset the state features keep per client.
+//
+// Unlike over HTTP, the server can send requests and notifications to a client
+// connected over a stream, and the lifecycle of the protocol is enforced:
+// requests sent before initialize or after shutdown are rejected.
+func (s *Server) Serve(rwc io.ReadWriteCloser) error {
+	c := newConn(s, rwc)
+
+	s.mu.Lock()
+	if s.conn != nil {
+		s.mu.Unlock()
+		return errors.New("[server] already serving a client")
+	}
+	s.conn = c
+	s.mu.Unlock()
+
+	defer func() {
+		// The next client starts a session afresh.
+		s.mu.Lock()
+		s.conn = nil
+		s.initializeParams = nil
+		s.initializeRaw = nil
+		s.clientCapabilities = nil
+		s.shuttingDown = false
+		s.trace = ""
+		s.registrations = nil
+		select {
+		case <-s.exited:
+			s.exited = make(chan struct{})
+		default:
+		}
+		s.mu.Unlock()
+
+		s.runExitHooks()
+	}()
+
+	return c.serve(s.ctx)
+}
+
+// stdio is the stream formed by stdin and stdout.
+type stdio struct{}
+
+func (stdio) Read(p []byte) (int, error) {
+	return os.Stdin.Read(p)
+}
+
+func (stdio) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
+
+func (stdio) Close() error {
+	if err := os.Stdin.Close(); err != nil {
+		return err
+	}
+	return os.Stdout.Close()
+}
+
+// ServeHTTP serves JSON-RPC requests over HTTP, which lets the server be
+// mounted on an existing HTTP server.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.logger.serveHTTP()
+	s.lspCallbacks.ServeHTTP(w, r)
 }
 
 // Stop gracefully shuts down the server if it was listening over TCP.
 func (s *Server) Stop() {
 	if s.httpServer != nil {
-		log.Printf("[server] stopped")
+		s.logger.Infof("[server] stopped")
 
 		if err := s.httpServer.Shutdown(s.ctx); err != nil {
 			log.Fatalf("[server] shutdown failed: %+v\n", err)
 		}
-		log.Println("[server] exited properly")
+		s.logger.Infof("[server] exited properly")
 	}
 }
 
 // On registers an LSP callback function for a method. The method should be a
 // request or notification method defined in the LSP Specification.
 func (s *Server) On(method string, do func(ctx context.Context, params *fastjson.RawMessage) (result interface{}, err error)) {
-	h := newHandler(s.ctx, do)
+	switch method {
+	case "initialize":
+		do = s.initialize(do)
+	case "initialized":
+		do = s.initializedNotification(do)
+	case "shutdown":
+		do = s.shutdown(do)
+	case "$/setTrace":
+		do = s.setTrace(do)
+	}
+	h := newHandler(do)
 
 	var result interface{}
 	// @TODO not sure what params is used for.
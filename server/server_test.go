@@ -2,10 +2,12 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -128,6 +130,160 @@ func TestRegisterMethod(t *testing.T) {
 	}
 }
 
+func TestInitialize(t *testing.T) {
+	s := NewServer(context.Background())
+	s.Capabilities().HoverProvider = true
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	rpcClient := jsonRPCClient.NewClient(ts.URL)
+
+	var result map[string]interface{}
+	rpcErr := rpcClient.CallFor(&result, "initialize", map[string]interface{}{
+		"processId": 42,
+		"capabilities": map[string]interface{}{
+			"textDocument": map[string]interface{}{
+				"completion": map[string]interface{}{
+					"completionList": map[string]interface{}{"itemDefaults": []string{"editRange"}},
+				},
+			},
+		},
+	})
+
+	assert.NoError(t, rpcErr)
+	assert.Equal(t, map[string]interface{}{"capabilities": map[string]interface{}{"hoverProvider": true}}, result)
+	assert.Equal(t, 42, s.InitializeParams().ProcessID)
+	assert.True(t, s.ClientSupports("textDocument.completion.completionList.itemDefaults"))
+	assert.False(t, s.ClientSupports("textDocument.hover.dynamicRegistration"))
+}
+
+func TestCommand(t *testing.T) {
+	type position struct {
+		URI  string
+		Line int
+	}
+
+	tests := []struct {
+		Name             string
+		Command          string
+		Arguments        []interface{}
+		ExpectedResponse interface{}
+		ExpectedError    *jsonRPCClient.RPCError
+	}{
+		{
+			"when arguments are decoded into a struct",
+			"test.position",
+			[]interface{}{"file:///main.go", 12},
+			"file:///main.go:12",
+			nil,
+		},
+		{
+			"when arguments are decoded into a slice",
+			"test.sum",
+			[]interface{}{1, 2, 3},
+			json.Number("6"),
+			nil,
+		},
+		{
+			"when a single argument is decoded",
+			"test.echo",
+			[]interface{}{map[string]interface{}{"URI": "file:///main.go"}},
+			map[string]interface{}{"URI": "file:///main.go", "Line": json.Number("0")},
+			nil,
+		},
+		{
+			"when arguments don't match the expected type",
+			"test.position",
+			[]interface{}{12, "file:///main.go"},
+			nil,
+			&jsonRPCClient.RPCError{Code: -32602, Message: "command test.position: argument 0: json: cannot unmarshal number into Go value of type string"},
+		},
+		{
+			"when there are too many arguments",
+			"test.echo",
+			[]interface{}{1, 2},
+			nil,
+			&jsonRPCClient.RPCError{Code: -32602, Message: "command test.echo: expected 1 argument, got 2"},
+		},
+		{
+			"when the command is unknown",
+			"test.unknown",
+			nil,
+			nil,
+			&jsonRPCClient.RPCError{Code: -32602, Message: `unknown command "test.unknown"`},
+		},
+		{
+			"when the command fails",
+			"test.fail",
+			nil,
+			nil,
+			&jsonRPCClient.RPCError{Code: -32603, Message: "test error"},
+		},
+	}
+
+	s := NewServer(context.Background())
+	s.Command("test.position", func(ctx context.Context, args position) (interface{}, error) {
+		return fmt.Sprintf("%s:%d", args.URI, args.Line), nil
+	})
+	s.Command("test.sum", func(ctx context.Context, args []int) (interface{}, error) {
+		sum := 0
+		for _, n := range args {
+			sum += n
+		}
+		return sum, nil
+	})
+	s.Command("test.echo", func(ctx context.Context, args *position) (interface{}, error) {
+		return args, nil
+	})
+	s.Command("test.fail", func(ctx context.Context, args []interface{}) (interface{}, error) {
+		return nil, errors.New("test error")
+	})
+
+	assert.Equal(t, []string{"test.position", "test.sum", "test.echo", "test.fail"}, s.Capabilities().ExecuteCommandProvider.Commands)
+	assert.Panics(t, func() {
+		s.Command("test.invalid", func(args []int) error { return nil })
+	})
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	rpcClient := jsonRPCClient.NewClient(ts.URL)
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			resp, err := rpcClient.Call("workspace/executeCommand", map[string]interface{}{
+				"command":   tc.Command,
+				"arguments": tc.Arguments,
+			})
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.ExpectedResponse, resp.Result)
+			assert.Equal(t, tc.ExpectedError, resp.Error)
+		})
+	}
+}
+
+func TestCommandWhileInitializing(t *testing.T) {
+	s := NewServer(context.Background())
+	registered := make(chan struct{})
+	s.On("initialize", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		go func() {
+			defer close(registered)
+			s.Command("test.late", func(ctx context.Context, args []interface{}) (interface{}, error) {
+				return nil, nil
+			})
+		}()
+		return nil, nil
+	})
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	rpcClient := jsonRPCClient.NewClient(ts.URL)
+
+	_, err := rpcClient.Call("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}})
+	assert.NoError(t, err)
+	<-registered
+}
+
 func getFreePort(t *testing.T) int {
 	t.Helper()
 
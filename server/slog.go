@@ -0,0 +1,103 @@
+//go:build go1.21
+// +build go1.21
+
+package server
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/sourcegraph/go-lsp"
+)
+
+// SlogHandler returns a slog.Handler logging records with l. Records are
+// formatted as their message followed by their attributes as key=value pairs,
+// and sent with the message type matching their level:
+//
+//	slog.SetDefault(slog.New(s.Logger().SlogHandler()))
+func (l *Logger) SlogHandler() slog.Handler {
+	return &slogHandler{logger: l}
+}
+
+type slogHandler struct {
+	logger *Logger
+	attrs  string // preformatted attributes of WithAttrs
+	group  string // prefix of the keys of attributes, e.g. "request."
+}
+
+// messageType returns the message type of records of the given level.
+func messageType(level slog.Level) lsp.MessageType {
+	switch {
+	case level >= slog.LevelError:
+		return lsp.MTError
+	case level >= slog.LevelWarn:
+		return lsp.MTWarning
+	case level >= slog.LevelInfo:
+		return lsp.Info
+	default:
+		return lsp.Log
+	}
+}
+
+func (h *slogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.logger.Enabled(messageType(level))
+}
+
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		appendAttr(&b, h.group, a)
+		return true
+	})
+
+	h.logger.Output(messageType(r.Level), b.String())
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	b.WriteString(h.attrs)
+	for _, a := range attrs {
+		appendAttr(&b, h.group, a)
+	}
+	return &slogHandler{logger: h.logger, attrs: b.String(), group: h.group}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{logger: h.logger, attrs: h.attrs, group: h.group + name + "."}
+}
+
+// appendAttr appends a to b as " key=value", flattening groups.
+func appendAttr(b *strings.Builder, group string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			group += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			appendAttr(b, group, ga)
+		}
+		return
+	}
+
+	b.WriteString(" ")
+	b.WriteString(group)
+	b.WriteString(a.Key)
+	b.WriteString("=")
+	if s := a.Value.String(); strings.ContainsAny(s, " \t\n\"=") {
+		b.WriteString(strconv.Quote(s))
+	} else {
+		b.WriteString(s)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build go1.21
+// +build go1.21
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogHandler(t *testing.T) {
+	s := NewServer(context.Background())
+	s.Logger().SetLevel(lsp.Info)
+	var sink bytes.Buffer
+	s.Logger().SetOutput(&sink)
+
+	var stderr bytes.Buffer
+	log.SetOutput(&stderr)
+	defer log.SetOutput(os.Stderr)
+
+	logger := slog.New(s.Logger().SlogHandler()).With("server", "gopls").WithGroup("request")
+	logger.Debug("filtered out")
+	logger.Warn("slow request", "method", "textDocument/hover", slog.Group("timing", "ms", 120), "uri", "file:///my project/main.go")
+
+	assert.Regexp(t, `^\S+ \S+ \[Warning\] slow request server=gopls request\.method=textDocument/hover request\.timing\.ms=120 request\.uri="file:///my project/main.go"
+$`, sink.String())
+}
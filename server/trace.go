@@ -0,0 +1,148 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/goodgophers/golsp-sdk/internal/rpc"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/sourcegraph/go-lsp"
+)
+
+const (
+	// TraceOff disables tracing.
+	TraceOff lsp.Trace = "off"
+	// TraceMessages traces messages without their details.
+	TraceMessages lsp.Trace = "messages"
+	// TraceVerbose traces messages with their details, and makes the server
+	// trace every message it exchanges with the client.
+	TraceVerbose lsp.Trace = "verbose"
+)
+
+type logTraceParams struct {
+	Message string `json:"message"`
+	Verbose string `json:"verbose,omitempty"`
+}
+
+// Trace returns the trace level set by the client, either in the initialize
+// request or with $/setTrace notifications.
+func (s *Server) Trace() lsp.Trace {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.trace == "" {
+		return TraceOff
+	}
+	return s.trace
+}
+
+// LogTrace sends a $/logTrace notification to the client, unless tracing is
+// off. verbose holds additional details, only sent when the trace level is
+// verbose.
+//
+// Like Notify, it requires a client connected over a stream; traces are
+// dropped otherwise.
+func (s *Server) LogTrace(message, verbose string) {
+	c, err := s.client()
+	if err != nil {
+		return
+	}
+	c.logTrace(message, verbose)
+}
+
+// setTrace wraps the $/setTrace callback registered by the user, if any, so
+// that the server tracks the trace level.
+func (s *Server) setTrace(do CallbackFunc) CallbackFunc {
+	return func(ctx context.Context, params *fastjson.RawMessage) (result interface{}, err error) {
+		var p struct {
+			Value lsp.Trace `json:"value"`
+		}
+		if err := jsonrpc.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+
+		s.mu.Lock()
+		s.trace = p.Value
+		s.mu.Unlock()
+
+		if do != nil {
+			return do(ctx, params)
+		}
+		return nil, nil
+	}
+}
+
+func (c *conn) logTrace(message, verbose string) {
+	switch c.server.Trace() {
+	case TraceMessages:
+		verbose = ""
+	case TraceVerbose:
+	default:
+		return
+	}
+
+	_ = c.notify("$/logTrace", logTraceParams{Message: message, Verbose: verbose})
+}
+
+// trace traces a message received from or sent to the client.
+func (c *conn) trace(v interface{}) {
+	var text, verbose string
+	switch m := v.(type) {
+	case *rpc.Message:
+		switch {
+		case m.Method != "" && m.ID != nil:
+			text, verbose = fmt.Sprintf("Received request '%s - (%s)'.", m.Method, traceID(m.ID)), details("Params", m.Params)
+		case m.Method != "":
+			text, verbose = fmt.Sprintf("Received notification '%s'.", m.Method), details("Params", m.Params)
+		case m.Error != nil:
+			text, verbose = fmt.Sprintf("Received response '(%s)' with error.", traceID(m.ID)), details("Error", m.Error)
+		default:
+			text, verbose = fmt.Sprintf("Received response '(%s)'.", traceID(m.ID)), details("Result", m.Result)
+		}
+	case rpc.Request:
+		if m.Method == "$/logTrace" {
+			return
+		}
+		if m.ID != nil {
+			text = fmt.Sprintf("Sending request '%s - (%s)'.", m.Method, traceID(m.ID))
+		} else {
+			text = fmt.Sprintf("Sending notification '%s'.", m.Method)
+		}
+		verbose = details("Params", m.Params)
+	case rpc.Response:
+		text, verbose = fmt.Sprintf("Sending response '(%s)'.", traceID(m.ID)), details("Result", m.Result)
+	case rpc.ErrorResponse:
+		text, verbose = fmt.Sprintf("Sending response '(%s)' with error.", traceID(m.ID)), details("Error", m.Error)
+	default:
+		return
+	}
+
+	c.logTrace(text, verbose)
+}
+
+// traceID formats the ID of a message, which is null for responses to
+// messages whose ID could not be read.
+func traceID(id *fastjson.RawMessage) string {
+	if id == nil {
+		return "null"
+	}
+	return string(*id)
+}
+
+// details formats the named part of a message, e.g. its params, as indented
+// JSON. It is empty if v is nil.
+func details(name string, v interface{}) string {
+	data, err := fastjson.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return ""
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, data, "", "    "); err != nil {
+		return ""
+	}
+	return name + ": " + indented.String()
+}
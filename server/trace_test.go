@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/intel-go/fastjson"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrace(t *testing.T) {
+	s := NewServer(context.Background())
+	s.On("textDocument/hover", func(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+		s.LogTrace("computing hover", "for main.go")
+		return nil, nil
+	})
+	assert.Equal(t, TraceOff, s.Trace())
+
+	c := newPipeClient(t, s)
+	c.send(map[string]interface{}{"id": 0, "method": "initialize", "params": map[string]interface{}{"capabilities": map[string]interface{}{}, "trace": "messages"}})
+	assert.Contains(t, c.receive(), "result")
+	c.send(map[string]interface{}{"method": "initialized", "params": map[string]interface{}{}})
+	assert.Equal(t, TraceMessages, s.Trace())
+
+	c.send(map[string]interface{}{"id": 1, "method": "textDocument/hover", "params": map[string]interface{}{}})
+	assert.Equal(t, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "$/logTrace",
+		"params":  map[string]interface{}{"message": "computing hover"},
+	}, c.receive())
+	assert.Equal(t, map[string]interface{}{"jsonrpc": "2.0", "id": float64(1), "result": nil}, c.receive())
+
+	c.send(map[string]interface{}{"method": "$/setTrace", "params": map[string]interface{}{"value": "verbose"}})
+	waitTrace(t, s, TraceVerbose)
+	c.send(map[string]interface{}{"id": 2, "method": "textDocument/hover", "params": map[string]interface{}{"position": map[string]interface{}{"line": 1}}})
+	assert.Equal(t, map[string]interface{}{
+		"message": "Received request 'textDocument/hover - (2)'.",
+		"verbose": "Params: {\n    \"position\": {\n        \"line\": 1\n    }\n}",
+	}, c.receive()["params"])
+	assert.Equal(t, map[string]interface{}{"message": "computing hover", "verbose": "for main.go"}, c.receive()["params"])
+	assert.Equal(t, map[string]interface{}{"jsonrpc": "2.0", "id": float64(2), "result": nil}, c.receive())
+	assert.Equal(t, map[string]interface{}{"message": "Sending response '(2)'."}, c.receive()["params"])
+
+	c.send(map[string]interface{}{"method": "$/setTrace", "params": map[string]interface{}{"value": "off"}})
+	assert.Equal(t, map[string]interface{}{
+		"message": "Received notification '$/setTrace'.",
+		"verbose": "Params: {\n    \"value\": \"off\"\n}",
+	}, c.receive()["params"])
+	waitTrace(t, s, TraceOff)
+
+	c.send(map[string]interface{}{"id": 3, "method": "textDocument/hover", "params": map[string]interface{}{}})
+	assert.Equal(t, map[string]interface{}{"jsonrpc": "2.0", "id": float64(3), "result": nil}, c.receive())
+
+	c.send(map[string]interface{}{"method": "exit"})
+	assert.NoError(t, <-c.done)
+}
+
+// waitTrace waits for the $/setTrace notification setting the trace level of s
+// to be handled.
+func waitTrace(t *testing.T, s *Server, trace lsp.Trace) {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); s.Trace() != trace; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("trace level is %s, expected %s", s.Trace(), trace)
+		}
+	}
+}
+
+func TestTraceResponseWithoutID(t *testing.T) {
+	s := NewServer(context.Background())
+	c := newPipeClient(t, s)
+	c.send(map[string]interface{}{"id": 0, "method": "initialize", "params": map[string]interface{}{"capabilities": map[string]interface{}{}, "trace": "verbose"}})
+	assert.Contains(t, c.receive(), "result")
+	assert.Equal(t, "Sending response '(0)'.", c.receive()["params"].(map[string]interface{})["message"])
+	c.send(map[string]interface{}{"method": "initialized", "params": map[string]interface{}{}})
+	assert.Equal(t, map[string]interface{}{"message": "Received notification 'initialized'.", "verbose": "Params: {}"}, c.receive()["params"])
+
+	// Responses without ID cannot be routed, but are traced before being
+	// rejected.
+	c.send(map[string]interface{}{"result": 1})
+	assert.Equal(t, map[string]interface{}{"message": "Received response '(null)'.", "verbose": "Result: 1"}, c.receive()["params"])
+	assert.Contains(t, c.receive(), "error")
+	assert.Equal(t, "Sending response '(null)' with error.", c.receive()["params"].(map[string]interface{})["message"])
+
+	c.send(map[string]interface{}{"id": nil, "error": map[string]interface{}{"code": -32700, "message": "x"}})
+	assert.Equal(t, "Received response '(null)' with error.", c.receive()["params"].(map[string]interface{})["message"])
+	assert.Contains(t, c.receive(), "error")
+	assert.Equal(t, "Sending response '(null)' with error.", c.receive()["params"].(map[string]interface{})["message"])
+
+	c.send(map[string]interface{}{"method": "exit"})
+	assert.Equal(t, "Received notification 'exit'.", c.receive()["params"].(map[string]interface{})["message"])
+	assert.NoError(t, <-c.done)
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/internal/rpc"
+	"github.com/osamingo/jsonrpc"
+)
+
+const (
+	// DefaultWatchdogInterval is the interval at which the watchdog checks
+	// whether the parent process is alive.
+	DefaultWatchdogInterval = 3 * time.Second
+
+	// watchdogShutdownTimeout bounds the shutdown path run by the watchdog,
+	// during which the client, most likely gone, cannot answer requests.
+	watchdogShutdownTimeout = 5 * time.Second
+)
+
+// Liveness tells whether processes are alive.
+type Liveness interface {
+	Alive(pid int) bool
+}
+
+// LivenessFunc adapts a function to the Liveness interface.
+type LivenessFunc func(pid int) bool
+
+// Alive calls f.
+func (f LivenessFunc) Alive(pid int) bool {
+	return f(pid)
+}
+
+// ProcessLiveness checks whether processes of the local system are alive. On
+// platforms where this cannot be checked, processes are always alive.
+var ProcessLiveness Liveness = LivenessFunc(processAlive)
+
+// WatchParentProcess enables the watchdog, which makes the server exit when
+// the process that launched it, as told by the processId parameter of the
+// initialize request, dies. Editors normally shut their servers down before
+// exiting, but servers would otherwise linger when editors crash.
+//
+// Once the client is initialized, liveness is checked every interval. When the
+// parent process is gone, the server runs the shutdown path, as if the client
+// had sent the shutdown request, then exits as if it had sent the exit
+// notification. The hooks registered with OnShutdown and OnExit are where
+// resources are cleaned up.
+//
+// A zero interval stands for DefaultWatchdogInterval, and a nil liveness for
+// ProcessLiveness. WatchParentProcess must be called before the server is
+// started.
+func (s *Server) WatchParentProcess(interval time.Duration, liveness Liveness) {
+	if interval <= 0 {
+		interval = DefaultWatchdogInterval
+	}
+	if liveness == nil {
+		liveness = ProcessLiveness
+	}
+
+	s.OnInitialized(func(ctx context.Context) {
+		p := s.InitializeParams()
+		if p == nil || p.ProcessID <= 0 {
+			return
+		}
+
+		// Over a stream, the watchdog stops with the session.
+		var done <-chan struct{}
+		s.mu.RLock()
+		if s.conn != nil {
+			done = s.conn.done()
+		}
+		s.mu.RUnlock()
+		go s.watchParent(p.ProcessID, interval, liveness, done)
+	})
+}
+
+// OnExit registers fn to be called when the server exits: after the client
+// connected over a stream is gone, or when the server stops listening over
+// TCP. As a server may serve several clients over streams in turn, features
+// reset the state they keep per client there.
+func (s *Server) OnExit(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.exitHooks = append(s.exitHooks, fn)
+}
+
+// runExitHooks runs the hooks registered with OnExit.
+func (s *Server) runExitHooks() {
+	s.mu.RLock()
+	hooks := s.exitHooks
+	s.mu.RUnlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+// watchParent checks every interval whether the process pid is alive, and
+// terminates the server once it is not. It returns early once done is closed.
+func (s *Server) watchParent(pid int, interval time.Duration, liveness Liveness, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	exited := s.exitedChan()
+	for {
+		select {
+		case <-ticker.C:
+			if !liveness.Alive(pid) {
+				s.logger.Warningf("[server] parent process %d is gone, exiting", pid)
+				s.terminate()
+				return
+			}
+		case <-exited:
+			return
+		case <-done:
+			return
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// exitedChan returns the channel closed when the watchdog terminates the
+// server.
+func (s *Server) exitedChan() <-chan struct{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.exited
+}
+
+// terminate runs the shutdown path unless the client already requested it,
+// then makes the server exit.
+func (s *Server) terminate() {
+	s.mu.RLock()
+	shuttingDown := s.shuttingDown
+	c := s.conn
+	s.mu.RUnlock()
+
+	if !shuttingDown {
+		ctx, cancel := context.WithTimeout(s.ctx, watchdogShutdownTimeout)
+		defer cancel()
+
+		h, rpcErr := s.lspCallbacks.TakeMethod(&jsonrpc.Request{Version: jsonrpc.Version, Method: "shutdown"})
+		if rpcErr == nil {
+			if _, rpcErr := h.ServeJSONRPC(ctx, nil); rpcErr != nil {
+				s.logger.Errorf("[server] shutdown: %s", rpcErr.Message)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	select {
+	case <-s.exited:
+	default:
+		close(s.exited)
+	}
+	s.mu.Unlock()
+	if c != nil {
+		c.rpc.Enqueue(&rpc.Message{Version: jsonrpc.Version, Method: "exit"})
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchParentProcess(t *testing.T) {
+	s := NewServer(context.Background())
+
+	var alive int32 = 1
+	checked := make(chan int, 1)
+	s.WatchParentProcess(10*time.Millisecond, LivenessFunc(func(pid int) bool {
+		select {
+		case checked <- pid:
+		default:
+		}
+		return atomic.LoadInt32(&alive) == 1
+	}))
+
+	var shutdown, exited int32
+	s.OnShutdown(func(ctx context.Context) {
+		atomic.AddInt32(&shutdown, 1)
+	})
+	s.OnExit(func() {
+		atomic.AddInt32(&exited, 1)
+	})
+
+	c := newPipeClient(t, s)
+	c.send(map[string]interface{}{"id": 0, "method": "initialize", "params": map[string]interface{}{"processId": 4242, "capabilities": map[string]interface{}{}}})
+	assert.Contains(t, c.receive(), "result")
+	c.send(map[string]interface{}{"method": "initialized", "params": map[string]interface{}{}})
+
+	assert.Equal(t, 4242, <-checked)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&shutdown))
+
+	atomic.StoreInt32(&alive, 0)
+	assert.Equal(t, map[string]interface{}{"type": float64(2), "message": "[server] parent process 4242 is gone, exiting"}, c.receive()["params"])
+	select {
+	case err := <-c.done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("server still running after its parent process died")
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&shutdown))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&exited))
+}
+
+func TestWatchParentProcessSessions(t *testing.T) {
+	s := NewServer(context.Background())
+
+	var alive, checks int32 = 1, 0
+	s.WatchParentProcess(5*time.Millisecond, LivenessFunc(func(pid int) bool {
+		atomic.AddInt32(&checks, 1)
+		return atomic.LoadInt32(&alive) == 1
+	}))
+	session := func() *pipeClient {
+		c := newPipeClient(t, s)
+		c.send(map[string]interface{}{"id": 0, "method": "initialize", "params": map[string]interface{}{"processId": 4242, "capabilities": map[string]interface{}{}}})
+		assert.Contains(t, c.receive(), "result")
+		c.send(map[string]interface{}{"method": "initialized", "params": map[string]interface{}{}})
+		return c
+	}
+
+	// The watchdog stops once the client exits.
+	c := session()
+	for atomic.LoadInt32(&checks) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	c.send(map[string]interface{}{"method": "exit"})
+	assert.NoError(t, <-c.done)
+	stopped := atomic.LoadInt32(&checks)
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, atomic.LoadInt32(&checks) <= stopped+1, "watchdog still running after the session")
+
+	// The watchdog terminates the sessions following one it terminated.
+	for i := 0; i < 2; i++ {
+		atomic.StoreInt32(&alive, 1)
+		c = session()
+		atomic.StoreInt32(&alive, 0)
+		assert.Equal(t, "[server] parent process 4242 is gone, exiting", c.receive()["params"].(map[string]interface{})["message"])
+		select {
+		case err := <-c.done:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatalf("session %d still running after its parent process died", i+1)
+		}
+	}
+}
+
+func TestProcessLiveness(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("processes are always deemed alive")
+	}
+
+	assert.True(t, ProcessLiveness.Alive(os.Getpid()))
+
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	assert.NoError(t, cmd.Run())
+	assert.False(t, ProcessLiveness.Alive(cmd.Process.Pid))
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/sourcegraph/go-lsp"
+)
+
+type showDocumentParams struct {
+	URI       uri.URI    `json:"uri"`
+	External  bool       `json:"external,omitempty"`
+	TakeFocus bool       `json:"takeFocus,omitempty"`
+	Selection *lsp.Range `json:"selection,omitempty"`
+}
+
+type showDocumentResult struct {
+	Success bool `json:"success"`
+}
+
+// ShowMessageRequest shows a message to the user along with actions to pick
+// from, and returns the action picked, or nil if the message was dismissed.
+//
+// Unlike window/showDocument, window/showMessageRequest is supported by every
+// client: the window.showMessage capability only tells whether action items
+// may carry properties other than their title, which lsp.MessageActionItem
+// doesn't have.
+func (s *Server) ShowMessageRequest(ctx context.Context, typ lsp.MessageType, message string, actions ...lsp.MessageActionItem) (*lsp.MessageActionItem, error) {
+	if actions == nil {
+		actions = []lsp.MessageActionItem{}
+	}
+
+	var picked *lsp.MessageActionItem
+	params := lsp.ShowMessageRequestParams{Type: typ, Message: message, Actions: actions}
+	if err := s.Call(ctx, "window/showMessageRequest", params, &picked); err != nil {
+		return nil, err
+	}
+	return picked, nil
+}
+
+// ShowDocument asks the client to show the document at u, and reports whether
+// it succeeded. The document is opened in an external program, e.g. a web
+// browser, if external is set; otherwise selection, if any, is selected in the
+// editor, which is focused if takeFocus is set.
+//
+// It returns an *UnsupportedError if the client doesn't support
+// window/showDocument requests.
+func (s *Server) ShowDocument(ctx context.Context, u uri.URI, selection *lsp.Range, takeFocus, external bool) (bool, error) {
+	if !s.ClientSupports("window.showDocument.support") {
+		return false, &UnsupportedError{Method: "window/showDocument", Capability: "window.showDocument.support"}
+	}
+
+	var result showDocumentResult
+	params := showDocumentParams{URI: u, External: external, TakeFocus: takeFocus, Selection: selection}
+	if err := s.Call(ctx, "window/showDocument", params, &result); err != nil {
+		return false, err
+	}
+	return result.Success, nil
+}
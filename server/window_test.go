@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindowRequests(t *testing.T) {
+	s := NewServer(context.Background())
+	c := newPipeClient(t, s)
+	c.initialize(map[string]interface{}{"window": map[string]interface{}{"showDocument": map[string]interface{}{"support": true}}})
+
+	picked := make(chan *lsp.MessageActionItem, 1)
+	go func() {
+		item, err := s.ShowMessageRequest(context.Background(), lsp.Info, "Reload workspace?", lsp.MessageActionItem{Title: "Reload"}, lsp.MessageActionItem{Title: "Later"})
+		assert.NoError(t, err)
+		picked <- item
+	}()
+	request := c.receive()
+	assert.Equal(t, "window/showMessageRequest", request["method"])
+	assert.Equal(t, map[string]interface{}{
+		"type":    float64(3),
+		"message": "Reload workspace?",
+		"actions": []interface{}{map[string]interface{}{"title": "Reload"}, map[string]interface{}{"title": "Later"}},
+	}, request["params"])
+	c.send(map[string]interface{}{"id": request["id"], "result": map[string]interface{}{"title": "Reload"}})
+	assert.Equal(t, &lsp.MessageActionItem{Title: "Reload"}, <-picked)
+
+	go func() {
+		item, err := s.ShowMessageRequest(context.Background(), lsp.MTWarning, "Module not found")
+		assert.NoError(t, err)
+		picked <- item
+	}()
+	request = c.receive()
+	assert.Equal(t, []interface{}{}, request["params"].(map[string]interface{})["actions"])
+	c.send(map[string]interface{}{"id": request["id"], "result": nil})
+	assert.Nil(t, <-picked)
+
+	shown := make(chan bool, 1)
+	go func() {
+		success, err := s.ShowDocument(context.Background(), "file:///api/main.go", &lsp.Range{End: lsp.Position{Character: 4}}, true, false)
+		assert.NoError(t, err)
+		shown <- success
+	}()
+	request = c.receive()
+	assert.Equal(t, "window/showDocument", request["method"])
+	assert.Equal(t, map[string]interface{}{
+		"uri":       "file:///api/main.go",
+		"takeFocus": true,
+		"selection": map[string]interface{}{
+			"start": map[string]interface{}{"line": float64(0), "character": float64(0)},
+			"end":   map[string]interface{}{"line": float64(0), "character": float64(4)},
+		},
+	}, request["params"])
+	c.send(map[string]interface{}{"id": request["id"], "result": map[string]interface{}{"success": true}})
+	assert.True(t, <-shown)
+
+	c.send(map[string]interface{}{"method": "exit"})
+	assert.NoError(t, <-c.done)
+}
+
+func TestShowDocumentUnsupported(t *testing.T) {
+	s := NewServer(context.Background())
+	c := newPipeClient(t, s)
+	c.initialize(map[string]interface{}{})
+
+	_, err := s.ShowDocument(context.Background(), "https://pkg.go.dev", nil, false, true)
+	var unsupported *UnsupportedError
+	assert.True(t, errors.As(err, &unsupported))
+	assert.Equal(t, "window/showDocument", unsupported.Method)
+
+	c.send(map[string]interface{}{"method": "exit"})
+	assert.NoError(t, <-c.done)
+}
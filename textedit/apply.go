@@ -0,0 +1,59 @@
+package textedit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sourcegraph/go-lsp"
+)
+
+// Apply returns the result of applying edits to content.
+//
+// As per the specification, all ranges refer to the original content. Edits
+// inserting text at the same position are applied in the order they are
+// given. Overlapping edits are rejected.
+func Apply(content string, edits []lsp.TextEdit) (string, error) {
+	type span struct {
+		start, end int
+		text       string
+	}
+
+	m := NewMapper(content)
+	spans := make([]span, 0, len(edits))
+	for _, e := range edits {
+		start, err := m.Offset(e.Range.Start)
+		if err != nil {
+			return "", err
+		}
+		end, err := m.Offset(e.Range.End)
+		if err != nil {
+			return "", err
+		}
+		if end < start {
+			return "", fmt.Errorf("textedit: invalid range %s", e.Range)
+		}
+		spans = append(spans, span{start: start, end: end, text: e.NewText})
+	}
+
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		return spans[i].end < spans[j].end
+	})
+
+	var b strings.Builder
+	last := 0
+	for _, s := range spans {
+		if s.start < last {
+			return "", fmt.Errorf("textedit: overlapping edits at offset %d", s.start)
+		}
+		b.WriteString(content[last:s.start])
+		b.WriteString(s.text)
+		last = s.end
+	}
+	b.WriteString(content[last:])
+
+	return b.String(), nil
+}
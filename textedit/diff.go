@@ -0,0 +1,102 @@
+package textedit
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// Compute returns the edits transforming before into after.
+//
+// Documents are first compared line by line, then each changed region is
+// narrowed down to the characters that actually differ. This keeps edits small
+// enough for editors to preserve cursor positions and undo history, which a
+// full document replacement would break.
+//
+// The ranges of the returned edits refer to before, do not overlap and are
+// sorted, as expected from a textDocument/formatting response.
+func Compute(before, after string) []lsp.TextEdit {
+	if before == after {
+		return nil
+	}
+
+	a, b := splitLines(before), splitLines(after)
+	offsets := make([]int, len(a)+1)
+	for i, line := range a {
+		offsets[i+1] = offsets[i] + len(line)
+	}
+
+	m := NewMapper(before)
+	var edits []lsp.TextEdit
+	for _, op := range difflib.NewMatcherWithJunk(a, b, false, nil).GetOpCodes() {
+		if op.Tag == 'e' {
+			continue
+		}
+
+		start, end := offsets[op.I1], offsets[op.I2]
+		oldText, newText := before[start:end], strings.Join(b[op.J1:op.J2], "")
+
+		prefix := commonPrefix(oldText, newText)
+		suffix := commonSuffix(oldText[prefix:], newText[prefix:])
+
+		// Offsets are always valid for before, so errors cannot occur here.
+		r, _ := m.Range(start+prefix, end-suffix)
+		edits = append(edits, lsp.TextEdit{
+			Range:   r,
+			NewText: newText[prefix : len(newText)-suffix],
+		})
+	}
+
+	return edits
+}
+
+// splitLines splits s after each "\n", keeping line terminators.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// commonPrefix returns the length in bytes of the longest common prefix of a
+// and b which neither splits a rune nor a "\r\n" sequence of a.
+func commonPrefix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	for n > 0 && (!runeStart(a, n) || !runeStart(b, n)) {
+		n--
+	}
+	if n > 0 && n < len(a) && a[n-1] == '\r' && a[n] == '\n' {
+		n--
+	}
+	return n
+}
+
+// commonSuffix returns the length in bytes of the longest common suffix of a
+// and b which neither splits a rune nor a "\r\n" sequence of a.
+func commonSuffix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+	for n > 0 && (!runeStart(a, len(a)-n) || !runeStart(b, len(b)-n)) {
+		n--
+	}
+	if n > 0 && len(a)-n > 0 && a[len(a)-n-1] == '\r' && a[len(a)-n] == '\n' {
+		n--
+	}
+	return n
+}
+
+// runeStart reports whether offset i of s is at a rune boundary.
+func runeStart(s string, i int) bool {
+	return i >= len(s) || utf8.RuneStart(s[i])
+}
@@ -0,0 +1,135 @@
+// Package textedit computes and applies LSP text edits.
+//
+// Positions in the Language Server Protocol address characters in UTF-16 code
+// units, while Go strings are UTF-8 byte sequences. The Mapper type converts
+// between the two so that edits can be computed and applied on plain strings.
+package textedit
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/sourcegraph/go-lsp"
+)
+
+// Mapper converts between byte offsets and LSP positions for a given content.
+//
+// Lines may be terminated by "\n", "\r\n" or "\r", as allowed by the LSP
+// specification.
+type Mapper struct {
+	content string
+	starts  []int // byte offset at which each line starts
+}
+
+// NewMapper returns a Mapper indexing the lines of content.
+func NewMapper(content string) *Mapper {
+	starts := []int{0}
+	for i := 0; i < len(content); i++ {
+		switch content[i] {
+		case '\n':
+			starts = append(starts, i+1)
+		case '\r':
+			if i+1 < len(content) && content[i+1] == '\n' {
+				i++
+			}
+			starts = append(starts, i+1)
+		}
+	}
+	return &Mapper{content: content, starts: starts}
+}
+
+// Lines returns the number of lines in the content. A trailing line
+// terminator starts a new, empty, line.
+func (m *Mapper) Lines() int {
+	return len(m.starts)
+}
+
+// Offset converts pos to a byte offset into the content.
+//
+// A character beyond the end of its line resolves to the end of that line, as
+// required by the specification. A position inside a surrogate pair resolves to
+// the start of the corresponding rune.
+func (m *Mapper) Offset(pos lsp.Position) (int, error) {
+	if pos.Line < 0 || pos.Line >= len(m.starts) || pos.Character < 0 {
+		return 0, fmt.Errorf("textedit: position %s out of range", pos)
+	}
+
+	offset, end := m.starts[pos.Line], m.lineEnd(pos.Line)
+	for col := 0; offset < end; {
+		r, size := utf8.DecodeRuneInString(m.content[offset:end])
+		units := utf16Len(r)
+		if col+units > pos.Character {
+			break
+		}
+		col += units
+		offset += size
+	}
+
+	return offset, nil
+}
+
+// Position converts a byte offset into the content to an LSP position.
+func (m *Mapper) Position(offset int) (lsp.Position, error) {
+	if offset < 0 || offset > len(m.content) {
+		return lsp.Position{}, fmt.Errorf("textedit: offset %d out of range", offset)
+	}
+
+	line := m.lineOf(offset)
+	col := 0
+	for i := m.starts[line]; i < offset; {
+		r, size := utf8.DecodeRuneInString(m.content[i:offset])
+		col += utf16Len(r)
+		i += size
+	}
+
+	return lsp.Position{Line: line, Character: col}, nil
+}
+
+// Range converts a pair of byte offsets into an LSP range.
+func (m *Mapper) Range(start, end int) (lsp.Range, error) {
+	s, err := m.Position(start)
+	if err != nil {
+		return lsp.Range{}, err
+	}
+	e, err := m.Position(end)
+	if err != nil {
+		return lsp.Range{}, err
+	}
+
+	return lsp.Range{Start: s, End: e}, nil
+}
+
+// lineOf returns the line holding offset.
+func (m *Mapper) lineOf(offset int) int {
+	lo, hi := 0, len(m.starts)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if m.starts[mid] <= offset {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo
+}
+
+// lineEnd returns the offset at which line's content ends, excluding its
+// terminator.
+func (m *Mapper) lineEnd(line int) int {
+	end := len(m.content)
+	if line+1 < len(m.starts) {
+		end = m.starts[line+1]
+	}
+	for end > m.starts[line] && (m.content[end-1] == '\n' || m.content[end-1] == '\r') {
+		end--
+	}
+	return end
+}
+
+// utf16Len returns the number of UTF-16 code units needed to encode r.
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,199 @@
+package textedit
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapper(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Content  string
+		Offset   int
+		Position lsp.Position
+	}{
+		{"start of document", "abc\ndef", 0, lsp.Position{Line: 0, Character: 0}},
+		{"start of second line", "abc\ndef", 4, lsp.Position{Line: 1, Character: 0}},
+		{"end of document", "abc\ndef", 7, lsp.Position{Line: 1, Character: 3}},
+		{"after a CRLF terminator", "abc\r\ndef", 5, lsp.Position{Line: 1, Character: 0}},
+		{"after a CR terminator", "abc\rdef", 4, lsp.Position{Line: 1, Character: 0}},
+		{"after a trailing terminator", "abc\n", 4, lsp.Position{Line: 1, Character: 0}},
+		{"after a multi-byte rune", "é=1", 2, lsp.Position{Line: 0, Character: 1}},
+		{"after a surrogate pair", "😀=1", 4, lsp.Position{Line: 0, Character: 2}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			m := NewMapper(tc.Content)
+
+			pos, err := m.Position(tc.Offset)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.Position, pos)
+
+			offset, err := m.Offset(tc.Position)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.Offset, offset)
+		})
+	}
+}
+
+func TestMapperOffsetClamping(t *testing.T) {
+	m := NewMapper("ab\r\ncd")
+
+	offset, err := m.Offset(lsp.Position{Line: 0, Character: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, offset)
+
+	_, err = m.Offset(lsp.Position{Line: 2, Character: 0})
+	assert.Error(t, err)
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Before        string
+		After         string
+		ExpectedEdits []lsp.TextEdit
+	}{
+		{
+			"when documents are identical",
+			"package main\n",
+			"package main\n",
+			nil,
+		},
+		{
+			"when a single character changes",
+			"a := 1\nb := 2\n",
+			"a := 1\nb := 3\n",
+			[]lsp.TextEdit{
+				{Range: lsp.Range{Start: lsp.Position{Line: 1, Character: 5}, End: lsp.Position{Line: 1, Character: 6}}, NewText: "3"},
+			},
+		},
+		{
+			"when a line is inserted",
+			"a\nc\n",
+			"a\nb\nc\n",
+			[]lsp.TextEdit{
+				{Range: lsp.Range{Start: lsp.Position{Line: 1, Character: 0}, End: lsp.Position{Line: 1, Character: 0}}, NewText: "b\n"},
+			},
+		},
+		{
+			"when a line is deleted",
+			"a\nb\nc\n",
+			"a\nc\n",
+			[]lsp.TextEdit{
+				{Range: lsp.Range{Start: lsp.Position{Line: 1, Character: 0}, End: lsp.Position{Line: 2, Character: 0}}, NewText: ""},
+			},
+		},
+		{
+			"when a change follows a surrogate pair",
+			"x := \"😀\"+a\n",
+			"x := \"😀\"+b\n",
+			[]lsp.TextEdit{
+				{Range: lsp.Range{Start: lsp.Position{Line: 0, Character: 10}, End: lsp.Position{Line: 0, Character: 11}}, NewText: "b"},
+			},
+		},
+		{
+			"when line endings are converted",
+			"a\r\nb\r\n",
+			"a\nb\n",
+			[]lsp.TextEdit{
+				{Range: lsp.Range{Start: lsp.Position{Line: 0, Character: 1}, End: lsp.Position{Line: 2, Character: 0}}, NewText: "\nb\n"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			edits := Compute(tc.Before, tc.After)
+			assert.Equal(t, tc.ExpectedEdits, edits)
+
+			result, err := Apply(tc.Before, edits)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.After, result)
+		})
+	}
+}
+
+func TestComputeRoundTrip(t *testing.T) {
+	docs := []string{
+		"",
+		"package main\n",
+		"package main\n\nfunc main() {\n\tprintln(\"héllo\")\n}\n",
+		"package main\r\n\r\nfunc main() {}\r\n",
+		"// 😀 emoji\nvar x = 1",
+		"no trailing newline",
+	}
+
+	for _, before := range docs {
+		for _, after := range docs {
+			result, err := Apply(before, Compute(before, after))
+			assert.NoError(t, err)
+			assert.Equal(t, after, result)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		Name           string
+		Content        string
+		Edits          []lsp.TextEdit
+		ExpectedResult string
+		ShouldError    bool
+	}{
+		{
+			"when edits are out of order",
+			"abc",
+			[]lsp.TextEdit{
+				{Range: lsp.Range{Start: lsp.Position{Character: 2}, End: lsp.Position{Character: 3}}, NewText: "C"},
+				{Range: lsp.Range{Start: lsp.Position{Character: 0}, End: lsp.Position{Character: 1}}, NewText: "A"},
+			},
+			"AbC",
+			false,
+		},
+		{
+			"when inserting at the start of a replaced range",
+			"abc",
+			[]lsp.TextEdit{
+				{Range: lsp.Range{Start: lsp.Position{Character: 1}, End: lsp.Position{Character: 2}}, NewText: "B"},
+				{Range: lsp.Range{Start: lsp.Position{Character: 1}, End: lsp.Position{Character: 1}}, NewText: "_"},
+			},
+			"a_Bc",
+			false,
+		},
+		{
+			"when edits overlap",
+			"abc",
+			[]lsp.TextEdit{
+				{Range: lsp.Range{Start: lsp.Position{Character: 0}, End: lsp.Position{Character: 2}}, NewText: "x"},
+				{Range: lsp.Range{Start: lsp.Position{Character: 1}, End: lsp.Position{Character: 3}}, NewText: "y"},
+			},
+			"",
+			true,
+		},
+		{
+			"when an edit is out of the document",
+			"abc",
+			[]lsp.TextEdit{
+				{Range: lsp.Range{Start: lsp.Position{Line: 3}, End: lsp.Position{Line: 3}}, NewText: "x"},
+			},
+			"",
+			true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			result, err := Apply(tc.Content, tc.Edits)
+			if tc.ShouldError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.ExpectedResult, result)
+		})
+	}
+}
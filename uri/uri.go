@@ -0,0 +1,205 @@
+// Package uri handles the document URIs exchanged with clients.
+//
+// lsp.DocumentURI is a bare string, while clients differ in how they encode
+// the same document: percent-encoded characters, "file:///c%3A/..." versus
+// "file:///C:/..." drive letters, trailing slashes. URI values are normalized
+// so that they can be compared with ==, and converted to and from file system
+// paths.
+package uri
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/intel-go/fastjson"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// FileScheme is the scheme of URIs denoting files on disk.
+const FileScheme = "file"
+
+// URI is a normalized document URI.
+//
+// File URIs are normalized to "file:///path" with percent-encoding applied
+// only where required, an uppercase drive letter and no trailing slash. The
+// URIs of other schemes, e.g. "untitled:Untitled-1", are only normalized to a
+// lowercase scheme.
+type URI string
+
+// Parse parses and normalizes a URI.
+func Parse(s string) (URI, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("uri: %v", err)
+	}
+	if u.Scheme == "" {
+		return "", fmt.Errorf("uri: missing scheme in %q", s)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != FileScheme {
+		return URI(u.Scheme + s[len(u.Scheme):]), nil
+	}
+
+	if u.Host == "localhost" {
+		u.Host = ""
+	}
+	return URI((&url.URL{Scheme: FileScheme, Host: u.Host, Path: normalizePath(u.Path)}).String()), nil
+}
+
+// New parses and normalizes a URI, returning it unchanged if it cannot be
+// parsed. It is meant for URIs received from clients, which are expected to
+// be valid.
+func New(s string) URI {
+	u, err := Parse(s)
+	if err != nil {
+		return URI(s)
+	}
+	return u
+}
+
+// FromDocumentURI returns the normalized form of a document URI.
+func FromDocumentURI(u lsp.DocumentURI) URI {
+	return New(string(u))
+}
+
+// File returns the URI of the file at path, which is made absolute if needed.
+func File(path string) URI {
+	if !filepath.IsAbs(path) {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+	}
+	return fromPath(path, runtime.GOOS == "windows")
+}
+
+// Equal reports whether a and b denote the same document, once normalized.
+func Equal(a, b string) bool {
+	return New(a) == New(b)
+}
+
+// DocumentURI returns u as a lsp.DocumentURI.
+func (u URI) DocumentURI() lsp.DocumentURI {
+	return lsp.DocumentURI(u)
+}
+
+// Scheme returns the scheme of u.
+func (u URI) Scheme() string {
+	if i := strings.IndexByte(string(u), ':'); i > 0 {
+		return string(u[:i])
+	}
+	return ""
+}
+
+// IsFile reports whether u denotes a file on disk.
+func (u URI) IsFile() bool {
+	return u.Scheme() == FileScheme
+}
+
+// Path returns the unescaped, slash-separated path of u, e.g. "/C:/Users/x"
+// for "file:///C:/Users/x". It is the empty string for opaque URIs such as
+// "untitled:Untitled-1".
+func (u URI) Path() string {
+	parsed, err := url.Parse(string(u))
+	if err != nil {
+		return ""
+	}
+	return parsed.Path
+}
+
+// Filename returns the file system path denoted by u, which must be a file
+// URI.
+func (u URI) Filename() (string, error) {
+	return toPath(u, runtime.GOOS == "windows")
+}
+
+// String returns u as a string.
+func (u URI) String() string {
+	return string(u)
+}
+
+// UnmarshalJSON decodes and normalizes a URI, so that URIs received from
+// clients can be compared right away.
+func (u *URI) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := fastjson.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*u = New(s)
+	return nil
+}
+
+// fromPath returns the URI of the absolute path, interpreted as a Windows path
+// if windows is set.
+func fromPath(path string, windows bool) URI {
+	host := ""
+	if windows {
+		path = strings.Replace(path, `\`, "/", -1)
+		if strings.HasPrefix(path, "//") {
+			// UNC path: //server/share/...
+			rest := path[2:]
+			if i := strings.IndexByte(rest, '/'); i >= 0 {
+				host, path = rest[:i], rest[i:]
+			} else {
+				host, path = rest, "/"
+			}
+		} else if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+	}
+	return URI((&url.URL{Scheme: FileScheme, Host: host, Path: normalizePath(path)}).String())
+}
+
+// toPath returns the path denoted by the file URI u, as a Windows path if
+// windows is set.
+func toPath(u URI, windows bool) (string, error) {
+	parsed, err := url.Parse(string(u))
+	if err != nil {
+		return "", fmt.Errorf("uri: %v", err)
+	}
+	if !strings.EqualFold(parsed.Scheme, FileScheme) {
+		return "", errors.New("uri: not a file URI: " + string(u))
+	}
+
+	path := parsed.Path
+	if !windows {
+		if parsed.Host != "" && parsed.Host != "localhost" {
+			return "", errors.New("uri: remote file URI: " + string(u))
+		}
+		return path, nil
+	}
+
+	if parsed.Host != "" && parsed.Host != "localhost" {
+		return `\\` + parsed.Host + strings.Replace(path, "/", `\`, -1), nil
+	}
+	if hasDriveLetter(path) {
+		path = strings.ToUpper(path[1:2]) + path[2:]
+	}
+	return strings.Replace(path, "/", `\`, -1), nil
+}
+
+// normalizePath normalizes the path of a file URI: drive letters are
+// uppercased and trailing slashes removed.
+func normalizePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	if hasDriveLetter(path) {
+		path = path[:1] + strings.ToUpper(path[1:2]) + path[2:]
+	}
+	for len(path) > 1 && strings.HasSuffix(path, "/") && !(hasDriveLetter(path) && len(path) == 4) {
+		path = path[:len(path)-1]
+	}
+	return path
+}
+
+// hasDriveLetter reports whether path starts with a Windows drive letter, as
+// in "/C:/Users".
+func hasDriveLetter(path string) bool {
+	return len(path) >= 3 && path[0] == '/' && path[2] == ':' &&
+		(('a' <= path[1] && path[1] <= 'z') || ('A' <= path[1] && path[1] <= 'Z'))
+}
@@ -0,0 +1,103 @@
+package uri
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		URI      string
+		Expected URI
+	}{
+		{"file:///home/gopher/main.go", "file:///home/gopher/main.go"},
+		{"FILE:///home/gopher/main.go", "file:///home/gopher/main.go"},
+		{"file://localhost/home/gopher/main.go", "file:///home/gopher/main.go"},
+		{"file:///home/gopher/api/", "file:///home/gopher/api"},
+		{"file:///home/gopher/my%20project/%6Dain.go", "file:///home/gopher/my%20project/main.go"},
+		{"file:///home/gopher/a%23b.go", "file:///home/gopher/a%23b.go"},
+		{"file:///c%3A/Users/gopher/main.go", "file:///C:/Users/gopher/main.go"},
+		{"file:///c:/Users/gopher/main.go", "file:///C:/Users/gopher/main.go"},
+		{"file:///C:/", "file:///C:/"},
+		{"file://server/share/main.go", "file://server/share/main.go"},
+		{"file://", "file:///"},
+		{"untitled:Untitled-1", "untitled:Untitled-1"},
+		{"Untitled:Untitled-1", "untitled:Untitled-1"},
+		{"vscode-notebook-cell:/home/gopher/nb.ipynb#W0sZmlsZQ%3D%3D", "vscode-notebook-cell:/home/gopher/nb.ipynb#W0sZmlsZQ%3D%3D"},
+	}
+
+	for _, tc := range tests {
+		u, err := Parse(tc.URI)
+		assert.NoError(t, err, tc.URI)
+		assert.Equal(t, tc.Expected, u, tc.URI)
+	}
+
+	for _, invalid := range []string{"/home/gopher/main.go", "file://%zz", ""} {
+		_, err := Parse(invalid)
+		assert.Error(t, err, invalid)
+		assert.Equal(t, URI(invalid), New(invalid))
+	}
+}
+
+func TestEqual(t *testing.T) {
+	assert.True(t, Equal("file:///c%3A/Users/gopher", "file:///C:/Users/gopher/"))
+	assert.True(t, Equal("file:///home/gopher/%6Dain.go", "file://localhost/home/gopher/main.go"))
+	assert.False(t, Equal("file:///home/gopher/main.go", "file:///home/gopher/Main.go"))
+	assert.False(t, Equal("file:///home/gopher/main.go", "untitled:/home/gopher/main.go"))
+}
+
+func TestAccessors(t *testing.T) {
+	u := New("file:///c%3A/my%20project/main.go")
+	assert.Equal(t, "file", u.Scheme())
+	assert.True(t, u.IsFile())
+	assert.Equal(t, "/C:/my project/main.go", u.Path())
+
+	u = New("untitled:Untitled-1")
+	assert.Equal(t, "untitled", u.Scheme())
+	assert.False(t, u.IsFile())
+	assert.Equal(t, "", u.Path())
+	_, err := u.Filename()
+	assert.Error(t, err)
+}
+
+func TestPaths(t *testing.T) {
+	tests := []struct {
+		Path    string
+		Windows bool
+		URI     URI
+	}{
+		{"/home/gopher/main.go", false, "file:///home/gopher/main.go"},
+		{"/home/gopher/my project/a#b.go", false, "file:///home/gopher/my%20project/a%23b.go"},
+		{"/", false, "file:///"},
+		{`C:\Users\gopher\main.go`, true, "file:///C:/Users/gopher/main.go"},
+		{`C:\`, true, "file:///C:/"},
+		{`\\server\share\main.go`, true, "file://server/share/main.go"},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.URI, fromPath(tc.Path, tc.Windows), tc.Path)
+
+		p, err := toPath(tc.URI, tc.Windows)
+		assert.NoError(t, err, tc.Path)
+		assert.Equal(t, tc.Path, p, tc.Path)
+	}
+
+	p, err := toPath("file:///c%3A/Users/gopher", true)
+	assert.NoError(t, err)
+	assert.Equal(t, `C:\Users\gopher`, p)
+
+	_, err = toPath("file://server/share/main.go", false)
+	assert.Error(t, err)
+	_, err = toPath("untitled:Untitled-1", true)
+	assert.Error(t, err)
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	var v struct {
+		URI URI `json:"uri"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(`{"uri": "file:///c%3A/api/"}`), &v))
+	assert.Equal(t, URI("file:///C:/api"), v.URI)
+}
@@ -0,0 +1,195 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// fileState is what the poller compares to detect changes to a file.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// poller detects file events by periodically walking the file system, for
+// clients unable to watch files.
+type poller struct {
+	watcher  *Watcher
+	interval time.Duration
+	roots    []string
+
+	mu        sync.Mutex
+	snapshots map[*subscription]map[string]fileState // nil until first walked
+
+	polled   chan struct{} // closed once the files are first walked
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newPoller(w *Watcher, interval time.Duration, roots []string) *poller {
+	return &poller{
+		watcher:   w,
+		interval:  interval,
+		roots:     roots,
+		snapshots: make(map[*subscription]map[string]fileState),
+		polled:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// add starts polling the files matching sub. Files existing when they are
+// first walked are not reported as created.
+func (p *poller) add(sub *subscription) {
+	p.mu.Lock()
+	p.snapshots[sub] = nil
+	p.mu.Unlock()
+}
+
+// run polls files until the poller is stopped, starting with the walk
+// snapshotting the files of the subscriptions added so far.
+func (p *poller) run() {
+	p.poll()
+	close(p.polled)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.poll()
+		case <-p.done:
+			return
+		}
+	}
+}
+
+func (p *poller) stop() {
+	p.stopOnce.Do(func() {
+		close(p.done)
+	})
+}
+
+// poll walks the file system once, and reports the events matching each
+// subscription, except for the subscriptions walked for the first time.
+func (p *poller) poll() {
+	p.mu.Lock()
+	subscriptions := make([]*subscription, 0, len(p.snapshots))
+	var roots []string
+	for sub := range p.snapshots {
+		subscriptions = append(subscriptions, sub)
+		roots = append(roots, p.rootsOf(sub)...)
+	}
+	p.mu.Unlock()
+
+	files := p.walk(roots)
+	for _, sub := range subscriptions {
+		current := p.filter(sub, files)
+
+		p.mu.Lock()
+		previous := p.snapshots[sub]
+		p.snapshots[sub] = current
+		p.mu.Unlock()
+
+		if previous == nil {
+			continue
+		}
+		if events := diff(previous, current, sub.kind); len(events) > 0 {
+			sub.handler(context.Background(), events)
+		}
+	}
+}
+
+// rootsOf returns the directories to walk for sub.
+func (p *poller) rootsOf(sub *subscription) []string {
+	if sub.pattern.Base != "" {
+		root, err := sub.pattern.Base.Filename()
+		if err != nil {
+			return nil
+		}
+		return []string{root}
+	}
+	return p.roots
+}
+
+// walk returns the state of the files under roots by path, skipping version
+// control directories.
+func (p *poller) walk(roots []string) map[string]fileState {
+	files := make(map[string]fileState)
+	walked := make(map[string]bool)
+	for _, root := range roots {
+		if walked[root] {
+			continue
+		}
+		walked[root] = true
+
+		_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				// Files may disappear while walking: skip them.
+				return nil
+			}
+			if info.IsDir() {
+				if info.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			files[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+			return nil
+		})
+	}
+	return files
+}
+
+// filter returns the files matching the pattern of sub.
+func (p *poller) filter(sub *subscription, files map[string]fileState) map[string]fileState {
+	matched := make(map[string]fileState)
+	for path, state := range files {
+		if rel, ok := sub.relativePath(uri.File(path)); ok && sub.glob.Match(rel) {
+			matched[path] = state
+		}
+	}
+	return matched
+}
+
+// diff returns the events of the given kinds turning previous into current,
+// sorted by path.
+func diff(previous, current map[string]fileState, kind Kind) []lsp.FileEvent {
+	var paths []string
+	changes := make(map[string]lsp.FileChangeType)
+	for path, state := range current {
+		old, ok := previous[path]
+		switch {
+		case !ok:
+			changes[path] = lsp.Created
+		case old != state:
+			changes[path] = lsp.Changed
+		default:
+			continue
+		}
+		paths = append(paths, path)
+	}
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			changes[path] = lsp.Deleted
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var events []lsp.FileEvent
+	for _, path := range paths {
+		e := lsp.FileEvent{URI: uri.File(path).DocumentURI(), Type: int(changes[path])}
+		if kindOf(e)&kind != 0 {
+			events = append(events, e)
+		}
+	}
+	return events
+}
@@ -0,0 +1,302 @@
+// Package watch lets servers react to changes made to files outside the
+// editor, e.g. to go.mod or generated sources.
+//
+// When the client supports it, watchers are registered dynamically for
+// workspace/didChangeWatchedFiles, and the file events it sends are
+// dispatched to the subscribers whose patterns match. Otherwise, the server
+// polls the file system itself.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/glob"
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// DefaultPollInterval is the interval at which files are polled when the
+// client cannot watch them.
+const DefaultPollInterval = 2 * time.Second
+
+// Kind is a bit set of the kinds of file events to watch.
+type Kind int
+
+const (
+	// Create watches file creations.
+	Create Kind = 1
+	// Change watches file changes.
+	Change Kind = 2
+	// Delete watches file deletions.
+	Delete Kind = 4
+	// All watches every kind of file event.
+	All = Create | Change | Delete
+)
+
+// kindOf returns the kind of a file event.
+func kindOf(e lsp.FileEvent) Kind {
+	return Kind(1 << uint(e.Type-1))
+}
+
+// Pattern is a glob pattern matching the paths of the files to watch.
+//
+// When Base is set, Glob is relative to it and the pattern is sent as a
+// RelativePattern to clients supporting them. Otherwise, Glob matches
+// absolute paths, e.g. "**/go.mod".
+type Pattern struct {
+	Base uri.URI
+	Glob string
+}
+
+// RelativePattern is the glob pattern relative to a base URI sent to clients.
+type RelativePattern struct {
+	BaseURI uri.URI `json:"baseUri"`
+	Pattern string  `json:"pattern"`
+}
+
+// FileSystemWatcher describes a pattern registered with the client.
+type FileSystemWatcher struct {
+	// GlobPattern is either a string or a RelativePattern.
+	GlobPattern interface{} `json:"globPattern"`
+	Kind        Kind        `json:"kind,omitempty"`
+}
+
+// RegistrationOptions are the options of the registration of
+// workspace/didChangeWatchedFiles.
+type RegistrationOptions struct {
+	Watchers []FileSystemWatcher `json:"watchers"`
+}
+
+// Handler is called with the file events matching a subscription.
+type Handler func(ctx context.Context, events []lsp.FileEvent)
+
+type subscription struct {
+	pattern Pattern
+	kind    Kind
+	glob    *glob.Glob
+	handler Handler
+}
+
+// matches reports whether the event matches s.
+func (s *subscription) matches(e lsp.FileEvent) bool {
+	if kindOf(e)&s.kind == 0 {
+		return false
+	}
+	p, ok := s.relativePath(uri.FromDocumentURI(e.URI))
+	return ok && s.glob.Match(p)
+}
+
+// relativePath returns the path matched against the glob of s for u.
+func (s *subscription) relativePath(u uri.URI) (string, bool) {
+	p := u.Path()
+	if s.pattern.Base == "" {
+		return p, true
+	}
+
+	base := strings.TrimSuffix(s.pattern.Base.Path(), "/")
+	if !strings.HasPrefix(p, base+"/") {
+		return "", false
+	}
+	return p[len(base)+1:], true
+}
+
+// watcher returns the watcher registered with the client for s.
+func (s *subscription) watcher(relativePatternSupport bool) FileSystemWatcher {
+	fsw := FileSystemWatcher{GlobPattern: s.pattern.Glob}
+	if s.kind != All {
+		fsw.Kind = s.kind
+	}
+	if s.pattern.Base != "" {
+		if relativePatternSupport {
+			fsw.GlobPattern = RelativePattern{BaseURI: s.pattern.Base, Pattern: s.pattern.Glob}
+		} else {
+			fsw.GlobPattern = path.Join(s.pattern.Base.Path(), s.pattern.Glob)
+		}
+	}
+	return fsw
+}
+
+// Watcher dispatches file events to subscribers.
+type Watcher struct {
+	// PollInterval is the interval at which files are polled when the client
+	// cannot watch them. It defaults to DefaultPollInterval.
+	PollInterval time.Duration
+
+	server *server.Server
+
+	mu            sync.Mutex
+	subscriptions []*subscription
+	started       bool
+	poller        *poller
+}
+
+// New returns a Watcher for the clients of s.
+//
+// It registers the workspace/didChangeWatchedFiles callback on s. Once the
+// client sends the initialized notification, the patterns watched so far are
+// registered with the client, or polled when it cannot watch files. Polling
+// stops when the client shuts the server down or is gone.
+func New(s *server.Server) *Watcher {
+	w := &Watcher{server: s}
+
+	s.On("workspace/didChangeWatchedFiles", w.didChangeWatchedFiles)
+	s.OnInitialized(w.start)
+	s.OnShutdown(func(ctx context.Context) {
+		w.Close()
+	})
+	s.OnExit(w.Close)
+
+	return w
+}
+
+// Watch calls fn with the events of the given kinds for the files matching
+// pattern. A zero kind watches every kind of event.
+func (w *Watcher) Watch(ctx context.Context, pattern Pattern, kind Kind, fn Handler) error {
+	g, err := glob.Compile(pattern.Glob)
+	if err != nil {
+		return fmt.Errorf("[watch] %+v", err)
+	}
+	if kind == 0 {
+		kind = All
+	}
+	sub := &subscription{pattern: pattern, kind: kind, glob: g, handler: fn}
+
+	w.mu.Lock()
+	w.subscriptions = append(w.subscriptions, sub)
+	started, poller := w.started, w.poller
+	w.mu.Unlock()
+
+	switch {
+	case !started:
+		return nil
+	case poller != nil:
+		poller.add(sub)
+		return nil
+	default:
+		return w.register(ctx, []*subscription{sub})
+	}
+}
+
+// Close stops polling files, if the client couldn't watch them. The patterns
+// watched are registered with the next client, or polled, once it is
+// initialized.
+func (w *Watcher) Close() {
+	w.mu.Lock()
+	poller := w.poller
+	w.poller = nil
+	w.started = false
+	w.mu.Unlock()
+
+	if poller != nil {
+		poller.stop()
+	}
+}
+
+// start registers the patterns watched so far with the client, or starts
+// polling them.
+func (w *Watcher) start(ctx context.Context) {
+	w.mu.Lock()
+	w.started = true
+	subscriptions := append([]*subscription(nil), w.subscriptions...)
+	if !w.server.SupportsDynamicRegistration("workspace/didChangeWatchedFiles") {
+		interval := w.PollInterval
+		if interval <= 0 {
+			interval = DefaultPollInterval
+		}
+		w.poller = newPoller(w, interval, w.roots())
+	}
+	poller := w.poller
+	w.mu.Unlock()
+
+	if poller != nil {
+		for _, sub := range subscriptions {
+			poller.add(sub)
+		}
+		go poller.run()
+		return
+	}
+
+	if len(subscriptions) > 0 {
+		if err := w.register(ctx, subscriptions); err != nil {
+			w.server.Logger().Errorf("[watch] register watchers: %+v", err)
+		}
+	}
+}
+
+// register registers subscriptions with the client.
+func (w *Watcher) register(ctx context.Context, subscriptions []*subscription) error {
+	relativePatternSupport := w.server.ClientSupports("workspace.didChangeWatchedFiles.relativePatternSupport")
+
+	var options RegistrationOptions
+	for _, sub := range subscriptions {
+		options.Watchers = append(options.Watchers, sub.watcher(relativePatternSupport))
+	}
+
+	_, err := w.server.RegisterCapability(ctx, "workspace/didChangeWatchedFiles", options)
+	return err
+}
+
+// roots returns the directories of the workspace, which are polled for
+// patterns without a base.
+func (w *Watcher) roots() []string {
+	var params struct {
+		WorkspaceFolders []struct {
+			URI uri.URI `json:"uri"`
+		} `json:"workspaceFolders"`
+	}
+	if err := w.server.DecodeInitializeParams(&params); err != nil {
+		return nil
+	}
+
+	var roots []string
+	for _, f := range params.WorkspaceFolders {
+		if root, err := f.URI.Filename(); err == nil {
+			roots = append(roots, root)
+		}
+	}
+	if roots == nil {
+		if p := w.server.InitializeParams(); p != nil && (p.RootURI != "" || p.RootPath != "") {
+			if root, err := uri.FromDocumentURI(p.Root()).Filename(); err == nil {
+				roots = append(roots, root)
+			}
+		}
+	}
+	return roots
+}
+
+func (w *Watcher) didChangeWatchedFiles(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	var p lsp.DidChangeWatchedFilesParams
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	w.dispatch(ctx, p.Changes)
+	return nil, nil
+}
+
+// dispatch calls each subscriber with the events matching its subscription.
+func (w *Watcher) dispatch(ctx context.Context, events []lsp.FileEvent) {
+	w.mu.Lock()
+	subscriptions := append([]*subscription(nil), w.subscriptions...)
+	w.mu.Unlock()
+
+	for _, sub := range subscriptions {
+		var matched []lsp.FileEvent
+		for _, e := range events {
+			if sub.matches(e) {
+				matched = append(matched, e)
+			}
+		}
+		if len(matched) > 0 {
+			sub.handler(ctx, matched)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package watch
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/intel-go/fastjson"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+	jsonRPCClient "github.com/ybbus/jsonrpc"
+)
+
+// testClient is a client connected to a server over an in-memory pipe.
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func newTestClient(t *testing.T, s *server.Server) *testClient {
+	clientConn, serverConn := net.Pipe()
+	go s.Serve(serverConn)
+
+	return &testClient{t: t, conn: clientConn, reader: bufio.NewReader(clientConn)}
+}
+
+func (c *testClient) send(msg map[string]interface{}) {
+	msg["jsonrpc"] = "2.0"
+	data, err := fastjson.Marshal(msg)
+	assert.NoError(c.t, err)
+	_, err = fmt.Fprintf(c.conn, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	assert.NoError(c.t, err)
+}
+
+func (c *testClient) receive() map[string]interface{} {
+	assert.NoError(c.t, c.conn.SetReadDeadline(time.Now().Add(time.Second)))
+
+	length := 0
+	for {
+		line, err := c.reader.ReadString('\n')
+		if !assert.NoError(c.t, err) {
+			c.t.FailNow()
+		}
+		if line == "\r\n" {
+			break
+		}
+		if strings.HasPrefix(line, "Content-Length: ") {
+			length, _ = strconv.Atoi(strings.TrimSpace(line[len("Content-Length: "):]))
+		}
+	}
+	data := make([]byte, length)
+	_, err := io.ReadFull(c.reader, data)
+	assert.NoError(c.t, err)
+
+	var msg map[string]interface{}
+	assert.NoError(c.t, fastjson.Unmarshal(data, &msg))
+	return msg
+}
+
+func TestWatchRegistration(t *testing.T) {
+	s := server.NewServer(context.Background())
+	w := New(s)
+
+	modEvents := make(chan []lsp.FileEvent, 1)
+	assert.NoError(t, w.Watch(context.Background(), Pattern{Glob: "**/go.mod"}, 0, func(ctx context.Context, events []lsp.FileEvent) {
+		modEvents <- events
+	}))
+	genEvents := make(chan []lsp.FileEvent, 1)
+	assert.NoError(t, w.Watch(context.Background(), Pattern{Base: "file:///api", Glob: "gen/*.go"}, Create|Delete, func(ctx context.Context, events []lsp.FileEvent) {
+		genEvents <- events
+	}))
+
+	c := newTestClient(t, s)
+	defer c.conn.Close()
+	c.send(map[string]interface{}{"id": 0, "method": "initialize", "params": map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"workspace": map[string]interface{}{
+				"didChangeWatchedFiles": map[string]interface{}{"dynamicRegistration": true, "relativePatternSupport": true},
+			},
+		},
+	}})
+	c.receive()
+	c.send(map[string]interface{}{"method": "initialized", "params": map[string]interface{}{}})
+
+	request := c.receive()
+	assert.Equal(t, "client/registerCapability", request["method"])
+	assert.Equal(t, map[string]interface{}{
+		"watchers": []interface{}{
+			map[string]interface{}{"globPattern": "**/go.mod"},
+			map[string]interface{}{"globPattern": map[string]interface{}{"baseUri": "file:///api", "pattern": "gen/*.go"}, "kind": float64(5)},
+		},
+	}, request["params"].(map[string]interface{})["registrations"].([]interface{})[0].(map[string]interface{})["registerOptions"])
+	c.send(map[string]interface{}{"id": request["id"], "result": nil})
+
+	c.send(map[string]interface{}{"method": "workspace/didChangeWatchedFiles", "params": map[string]interface{}{
+		"changes": []interface{}{
+			map[string]interface{}{"uri": "file:///api/go.mod", "type": 2},
+			map[string]interface{}{"uri": "file:///api/gen/types.go", "type": 1},
+			map[string]interface{}{"uri": "file:///api/gen/enums.go", "type": 2},
+			map[string]interface{}{"uri": "file:///web/gen/types.go", "type": 1},
+		},
+	}})
+
+	assert.Equal(t, []lsp.FileEvent{{URI: "file:///api/go.mod", Type: 2}}, <-modEvents)
+	assert.Equal(t, []lsp.FileEvent{{URI: "file:///api/gen/types.go", Type: 1}}, <-genEvents)
+}
+
+func TestWatchPolling(t *testing.T) {
+	root, err := ioutil.TempDir("", "watch")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "go.mod"), []byte("module api\n"), 0644))
+
+	s := server.NewServer(context.Background())
+	w := New(s)
+	w.PollInterval = 10 * time.Millisecond
+	defer w.Close()
+
+	events := make(chan []lsp.FileEvent, 10)
+	assert.NoError(t, w.Watch(context.Background(), Pattern{Glob: "**/*.go"}, 0, func(ctx context.Context, e []lsp.FileEvent) {
+		events <- e
+	}))
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	rpcClient := jsonRPCClient.NewClient(ts.URL)
+
+	_, err = rpcClient.Call("initialize", map[string]interface{}{"rootUri": string(uri.File(root)), "capabilities": map[string]interface{}{}})
+	assert.NoError(t, err)
+	_, err = rpcClient.Call("initialized", map[string]interface{}{})
+	assert.NoError(t, err)
+
+	// Files are first walked in the background, not while initializing.
+	w.mu.Lock()
+	poller := w.poller
+	w.mu.Unlock()
+	<-poller.polled
+
+	mainGo := filepath.Join(root, "main.go")
+	assert.NoError(t, ioutil.WriteFile(mainGo, []byte("package main\n"), 0644))
+	assert.Equal(t, []lsp.FileEvent{{URI: uri.File(mainGo).DocumentURI(), Type: 1}}, receive(t, events))
+
+	assert.NoError(t, os.Remove(mainGo))
+	assert.Equal(t, []lsp.FileEvent{{URI: uri.File(mainGo).DocumentURI(), Type: 3}}, receive(t, events))
+
+	// Once closed, patterns are watched again when the next client is
+	// initialized, not registered with the current one.
+	w.Close()
+	assert.NoError(t, w.Watch(context.Background(), Pattern{Glob: "**/*.mod"}, 0, func(ctx context.Context, e []lsp.FileEvent) {}))
+}
+
+func receive(t *testing.T, events chan []lsp.FileEvent) []lsp.FileEvent {
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no file event received")
+		return nil
+	}
+}
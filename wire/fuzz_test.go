@@ -0,0 +1,99 @@
+//go:build go1.18
+// +build go1.18
+
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// FuzzReadMessage checks that reading arbitrary streams never panics, and
+// either yields bodies of the announced length or fails with a HeaderError or
+// a premature end of stream.
+func FuzzReadMessage(f *testing.F) {
+	for _, seed := range []string{
+		"Content-Length: 2\r\n\r\n{}",
+		"Content-Length: 2\r\n\r\n{}Content-Length: 2\r\n\r\n[]",
+		"Content-Type: application/vscode-jsonrpc; charset=utf-8\r\nContent-Length: 2\r\n\r\n{}",
+		"content-length:2\r\n\r\n{}",
+		"Content-Length:    2   \r\n\r\n{}",
+		"Content-Length: 2\r\nContent-Length: 3\r\n\r\n{}",
+		"Content-Length: 2\n\n{}",
+		"Content-Length: 2\r\n{}",
+		"Content-Length: 2",
+		"Content-Length: 2\r\n",
+		"Content-Length: 10\r\n\r\n{}",
+		"Content-Length: two\r\n\r\n{}",
+		"Content-Length: -1\r\n\r\n{}",
+		"Content-Length: +2\r\n\r\n{}",
+		"Content-Length: 0x2\r\n\r\n{}",
+		"Content-Length: \r\n\r\n{}",
+		"Content-Length: 67108865\r\n\r\n{}",
+		"Content-Length: 1000000000000\r\n\r\n{}",
+		"Content-Length: 99999999999999999999999999\r\n\r\n{}",
+		"Content-Type: application/json\r\n\r\n{}",
+		"Content-Length 2\r\n\r\n{}",
+		"\r\n\r\n",
+		"\r\n",
+		"Content-Length: 2\r\n\r" + strings.Repeat(" ", 5000) + "\n{}",
+		strings.Repeat("X", 5000) + "\r\n\r\n{}",
+		"",
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := bufio.NewReader(bytes.NewReader(data))
+		for {
+			body, err := ReadMessage(r)
+			if err != nil {
+				switch err.(type) {
+				case *HeaderError:
+				default:
+					if err != io.EOF && err != io.ErrUnexpectedEOF {
+						t.Fatalf("unexpected error %T: %v", err, err)
+					}
+				}
+				return
+			}
+			if len(body) > MaxContentLength {
+				t.Fatalf("body of %d bytes exceeds MaxContentLength", len(body))
+			}
+		}
+	})
+}
+
+// FuzzReadMessageBody checks that any body framed with its Content-Length is
+// read back unchanged.
+func FuzzReadMessageBody(f *testing.F) {
+	for _, seed := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize"}`,
+		"Content-Length: 2\r\n\r\n{}",
+		"\r\n\r\n",
+		"",
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		framed := fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+		r := bufio.NewReader(strings.NewReader(framed + framed))
+		for i := 0; i < 2; i++ {
+			got, err := ReadMessage(r)
+			if err != nil {
+				t.Fatalf("message %d: %v", i, err)
+			}
+			if !bytes.Equal(got, body) {
+				t.Fatalf("message %d: got %q, want %q", i, got, body)
+			}
+		}
+		if _, err := ReadMessage(r); err != io.EOF {
+			t.Fatalf("expected io.EOF after the messages, got %v", err)
+		}
+	})
+}
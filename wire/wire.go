@@ -0,0 +1,101 @@
+// Package wire implements the base protocol of the LSP specification, which
+// frames JSON-RPC messages exchanged over a stream with HTTP-like headers.
+package wire
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/intel-go/fastjson"
+)
+
+// MaxContentLength bounds the size of incoming messages, so that a peer
+// announcing a huge Content-Length cannot exhaust the reader's memory.
+const MaxContentLength = 64 << 20
+
+// HeaderError is returned when the headers of a message are malformed. The
+// stream cannot be resynchronised after such an error.
+type HeaderError struct {
+	Message string
+}
+
+func (e *HeaderError) Error() string {
+	return "[wire] invalid header: " + e.Message
+}
+
+// ReadMessage reads a message framed by the base protocol of the LSP
+// specification: headers each terminated by "\r\n", an empty line, then a
+// body of Content-Length bytes.
+func ReadMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for first := true; ; first = false {
+		line, err := r.ReadSlice('\n')
+		switch {
+		case err == bufio.ErrBufferFull:
+			return nil, &HeaderError{"header line too long"}
+		case err == io.EOF && first && len(line) == 0:
+			return nil, io.EOF
+		case err == io.EOF:
+			return nil, io.ErrUnexpectedEOF
+		case err != nil:
+			return nil, err
+		}
+
+		if len(line) < 2 || line[len(line)-2] != '\r' {
+			return nil, &HeaderError{"header line not terminated by \\r\\n"}
+		}
+		header := string(line[:len(line)-2])
+		if header == "" {
+			break
+		}
+
+		colon := strings.IndexByte(header, ':')
+		if colon < 0 {
+			return nil, &HeaderError{fmt.Sprintf("malformed header %q", header)}
+		}
+		name, value := strings.TrimSpace(header[:colon]), strings.TrimSpace(header[colon+1:])
+		if strings.EqualFold(name, "Content-Length") {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, &HeaderError{fmt.Sprintf("invalid Content-Length %q", value)}
+			}
+			if n > MaxContentLength {
+				return nil, &HeaderError{fmt.Sprintf("Content-Length %d exceeds %d", n, MaxContentLength)}
+			}
+			length = n
+		}
+	}
+
+	if length < 0 {
+		return nil, &HeaderError{"missing Content-Length"}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return body, nil
+}
+
+// WriteMessage writes v as a message framed by the base protocol.
+func WriteMessage(w io.Writer, v interface{}) error {
+	body, err := fastjson.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	data := make([]byte, 0, len(body)+32)
+	data = append(data, "Content-Length: "...)
+	data = strconv.AppendInt(data, int64(len(body)), 10)
+	data = append(data, "\r\n\r\n"...)
+	data = append(data, body...)
+
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,38 @@
+package wire
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadMessage(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Input         string
+		ExpectedBody  string
+		ExpectedError string
+	}{
+		{"when the message is well formed", "Content-Length: 2\r\n\r\n{}", "{}", ""},
+		{"when there are several headers", "Content-Type: application/vscode-jsonrpc; charset=utf-8\r\ncontent-length: 2\r\n\r\n{}", "{}", ""},
+		{"when the length is missing", "Content-Type: application/json\r\n\r\n{}", "", "[wire] invalid header: missing Content-Length"},
+		{"when the length is not a number", "Content-Length: two\r\n\r\n{}", "", `[wire] invalid header: invalid Content-Length "two"`},
+		{"when the length is huge", "Content-Length: 1000000000000\r\n\r\n{}", "", "[wire] invalid header: Content-Length 1000000000000 exceeds 67108864"},
+		{"when headers are terminated by \\n", "Content-Length: 2\n\n{}", "", `[wire] invalid header: header line not terminated by \r\n`},
+		{"when the body is truncated", "Content-Length: 10\r\n\r\n{}", "", "unexpected EOF"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			body, err := ReadMessage(bufio.NewReader(strings.NewReader(tc.Input)))
+			if tc.ExpectedError != "" {
+				assert.EqualError(t, err, tc.ExpectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.ExpectedBody, string(body))
+		})
+	}
+}
@@ -0,0 +1,169 @@
+// Package workspace models the folders of a multi-root workspace.
+//
+// Folders are populated from the initialize request and kept in sync with the
+// client through workspace/didChangeWorkspaceFolders notifications. Servers
+// keeping per-folder state subscribe to folder additions and removals.
+package workspace
+
+import (
+	"context"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/intel-go/fastjson"
+	"github.com/osamingo/jsonrpc"
+)
+
+// Folder is a workspace folder.
+type Folder struct {
+	URI  uri.URI `json:"uri"`
+	Name string  `json:"name"`
+}
+
+// contains reports whether u is f's URI or one of its descendants.
+func (f Folder) contains(u uri.URI) bool {
+	root := strings.TrimSuffix(string(f.URI), "/")
+	return u == f.URI || strings.HasPrefix(string(u), root+"/")
+}
+
+// FoldersChangeEvent describes folders added to and removed from the
+// workspace.
+type FoldersChangeEvent struct {
+	Added   []Folder `json:"added"`
+	Removed []Folder `json:"removed"`
+}
+
+// Workspace tracks the folders opened in the client.
+type Workspace struct {
+	server *server.Server
+
+	mu          sync.RWMutex
+	folders     []Folder
+	subscribers []func(ctx context.Context, event FoldersChangeEvent)
+}
+
+// New returns the Workspace of the clients of s.
+//
+// It declares the workspace folders capability and registers the
+// workspace/didChangeWorkspaceFolders callback on s. Folders are populated
+// once the client sends the initialized notification, and removed once it is
+// gone.
+func New(s *server.Server) *Workspace {
+	w := &Workspace{server: s}
+
+	caps := s.Capabilities()
+	if caps.Workspace == nil {
+		caps.Workspace = &server.WorkspaceServerCapabilities{}
+	}
+	caps.Workspace.WorkspaceFolders = &server.WorkspaceFoldersServerCapabilities{
+		Supported:           true,
+		ChangeNotifications: true,
+	}
+
+	s.On("workspace/didChangeWorkspaceFolders", w.didChangeWorkspaceFolders)
+	s.OnInitialized(w.initialized)
+	s.OnExit(w.reset)
+
+	return w
+}
+
+// Folders returns the folders of the workspace, in the order they were added.
+func (w *Workspace) Folders() []Folder {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return append([]Folder(nil), w.folders...)
+}
+
+// FolderOf returns the folder owning the document at u, that is the innermost
+// folder containing it.
+func (w *Workspace) FolderOf(u uri.URI) (Folder, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var owner Folder
+	found := false
+	for _, f := range w.folders {
+		if f.contains(u) && (!found || len(f.URI) > len(owner.URI)) {
+			owner, found = f, true
+		}
+	}
+	return owner, found
+}
+
+// Subscribe registers fn to be called when folders are added to or removed
+// from the workspace. The initial folders of the workspace are notified as
+// added once the client is initialized.
+func (w *Workspace) Subscribe(fn func(ctx context.Context, event FoldersChangeEvent)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// initialized populates the workspace from the initialize request. Clients not
+// supporting workspace folders only send a root, which then forms the single
+// folder of the workspace.
+func (w *Workspace) initialized(ctx context.Context) {
+	var params struct {
+		WorkspaceFolders []Folder `json:"workspaceFolders"`
+	}
+	if err := w.server.DecodeInitializeParams(&params); err != nil {
+		return
+	}
+
+	folders := params.WorkspaceFolders
+	if folders == nil {
+		if p := w.server.InitializeParams(); p != nil && (p.RootURI != "" || p.RootPath != "") {
+			root := uri.FromDocumentURI(p.Root())
+			folders = []Folder{{URI: root, Name: path.Base(root.Path())}}
+		}
+	}
+
+	w.change(ctx, FoldersChangeEvent{Added: folders})
+}
+
+// reset removes the folders of the client once it is gone, so that the next
+// client starts with its own.
+func (w *Workspace) reset() {
+	w.change(context.Background(), FoldersChangeEvent{Removed: w.Folders()})
+}
+
+func (w *Workspace) didChangeWorkspaceFolders(ctx context.Context, params *fastjson.RawMessage) (interface{}, error) {
+	var p struct {
+		Event FoldersChangeEvent `json:"event"`
+	}
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	w.change(ctx, p.Event)
+	return nil, nil
+}
+
+// change applies event to the workspace and notifies subscribers.
+func (w *Workspace) change(ctx context.Context, event FoldersChangeEvent) {
+	if len(event.Added) == 0 && len(event.Removed) == 0 {
+		return
+	}
+
+	w.mu.Lock()
+	for _, removed := range event.Removed {
+		for i, f := range w.folders {
+			if f.URI == removed.URI {
+				w.folders = append(w.folders[:i:i], w.folders[i+1:]...)
+				break
+			}
+		}
+	}
+	w.folders = append(w.folders, event.Added...)
+	subscribers := w.subscribers
+	w.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(ctx, event)
+	}
+}
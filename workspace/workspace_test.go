@@ -0,0 +1,160 @@
+package workspace
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goodgophers/golsp-sdk/lsptest"
+	"github.com/goodgophers/golsp-sdk/server"
+	"github.com/goodgophers/golsp-sdk/uri"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+	jsonRPCClient "github.com/ybbus/jsonrpc"
+)
+
+func TestWorkspace(t *testing.T) {
+	tests := []struct {
+		Name             string
+		InitializeParams map[string]interface{}
+		ExpectedFolders  []Folder
+	}{
+		{
+			"when the client sends workspace folders",
+			map[string]interface{}{
+				"rootUri": "file:///home/gopher/api",
+				"workspaceFolders": []interface{}{
+					map[string]interface{}{"uri": "file:///home/gopher/api", "name": "api"},
+					map[string]interface{}{"uri": "file:///home/gopher/api/tools/", "name": "tools"},
+				},
+			},
+			[]Folder{
+				{URI: "file:///home/gopher/api", Name: "api"},
+				{URI: "file:///home/gopher/api/tools", Name: "tools"},
+			},
+		},
+		{
+			"when the client only sends a root",
+			map[string]interface{}{"rootUri": "file:///home/gopher/api"},
+			[]Folder{{URI: "file:///home/gopher/api", Name: "api"}},
+		},
+		{
+			"when no folder is open",
+			map[string]interface{}{"workspaceFolders": nil},
+			nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			s := server.NewServer(context.Background())
+			w := New(s)
+
+			var events []FoldersChangeEvent
+			w.Subscribe(func(ctx context.Context, event FoldersChangeEvent) {
+				events = append(events, event)
+			})
+
+			ts := httptest.NewServer(s)
+			defer ts.Close()
+			rpcClient := jsonRPCClient.NewClient(ts.URL)
+
+			tc.InitializeParams["capabilities"] = map[string]interface{}{}
+			var result map[string]interface{}
+			assert.NoError(t, rpcClient.CallFor(&result, "initialize", tc.InitializeParams))
+			assert.Equal(t, map[string]interface{}{
+				"workspaceFolders": map[string]interface{}{"supported": true, "changeNotifications": true},
+			}, result["capabilities"].(map[string]interface{})["workspace"])
+
+			_, err := rpcClient.Call("initialized", map[string]interface{}{})
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.ExpectedFolders, w.Folders())
+			if tc.ExpectedFolders != nil {
+				assert.Equal(t, []FoldersChangeEvent{{Added: tc.ExpectedFolders}}, events)
+			} else {
+				assert.Empty(t, events)
+			}
+		})
+	}
+}
+
+func TestWorkspaceChange(t *testing.T) {
+	s := server.NewServer(context.Background())
+	w := New(s)
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	rpcClient := jsonRPCClient.NewClient(ts.URL)
+
+	_, err := rpcClient.Call("initialize", map[string]interface{}{
+		"capabilities": map[string]interface{}{},
+		"workspaceFolders": []interface{}{
+			map[string]interface{}{"uri": "file:///home/gopher/api", "name": "api"},
+			map[string]interface{}{"uri": "file:///home/gopher/web", "name": "web"},
+		},
+	})
+	assert.NoError(t, err)
+	_, err = rpcClient.Call("initialized", map[string]interface{}{})
+	assert.NoError(t, err)
+
+	var events []FoldersChangeEvent
+	w.Subscribe(func(ctx context.Context, event FoldersChangeEvent) {
+		events = append(events, event)
+	})
+
+	_, err = rpcClient.Call("workspace/didChangeWorkspaceFolders", map[string]interface{}{
+		"event": map[string]interface{}{
+			"added":   []interface{}{map[string]interface{}{"uri": "file:///home/gopher/api/internal/gen", "name": "gen"}},
+			"removed": []interface{}{map[string]interface{}{"uri": "file:///home/gopher/web", "name": "web"}},
+		},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []Folder{
+		{URI: "file:///home/gopher/api", Name: "api"},
+		{URI: "file:///home/gopher/api/internal/gen", Name: "gen"},
+	}, w.Folders())
+	assert.Equal(t, []FoldersChangeEvent{{
+		Added:   []Folder{{URI: "file:///home/gopher/api/internal/gen", Name: "gen"}},
+		Removed: []Folder{{URI: "file:///home/gopher/web", Name: "web"}},
+	}}, events)
+
+	tests := []struct {
+		URI            string
+		ExpectedFolder string
+	}{
+		{"file:///home/gopher/api/main.go", "api"},
+		{"file:///home/gopher/api/internal/gen/types.go", "gen"},
+		{"file:///home/gopher/api", "api"},
+		{"FILE:///home/gopher/api/%6Dain.go", "api"},
+		{"file:///home/gopher/apiary/main.go", ""},
+		{"file:///home/gopher/web/index.html", ""},
+	}
+	for _, tc := range tests {
+		f, ok := w.FolderOf(uri.New(tc.URI))
+		assert.Equal(t, tc.ExpectedFolder != "", ok, tc.URI)
+		assert.Equal(t, tc.ExpectedFolder, f.Name, tc.URI)
+	}
+}
+
+func TestWorkspaceSessions(t *testing.T) {
+	s := server.NewServer(context.Background())
+	w := New(s)
+
+	for _, folder := range []Folder{
+		{URI: "file:///home/gopher/api", Name: "api"},
+		{URI: "file:///home/gopher/web", Name: "web"},
+	} {
+		c := lsptest.New(s)
+		_, err := c.Initialize(context.Background(), lsptest.InitializeParams{RootURI: folder.URI})
+		assert.NoError(t, err)
+		_, err = c.Hover(context.Background(), "file:///home/gopher/main.go", lsp.Position{})
+		assert.Error(t, err) // waits for the initialized notification to be handled
+
+		// The folders of the previous client are gone.
+		assert.Equal(t, []Folder{folder}, w.Folders())
+		assert.NoError(t, c.Close())
+	}
+	assert.Empty(t, w.Folders())
+}